@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"flag"
+	"time"
 
 	"manjing-ai-go/config"
 	"manjing-ai-go/internal/handler"
@@ -10,7 +11,6 @@ import (
 	"manjing-ai-go/internal/router"
 	"manjing-ai-go/internal/service"
 	"manjing-ai-go/pkg/email"
-	"manjing-ai-go/pkg/llm"
 	"manjing-ai-go/pkg/logger"
 	redisclient "manjing-ai-go/pkg/redis"
 	"manjing-ai-go/pkg/storage"
@@ -66,26 +66,42 @@ func main() {
 
 	chapterRepo := repository.NewChapterRepo(db)
 	chapterSvc := service.NewChapterService(chapterRepo, projectRepo)
-	chapterHandler := handler.NewChapterHandler(chapterSvc)
 
 	voiceRepo := repository.NewVoiceRepo(db)
 	voiceSvc := service.NewVoiceService(voiceRepo)
 	voiceHandler := handler.NewVoiceHandler(voiceSvc)
 
 	// LLM 模块
-	llmModelRepo := repository.NewLLMModelRepo(db)
-	llmCallLogRepo := repository.NewLLMCallLogRepo(db)
-	llmClient := llm.NewClient(llm.ClientConfig{
-		BaseURL:     cfg.LLM.Default.BaseURL,
-		APIKey:      cfg.LLM.Default.APIKey,
-		Model:       cfg.LLM.Default.Model,
-		MaxTokens:   cfg.LLM.Default.MaxTokens,
-		Temperature: cfg.LLM.Default.Temperature,
-		Timeout:     cfg.LLM.Default.Timeout,
-	})
-	llmSvc := service.NewLLMService(llmModelRepo, llmCallLogRepo, llmClient, cfg.LLM)
+	if cfg.LLM.Secret.MasterKey == "" {
+		logger.L().Warn("llm.secret.master_key not configured, creating LLM models is disabled")
+	}
+	// 工具依赖的服务在 LLM 服务之后创建，注册延后到下方
+	llmSvc, llmTools, err := service.BuildLLMService(cfg.LLM, db, rdb, storageSvc)
+	if err != nil {
+		panic(err)
+	}
+	go purgeLLMPayloads(llmSvc)
+	if cfg.LLM.Health.Enable {
+		go checkLLMModelHealth(llmSvc, time.Duration(cfg.LLM.Health.IntervalSeconds)*time.Second)
+	}
 	llmHandler := handler.NewLLMHandler(llmSvc)
 
+	chapterParseRepo := repository.NewChapterParseRepo(db)
+	chapterParseSvc := service.NewChapterParseService(chapterParseRepo, chapterSvc, llmSvc, cfg.LLM.ChapterParse)
+	chapterHandler := handler.NewChapterHandler(chapterSvc, chapterParseSvc)
+
+	subjectRepo := repository.NewSubjectRepo(db)
+	subjectSvc := service.NewSubjectService(subjectRepo, chapterRepo, resRepo, projectSvc, voiceSvc, llmSvc, cfg.LLM.SubjectExtract)
+	service.RegisterBuiltinTools(llmTools, subjectSvc, chapterSvc)
+	subjectHandler := handler.NewSubjectHandler(subjectSvc)
+
+	llmConvRepo := repository.NewLLMConversationRepo(db)
+	llmConvSvc := service.NewLLMConversationService(llmConvRepo, llmSvc, projectSvc, chapterSvc, cfg.LLM)
+	llmConvHandler := handler.NewLLMConversationHandler(llmConvSvc)
+
+	llmBatchSvc := service.NewLLMBatchService(repository.NewLLMBatchRepo(db), cfg.LLM.Batch)
+	llmBatchHandler := handler.NewLLMBatchHandler(llmBatchSvc)
+
 	emailClient := email.NewSMTPClient(email.SMTPConfig{
 		Host:        cfg.Email.SMTP.Host,
 		Port:        cfg.Email.SMTP.Port,
@@ -99,7 +115,37 @@ func main() {
 	emailSvc := service.NewEmailService(cfg.Email, rdb, emailClient)
 	emailHandler := handler.NewEmailHandler(emailSvc)
 
-	r := router.NewRouter(cfg, authHandler, resHandler, projectHandler, chapterHandler, emailHandler, voiceHandler, llmHandler, rdb)
+	r := router.NewRouter(cfg, authHandler, resHandler, projectHandler, chapterHandler, subjectHandler, emailHandler, voiceHandler, llmHandler, llmConvHandler, llmBatchHandler, userRepo, rdb)
 	logger.L().Info("api listening on ", cfg.App.Addr)
 	_ = r.Run(cfg.App.Addr)
 }
+
+// purgeLLMPayloads 每小时清理过期的LLM调用内容采样
+func purgeLLMPayloads(svc *service.LLMServiceImpl) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		n, err := svc.PurgeExpiredPayloads(context.Background())
+		if err != nil {
+			logger.L().Errorf("purge llm payloads failed: %v", err)
+			continue
+		}
+		if n > 0 {
+			logger.L().Infof("purged %d expired llm payloads", n)
+		}
+	}
+}
+
+// checkLLMModelHealth 定期探测启用的LLM模型并更新健康状态
+func checkLLMModelHealth(svc *service.LLMServiceImpl, interval time.Duration) {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := svc.CheckModelsHealth(context.Background()); err != nil {
+			logger.L().Errorf("check llm model health failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/pkg/secret"
+)
+
+// llmkey 维护 llm_models.api_key 的加密状态：
+//
+//	encrypt  加密存量明文密钥
+//	rotate   主密钥轮换后，用当前主密钥重新加密数据密钥（需把旧主密钥配置到 llm.secret.previous_keys）
+//	decrypt  还原为明文，仅用于回滚迁移
+func main() {
+	fs := flag.NewFlagSet("llmkey", flag.ExitOnError)
+	configPath := fs.String("config", "", "config file path")
+	dryRun := fs.Bool("dry-run", false, "only print affected rows")
+	_ = fs.Parse(os.Args[1:])
+	args := fs.Args()
+	if len(args) < 1 {
+		fmt.Println("usage: llmkey [-config path] [-dry-run] [encrypt|rotate|decrypt]")
+		os.Exit(1)
+	}
+
+	var cfg *config.Config
+	if *configPath != "" {
+		cfg = config.MustLoadWithPath(*configPath)
+	} else {
+		cfg = config.MustLoad()
+	}
+	keyring, err := secret.NewKeyring(cfg.LLM.Secret.MasterKey, cfg.LLM.Secret.PreviousKeys)
+	if err != nil {
+		panic(err)
+	}
+	db, err := repository.InitDB(cfg.DB.DSN)
+	if err != nil {
+		panic(err)
+	}
+	repo := repository.NewLLMModelRepo(db)
+
+	var transform func(m *model.LLMModel) (string, bool, error)
+	switch args[0] {
+	case "encrypt":
+		transform = func(m *model.LLMModel) (string, bool, error) {
+			if secret.IsEncrypted(m.APIKey) {
+				return "", false, nil
+			}
+			enc, err := keyring.Encrypt(m.APIKey)
+			return enc, true, err
+		}
+	case "rotate":
+		transform = func(m *model.LLMModel) (string, bool, error) {
+			if !secret.IsEncrypted(m.APIKey) {
+				enc, err := keyring.Encrypt(m.APIKey)
+				return enc, true, err
+			}
+			if keyring.KeyID(m.APIKey) == keyring.CurrentKeyID() {
+				return "", false, nil
+			}
+			enc, err := keyring.Rewrap(m.APIKey)
+			return enc, true, err
+		}
+	case "decrypt":
+		transform = func(m *model.LLMModel) (string, bool, error) {
+			if !secret.IsEncrypted(m.APIKey) {
+				return "", false, nil
+			}
+			plain, err := keyring.Decrypt(m.APIKey)
+			return plain, true, err
+		}
+	default:
+		fmt.Println("unknown command")
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	items, err := repo.FindAll(ctx)
+	if err != nil {
+		panic(err)
+	}
+
+	changed, failed := 0, 0
+	for i := range items {
+		m := &items[i]
+		value, ok, err := transform(m)
+		if err != nil {
+			failed++
+			fmt.Printf("model %d (%s): %v\n", m.ID, m.Name, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		changed++
+		if *dryRun {
+			fmt.Printf("model %d (%s): would %s\n", m.ID, m.Name, args[0])
+			continue
+		}
+		updates := map[string]interface{}{
+			"api_key":    value,
+			"updated_at": time.Now(),
+		}
+		if args[0] == "encrypt" && m.APIKeyMask == "" {
+			updates["api_key_mask"] = model.MaskAPIKey(m.APIKey)
+		}
+		if err := repo.Update(ctx, m.ID, updates); err != nil {
+			failed++
+			changed--
+			fmt.Printf("model %d (%s): update failed: %v\n", m.ID, m.Name, err)
+		}
+	}
+	fmt.Printf("%s: %d changed, %d failed, %d total\n", args[0], changed, failed, len(items))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/internal/service"
+	"manjing-ai-go/pkg/logger"
+	redisclient "manjing-ai-go/pkg/redis"
+	"manjing-ai-go/pkg/storage"
+)
+
+// worker 执行 POST /v1/llm/batches 提交的批量任务，可部署多个实例；
+// 收到 SIGINT/SIGTERM 后中止执行中的任务并放回队列。
+func main() {
+	logger.Init()
+	configPath := flag.String("config", "", "config file path")
+	flag.Parse()
+	cfg := config.MustLoadWithPath(*configPath)
+
+	db, err := repository.InitDB(cfg.DB.DSN)
+	if err != nil {
+		panic(err)
+	}
+
+	rdb := redisclient.New(cfg.Redis)
+	if err := rdb.Ping(context.Background()); err != nil {
+		// Redis 可选，初始化失败不阻断启动
+		rdb = nil
+	}
+
+	var storageSvc storage.Service
+	switch cfg.Storage.Type {
+	case "cos":
+		storageSvc = storage.NewCOSStorage(cfg.Storage.COS)
+	default:
+		storageSvc = storage.NewLocalStorage(cfg.Storage.Local)
+	}
+
+	resRepo := repository.NewResourceRepo(db)
+	projectRepo := repository.NewProjectRepo(db)
+	projectSvc := service.NewProjectService(projectRepo)
+	chapterRepo := repository.NewChapterRepo(db)
+	chapterSvc := service.NewChapterService(chapterRepo, projectRepo)
+	voiceSvc := service.NewVoiceService(repository.NewVoiceRepo(db))
+
+	llmSvc, llmTools, err := service.BuildLLMService(cfg.LLM, db, rdb, storageSvc)
+	if err != nil {
+		panic(err)
+	}
+	subjectSvc := service.NewSubjectService(repository.NewSubjectRepo(db), chapterRepo, resRepo, projectSvc, voiceSvc, llmSvc, cfg.LLM.SubjectExtract)
+	service.RegisterBuiltinTools(llmTools, subjectSvc, chapterSvc)
+
+	worker := service.NewLLMBatchWorker(repository.NewLLMBatchRepo(db), llmSvc, cfg.LLM.Batch)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	worker.Run(ctx)
+}
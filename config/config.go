@@ -73,17 +73,152 @@ type SwaggerConfig struct {
 
 // LLMConfig LLM 大语言模型配置
 type LLMConfig struct {
-	Default LLMModelConfig `mapstructure:"default"`
+	Default        LLMModelConfig          `mapstructure:"default"`
+	Secret         LLMSecretConfig         `mapstructure:"secret"`
+	ChapterParse   LLMChapterParseConfig   `mapstructure:"chapter_parse"`
+	SubjectExtract LLMSubjectExtractConfig `mapstructure:"subject_extract"`
+	Conversation   LLMConversationConfig   `mapstructure:"conversation"`
+	Quota          LLMQuotaConfig          `mapstructure:"quota"`
+	Capture        LLMCaptureConfig        `mapstructure:"capture"`
+	Health         LLMHealthConfig         `mapstructure:"health"`
+	Routing        LLMRoutingConfig        `mapstructure:"routing"`
+	Cache          LLMCacheConfig          `mapstructure:"cache"`
+	Structured     LLMStructuredConfig     `mapstructure:"structured"`
+	Tools          LLMToolsConfig          `mapstructure:"tools"`
+	Images         LLMImagesConfig         `mapstructure:"images"`
+	Context        LLMContextConfig        `mapstructure:"context"`
+	Batch          LLMBatchConfig          `mapstructure:"batch"`
+	Limits         LLMLimitsConfig         `mapstructure:"limits"`
+}
+
+// LLMLimitsConfig 模型限流排队配置，限额本身在模型 extra_config.limits 中设置
+type LLMLimitsConfig struct {
+	MaxWaitMs    int `mapstructure:"max_wait_ms"`   // 超出限额时最长排队等待时间
+	QueueSize    int `mapstructure:"queue_size"`    // 单个实例对同一模型最多排队的请求数，超出直接返回限流
+	LeaseSeconds int `mapstructure:"lease_seconds"` // 并发名额的最长占用时间，实例异常退出后名额在此之后释放
+}
+
+// LLMBatchConfig 批量任务与 worker 配置
+type LLMBatchConfig struct {
+	MaxItems          int `mapstructure:"max_items"`           // 单个批次最多任务数
+	Concurrency       int `mapstructure:"concurrency"`         // 单个 worker 同时执行的任务数
+	ModelConcurrency  int `mapstructure:"model_concurrency"`   // 单个 worker 对同一模型（或同一用途）同时执行的任务数
+	MaxAttempts       int `mapstructure:"max_attempts"`        // 限流、超时等可重试错误的最多执行次数
+	PollIntervalMs    int `mapstructure:"poll_interval_ms"`    // 空闲时拉取任务与续租的间隔
+	JobTimeoutSeconds int `mapstructure:"job_timeout_seconds"` // 单个任务的执行超时
+	LeaseSeconds      int `mapstructure:"lease_seconds"`       // 执行租约时长，worker 退出后超过该时长任务被重新领取
+}
+
+// LLMContextConfig 上下文窗口检查配置
+type LLMContextConfig struct {
+	Overflow string `mapstructure:"overflow"` // 输入超出模型上下文窗口时：reject 拒绝 / trim 从最早的历史消息开始丢弃
+}
+
+// LLMImagesConfig 对话图片输入配置
+type LLMImagesConfig struct {
+	Mode           string `mapstructure:"mode"`             // resource_id 的解析方式：base64 内联 / url 存储地址（需服务商可访问）
+	MaxInlineBytes int64  `mapstructure:"max_inline_bytes"` // 内联图片的最大字节数
+}
+
+// LLMToolsConfig 服务端工具调用配置
+type LLMToolsConfig struct {
+	MaxRounds int `mapstructure:"max_rounds"` // 单次对话最多执行几轮工具调用
+}
+
+// LLMStructuredConfig 结构化输出配置
+type LLMStructuredConfig struct {
+	MaxRepairs int `mapstructure:"max_repairs"` // 输出不符合 json_schema 时最多要求模型修正的次数
+}
+
+// LLMCacheConfig 响应缓存配置
+type LLMCacheConfig struct {
+	Enable     bool `mapstructure:"enable"`
+	TTLSeconds int  `mapstructure:"ttl_seconds"`
+}
+
+// LLMRoutingConfig 同用途多模型的流量分配配置
+type LLMRoutingConfig struct {
+	Sticky         bool     `mapstructure:"sticky"`          // 所有用途按用户固定分配模型
+	StickyPurposes []string `mapstructure:"sticky_purposes"` // 仅对这些用途按用户固定分配
+}
+
+// LLMHealthConfig 模型健康检查配置
+type LLMHealthConfig struct {
+	Enable           bool `mapstructure:"enable"`
+	IntervalSeconds  int  `mapstructure:"interval_seconds"`  // 探测间隔
+	TimeoutSeconds   int  `mapstructure:"timeout_seconds"`   // 单次探测超时
+	FailureThreshold int  `mapstructure:"failure_threshold"` // 连续失败多少次标记为不健康
+}
+
+// LLMCaptureConfig 调用内容采样配置
+type LLMCaptureConfig struct {
+	Enable        bool               `mapstructure:"enable"`
+	SampleRate    float64            `mapstructure:"sample_rate"`    // 默认采样率 0~1
+	Purposes      map[string]float64 `mapstructure:"purposes"`       // 按用途覆盖采样率；模型 extra_config.capture 优先级最高
+	RetentionDays int                `mapstructure:"retention_days"` // 保留天数
+	Redact        bool               `mapstructure:"redact"`         // 是否脱敏邮箱、手机号
+}
+
+// LLMQuotaConfig 用户 Token 配额配置
+type LLMQuotaConfig struct {
+	Enable           bool                     `mapstructure:"enable"`
+	DailyTokens      int64                    `mapstructure:"daily_tokens"`      // 每日额度，0 表示不限
+	MonthlyTokens    int64                    `mapstructure:"monthly_tokens"`    // 每月额度，0 表示不限
+	ReconcileSeconds int                      `mapstructure:"reconcile_seconds"` // Redis 计数与调用日志对账间隔
+	Roles            map[string]LLMQuotaLimit `mapstructure:"roles"`             // 按用户角色覆盖默认额度
+}
+
+// LLMQuotaLimit 单个角色的额度，配置后整体覆盖默认值
+type LLMQuotaLimit struct {
+	DailyTokens   int64 `mapstructure:"daily_tokens"`
+	MonthlyTokens int64 `mapstructure:"monthly_tokens"`
+}
+
+// LLMConversationConfig 多轮对话配置
+type LLMConversationConfig struct {
+	ContextTokens int `mapstructure:"context_tokens"` // 上下文预算（含回复），超出时从最早的历史开始截断
+}
+
+// LLMSubjectExtractConfig 主体提取配置
+type LLMSubjectExtractConfig struct {
+	ChunkChars int `mapstructure:"chunk_chars"` // 单段最大字符数
+}
+
+// LLMChapterParseConfig 章节解析配置
+type LLMChapterParseConfig struct {
+	ChunkChars     int `mapstructure:"chunk_chars"`     // 无法确定模型上下文窗口与回复上限时的单段最大字符数
+	TimeoutSeconds int `mapstructure:"timeout_seconds"` // 单次解析任务总超时
+	Concurrency    int `mapstructure:"concurrency"`     // 同时运行的解析任务数
+}
+
+// LLMSecretConfig 模型API密钥加密配置
+type LLMSecretConfig struct {
+	MasterKey    string   `mapstructure:"master_key"`    // 当前主密钥（base64编码的32字节），可用 MJ_LLM_SECRET_MASTER_KEY 注入
+	PreviousKeys []string `mapstructure:"previous_keys"` // 轮换前的历史主密钥，仅用于解密
 }
 
 // LLMModelConfig 单个模型配置
 type LLMModelConfig struct {
-	BaseURL     string  `mapstructure:"base_url"`
-	APIKey      string  `mapstructure:"api_key"`
-	Model       string  `mapstructure:"model"`
-	MaxTokens   int     `mapstructure:"max_tokens"`
-	Temperature float32 `mapstructure:"temperature"`
-	Timeout     int     `mapstructure:"timeout"`
+	BaseURL          string         `mapstructure:"base_url"`
+	APIKey           string         `mapstructure:"api_key"`
+	Model            string         `mapstructure:"model"`
+	MaxTokens        int            `mapstructure:"max_tokens"`
+	ContextWindow    int            `mapstructure:"context_window"` // 上下文窗口（输入+输出），0 表示不检查
+	Temperature      float32        `mapstructure:"temperature"`
+	Timeout          int            `mapstructure:"timeout"`
+	Retry            LLMRetryConfig `mapstructure:"retry"`
+	InputPrice       float64        `mapstructure:"input_price"`        // 输入单价（每1K Token）
+	OutputPrice      float64        `mapstructure:"output_price"`       // 输出单价（每1K Token）
+	CachedInputPrice float64        `mapstructure:"cached_input_price"` // 缓存命中输入单价（每1K Token）
+	Currency         string         `mapstructure:"currency"`           // 币种
+}
+
+// LLMRetryConfig LLM 调用重试配置
+type LLMRetryConfig struct {
+	MaxAttempts   int     `mapstructure:"max_attempts"`
+	BaseBackoffMs int     `mapstructure:"base_backoff_ms"`
+	MaxBackoffMs  int     `mapstructure:"max_backoff_ms"`
+	Jitter        float64 `mapstructure:"jitter"`
 }
 
 // EmailConfig 邮件配置
@@ -223,6 +358,52 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("llm.default.base_url", "https://api.deepseek.com/v1")
 	v.SetDefault("llm.default.model", "deepseek-chat")
 	v.SetDefault("llm.default.max_tokens", 4096)
+	v.SetDefault("llm.default.context_window", 65536)
 	v.SetDefault("llm.default.temperature", 0.7)
 	v.SetDefault("llm.default.timeout", 60)
+	v.SetDefault("llm.default.retry.max_attempts", 3)
+	v.SetDefault("llm.default.retry.base_backoff_ms", 500)
+	v.SetDefault("llm.default.retry.max_backoff_ms", 8000)
+	v.SetDefault("llm.default.retry.jitter", 0.2)
+	v.SetDefault("llm.default.input_price", 0)
+	v.SetDefault("llm.default.output_price", 0)
+	v.SetDefault("llm.default.cached_input_price", 0)
+	v.SetDefault("llm.default.currency", "CNY")
+	v.SetDefault("llm.secret.master_key", "")
+	v.SetDefault("llm.secret.previous_keys", []string{})
+	v.SetDefault("llm.chapter_parse.chunk_chars", 3000)
+	v.SetDefault("llm.chapter_parse.timeout_seconds", 600)
+	v.SetDefault("llm.chapter_parse.concurrency", 2)
+	v.SetDefault("llm.subject_extract.chunk_chars", 6000)
+	v.SetDefault("llm.conversation.context_tokens", 16000)
+	v.SetDefault("llm.quota.enable", false)
+	v.SetDefault("llm.quota.daily_tokens", 200000)
+	v.SetDefault("llm.quota.monthly_tokens", 3000000)
+	v.SetDefault("llm.quota.reconcile_seconds", 300)
+	v.SetDefault("llm.capture.enable", false)
+	v.SetDefault("llm.capture.sample_rate", 0)
+	v.SetDefault("llm.capture.retention_days", 7)
+	v.SetDefault("llm.capture.redact", true)
+	v.SetDefault("llm.health.enable", false)
+	v.SetDefault("llm.health.interval_seconds", 300)
+	v.SetDefault("llm.health.timeout_seconds", 15)
+	v.SetDefault("llm.health.failure_threshold", 3)
+	v.SetDefault("llm.routing.sticky", false)
+	v.SetDefault("llm.cache.enable", false)
+	v.SetDefault("llm.cache.ttl_seconds", 86400)
+	v.SetDefault("llm.structured.max_repairs", 2)
+	v.SetDefault("llm.tools.max_rounds", 5)
+	v.SetDefault("llm.images.mode", "base64")
+	v.SetDefault("llm.images.max_inline_bytes", 5242880)
+	v.SetDefault("llm.context.overflow", "reject")
+	v.SetDefault("llm.batch.max_items", 1000)
+	v.SetDefault("llm.batch.concurrency", 8)
+	v.SetDefault("llm.batch.model_concurrency", 4)
+	v.SetDefault("llm.batch.max_attempts", 3)
+	v.SetDefault("llm.batch.poll_interval_ms", 1000)
+	v.SetDefault("llm.batch.job_timeout_seconds", 600)
+	v.SetDefault("llm.batch.lease_seconds", 60)
+	v.SetDefault("llm.limits.max_wait_ms", 30000)
+	v.SetDefault("llm.limits.queue_size", 100)
+	v.SetDefault("llm.limits.lease_seconds", 600)
 }
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"manjing-ai-go/internal/repository"
@@ -11,12 +12,13 @@ import (
 
 // ChapterHandler 章节处理器
 type ChapterHandler struct {
-	svc service.ChapterService
+	svc      service.ChapterService
+	parseSvc service.ChapterParseService
 }
 
 // NewChapterHandler 创建处理器
-func NewChapterHandler(svc service.ChapterService) *ChapterHandler {
-	return &ChapterHandler{svc: svc}
+func NewChapterHandler(svc service.ChapterService, parseSvc service.ChapterParseService) *ChapterHandler {
+	return &ChapterHandler{svc: svc, parseSvc: parseSvc}
 }
 
 // CreateChapterReq 创建章节请求
@@ -285,6 +287,9 @@ func mapChapterErr(err error) int {
 	if err == nil {
 		return 0
 	}
+	if errors.Is(err, service.ErrChapterParseBusy) {
+		return 42903
+	}
 	switch err.Error() {
 	case "未授权", "无权访问":
 		return 40301
@@ -292,6 +297,8 @@ func mapChapterErr(err error) int {
 		return 40402
 	case "章节不存在":
 		return 40401
+	case "解析任务不存在":
+		return 40403
 	default:
 		return 40001
 	}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"manjing-ai-go/internal/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Parse 发起章节解析
+// @Summary 发起章节解析（后台任务）
+// @Description 使用 chapter_parse 用途的模型将章节拆分为场景、对白与旁白；同一章节已有进行中的任务时返回该任务；运行中的解析任务数达到 llm.chapter_parse.concurrency 时返回 42903
+// @Tags Chapter
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "章节ID"
+// @Success 200 {object} Resp
+// @Router /v1/chapters/{id}/parse [post]
+func (h *ChapterHandler) Parse(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	p, err := h.parseSvc.StartParse(c.Request.Context(), userID, id)
+	if err != nil {
+		fail(c, mapChapterErr(err), err.Error())
+		return
+	}
+	ok(c, chapterParseToMap(p))
+}
+
+// ParseResult 查询章节解析结果
+// @Summary 查询章节最近一次解析的状态与结果
+// @Tags Chapter
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "章节ID"
+// @Success 200 {object} Resp
+// @Router /v1/chapters/{id}/parse [get]
+func (h *ChapterHandler) ParseResult(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	p, err := h.parseSvc.GetParse(c.Request.Context(), userID, id)
+	if err != nil {
+		fail(c, mapChapterErr(err), err.Error())
+		return
+	}
+	ok(c, chapterParseToMap(p))
+}
+
+func chapterParseToMap(p *model.ChapterParse) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            p.ID,
+		"chapter_id":    p.ChapterID,
+		"status":        p.Status,
+		"total_chunks":  p.TotalChunks,
+		"done_chunks":   p.DoneChunks,
+		"content_hash":  p.ContentHash,
+		"result":        p.Result,
+		"error_message": p.ErrorMessage,
+		"started_at":    p.StartedAt,
+		"finished_at":   p.FinishedAt,
+		"created_at":    p.CreatedAt,
+		"updated_at":    p.UpdatedAt,
+	}
+}
@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LLMBatchHandler 批量任务处理器
+type LLMBatchHandler struct {
+	svc service.LLMBatchService
+}
+
+// NewLLMBatchHandler 创建处理器
+func NewLLMBatchHandler(svc service.LLMBatchService) *LLMBatchHandler {
+	return &LLMBatchHandler{svc: svc}
+}
+
+// SubmitBatchReq 提交批量任务请求
+type SubmitBatchReq struct {
+	Name  string    `json:"name"`  // 批次名称（可选）
+	Items []ChatReq `json:"items"` // 任务列表，每项与 POST /v1/llm/chat 请求体一致（stream 无效）
+}
+
+// Submit 提交批量任务
+// @Summary 提交LLM批量任务
+// @Description 任务由 worker 进程异步执行，通过批次详情轮询进度，通过任务明细获取结果
+// @Tags LLM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body SubmitBatchReq true "批量任务"
+// @Success 201 {object} Resp
+// @Router /v1/llm/batches [post]
+func (h *LLMBatchHandler) Submit(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	var req SubmitBatchReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	items := make([]service.LLMChatRequest, 0, len(req.Items))
+	for _, item := range req.Items {
+		items = append(items, item.toService())
+	}
+	batch, progress, err := h.svc.Submit(c.Request.Context(), userID, service.LLMBatchSubmit{Name: req.Name, Items: items})
+	if err != nil {
+		fail(c, mapBatchErr(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, Resp{
+		Code:    0,
+		Message: "success",
+		Data:    batchToMap(batch, *progress),
+	})
+}
+
+// List 批次列表
+// @Summary LLM批量任务列表
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} Resp
+// @Router /v1/llm/batches [get]
+func (h *LLMBatchHandler) List(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	query := repository.LLMBatchListQuery{
+		Page:     parseIntDef(c.Query("page"), 1),
+		PageSize: parseIntDef(c.Query("page_size"), 20),
+	}
+	items, progress, total, err := h.svc.List(c.Request.Context(), userID, query)
+	if err != nil {
+		fail(c, mapBatchErr(err), err.Error())
+		return
+	}
+	list := make([]map[string]interface{}, 0, len(items))
+	for i := range items {
+		list = append(list, batchToMap(&items[i], progress[items[i].ID]))
+	}
+	ok(c, map[string]interface{}{
+		"items": list,
+		"pagination": map[string]interface{}{
+			"page":        query.Page,
+			"page_size":   query.PageSize,
+			"total":       total,
+			"total_pages": (total + int64(query.PageSize) - 1) / int64(query.PageSize),
+		},
+	})
+}
+
+// Detail 批次详情与进度
+// @Summary LLM批量任务详情
+// @Description status: pending 排队 / running 执行中 / completed 全部结束 / canceled 已取消
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "批次ID"
+// @Success 200 {object} Resp
+// @Router /v1/llm/batches/{id} [get]
+func (h *LLMBatchHandler) Detail(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	batch, progress, err := h.svc.Get(c.Request.Context(), userID, id)
+	if err != nil {
+		fail(c, mapBatchErr(err), err.Error())
+		return
+	}
+	ok(c, batchToMap(batch, *progress))
+}
+
+// Cancel 取消批次
+// @Summary 取消LLM批量任务
+// @Description 排队与执行中的任务标记为已取消，已完成的结果保留
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "批次ID"
+// @Success 200 {object} Resp
+// @Router /v1/llm/batches/{id}/cancel [post]
+func (h *LLMBatchHandler) Cancel(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	n, err := h.svc.Cancel(c.Request.Context(), userID, id)
+	if err != nil {
+		fail(c, mapBatchErr(err), err.Error())
+		return
+	}
+	ok(c, map[string]interface{}{"canceled": n})
+}
+
+// Jobs 任务明细与结果
+// @Summary LLM批量任务明细
+// @Description 按提交顺序返回，成功任务的 result 与 POST /v1/llm/chat 响应数据一致
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "批次ID"
+// @Param status query int false "状态：1排队/2执行中/3成功/4失败/5已取消"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Success 200 {object} Resp
+// @Router /v1/llm/batches/{id}/jobs [get]
+func (h *LLMBatchHandler) Jobs(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	query := repository.LLMJobListQuery{
+		Page:     parseIntDef(c.Query("page"), 1),
+		PageSize: parseIntDef(c.Query("page_size"), 20),
+	}
+	if v := c.Query("status"); v != "" {
+		status, err := strconv.ParseInt(v, 10, 16)
+		if err != nil {
+			fail(c, 40001, "参数错误")
+			return
+		}
+		s := int16(status)
+		query.Status = &s
+	}
+	items, total, err := h.svc.ListJobs(c.Request.Context(), userID, id, query)
+	if err != nil {
+		fail(c, mapBatchErr(err), err.Error())
+		return
+	}
+	if items == nil {
+		items = []model.LLMJob{}
+	}
+	ok(c, map[string]interface{}{
+		"items": items,
+		"pagination": map[string]interface{}{
+			"page":        query.Page,
+			"page_size":   query.PageSize,
+			"total":       total,
+			"total_pages": (total + int64(query.PageSize) - 1) / int64(query.PageSize),
+		},
+	})
+}
+
+// Retry 重试批次内全部失败任务
+// @Summary 重试LLM批量任务中失败的任务
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "批次ID"
+// @Success 200 {object} Resp
+// @Router /v1/llm/batches/{id}/retry [post]
+func (h *LLMBatchHandler) Retry(c *gin.Context) {
+	h.retry(c, false)
+}
+
+// RetryJob 重试单个失败任务
+// @Summary 重试LLM批量任务中的单个失败任务
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "批次ID"
+// @Param job_id path int true "任务ID"
+// @Success 200 {object} Resp
+// @Router /v1/llm/batches/{id}/jobs/{job_id}/retry [post]
+func (h *LLMBatchHandler) RetryJob(c *gin.Context) {
+	h.retry(c, true)
+}
+
+func (h *LLMBatchHandler) retry(c *gin.Context, single bool) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	var jobID *int64
+	if single {
+		v, err := parseID(c.Param("job_id"))
+		if err != nil {
+			fail(c, 40001, "参数错误")
+			return
+		}
+		jobID = &v
+	}
+	n, err := h.svc.Retry(c.Request.Context(), userID, id, jobID)
+	if err != nil {
+		fail(c, mapBatchErr(err), err.Error())
+		return
+	}
+	ok(c, map[string]interface{}{"retried": n})
+}
+
+// batchStatus 由取消标记与任务进度得出批次状态
+func batchStatus(b *model.LLMBatch, p model.LLMBatchProgress) string {
+	switch {
+	case b.CanceledAt != nil && p.Done():
+		return "canceled"
+	case p.Done():
+		return "completed"
+	case p.Running == 0 && p.Success+p.Failed+p.Canceled == 0:
+		return "pending"
+	default:
+		return "running"
+	}
+}
+
+func batchToMap(b *model.LLMBatch, p model.LLMBatchProgress) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          b.ID,
+		"name":        b.Name,
+		"status":      batchStatus(b, p),
+		"total_jobs":  b.TotalJobs,
+		"progress":    p,
+		"canceled_at": b.CanceledAt,
+		"created_at":  b.CreatedAt,
+		"updated_at":  b.UpdatedAt,
+	}
+}
+
+func mapBatchErr(err error) int {
+	if err == nil {
+		return 0
+	}
+	if errors.Is(err, service.ErrBatchNotFound) {
+		return 40405
+	}
+	if err.Error() == "任务不存在或不是失败状态" {
+		return 40405
+	}
+	return mapLLMErr(err)
+}
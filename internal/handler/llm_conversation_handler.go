@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"net/http"
+
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LLMConversationHandler 多轮对话会话处理器
+type LLMConversationHandler struct {
+	svc service.LLMConversationService
+}
+
+// NewLLMConversationHandler 创建处理器
+func NewLLMConversationHandler(svc service.LLMConversationService) *LLMConversationHandler {
+	return &LLMConversationHandler{svc: svc}
+}
+
+// CreateConversationReq 创建会话请求
+type CreateConversationReq struct {
+	Title        string `json:"title"`         // 标题（可选，默认取首条消息）
+	Purpose      string `json:"purpose"`       // 用途（可选，默认 default）
+	ModelID      *int64 `json:"model_id"`      // 固定模型配置ID（可选）
+	ProjectID    *int64 `json:"project_id"`    // 关联项目ID（可选）
+	ChapterID    *int64 `json:"chapter_id"`    // 关联章节ID（可选）
+	SystemPrompt string `json:"system_prompt"` // 系统提示词（可选）
+}
+
+// SendMessageReq 发送消息请求
+type SendMessageReq struct {
+	Content        string   `json:"content"`         // 用户消息（必填）
+	ResponseFormat string   `json:"response_format"` // 返回格式: text/json（可选）
+	MaxTokens      *int     `json:"max_tokens"`      // 最大输出Token（可选）
+	Temperature    *float32 `json:"temperature"`     // 温度（可选）
+}
+
+// Create 创建会话
+// @Summary 创建多轮对话会话
+// @Tags LLM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body CreateConversationReq true "创建会话"
+// @Success 201 {object} Resp
+// @Router /v1/llm/conversations [post]
+func (h *LLMConversationHandler) Create(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	var req CreateConversationReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	conv, err := h.svc.Create(c.Request.Context(), userID, service.LLMConversationCreate{
+		Title:        req.Title,
+		Purpose:      req.Purpose,
+		ModelID:      req.ModelID,
+		ProjectID:    req.ProjectID,
+		ChapterID:    req.ChapterID,
+		SystemPrompt: req.SystemPrompt,
+	})
+	if err != nil {
+		fail(c, mapConversationErr(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, Resp{
+		Code:    0,
+		Message: "success",
+		Data:    conversationToMap(conv),
+	})
+}
+
+// List 会话列表
+// @Summary 会话列表
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Param project_id query int false "项目ID"
+// @Param chapter_id query int false "章节ID"
+// @Success 200 {object} Resp
+// @Router /v1/llm/conversations [get]
+func (h *LLMConversationHandler) List(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	query := repository.LLMConversationListQuery{
+		Page:     parseIntDef(c.Query("page"), 1),
+		PageSize: parseIntDef(c.Query("page_size"), 20),
+	}
+	if v := c.Query("project_id"); v != "" {
+		id, err := parseID(v)
+		if err != nil {
+			fail(c, 40001, "参数错误")
+			return
+		}
+		query.ProjectID = &id
+	}
+	if v := c.Query("chapter_id"); v != "" {
+		id, err := parseID(v)
+		if err != nil {
+			fail(c, 40001, "参数错误")
+			return
+		}
+		query.ChapterID = &id
+	}
+	items, total, err := h.svc.List(c.Request.Context(), userID, query)
+	if err != nil {
+		fail(c, mapConversationErr(err), err.Error())
+		return
+	}
+	list := make([]map[string]interface{}, 0, len(items))
+	for i := range items {
+		list = append(list, conversationToMap(&items[i]))
+	}
+	ok(c, map[string]interface{}{
+		"items": list,
+		"pagination": map[string]interface{}{
+			"page":        query.Page,
+			"page_size":   query.PageSize,
+			"total":       total,
+			"total_pages": (total + int64(query.PageSize) - 1) / int64(query.PageSize),
+		},
+	})
+}
+
+// Detail 会话详情（含消息）
+// @Summary 会话详情
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "会话ID"
+// @Success 200 {object} Resp
+// @Router /v1/llm/conversations/{id} [get]
+func (h *LLMConversationHandler) Detail(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	conv, msgs, err := h.svc.Get(c.Request.Context(), userID, id)
+	if err != nil {
+		fail(c, mapConversationErr(err), err.Error())
+		return
+	}
+	data := conversationToMap(conv)
+	if msgs == nil {
+		msgs = []model.LLMMessage{}
+	}
+	data["messages"] = msgs
+	ok(c, data)
+}
+
+// Delete 删除会话
+// @Summary 删除会话
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "会话ID"
+// @Success 204 {object} Resp
+// @Router /v1/llm/conversations/{id} [delete]
+func (h *LLMConversationHandler) Delete(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), userID, id); err != nil {
+		fail(c, mapConversationErr(err), err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Send 发送消息
+// @Summary 追加用户消息并获取回复
+// @Description 历史消息超出上下文预算时从最早的消息开始截断，truncated 为本轮未发送的历史条数
+// @Tags LLM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "会话ID"
+// @Param body body SendMessageReq true "消息"
+// @Success 200 {object} Resp
+// @Router /v1/llm/conversations/{id}/messages [post]
+func (h *LLMConversationHandler) Send(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	var req SendMessageReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	reply, err := h.svc.Send(c.Request.Context(), userID, id, service.LLMConversationSend{
+		Content:        req.Content,
+		ResponseFormat: req.ResponseFormat,
+		MaxTokens:      req.MaxTokens,
+		Temperature:    req.Temperature,
+	})
+	if err != nil {
+		fail(c, mapConversationErr(err), err.Error())
+		return
+	}
+	ok(c, map[string]interface{}{
+		"user_message":      reply.UserMessage,
+		"assistant_message": reply.AssistantMessage,
+		"model":             reply.Model,
+		"provider":          reply.Provider,
+		"usage":             reply.Usage,
+		"duration_ms":       reply.DurationMs,
+		"truncated":         reply.TruncatedCount,
+	})
+}
+
+func conversationToMap(conv *model.LLMConversation) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            conv.ID,
+		"title":         conv.Title,
+		"purpose":       conv.Purpose,
+		"model_id":      conv.ModelID,
+		"project_id":    conv.ProjectID,
+		"chapter_id":    conv.ChapterID,
+		"system_prompt": conv.SystemPrompt,
+		"message_count": conv.MessageCount,
+		"created_at":    conv.CreatedAt,
+		"updated_at":    conv.UpdatedAt,
+	}
+}
+
+func mapConversationErr(err error) int {
+	if err == nil {
+		return 0
+	}
+	switch err.Error() {
+	case "未授权", "无权访问":
+		return 40301
+	case "会话不存在":
+		return 40403
+	case "项目不存在", "章节不存在":
+		return 40404
+	case "消息过长，超出上下文预算":
+		return 41301
+	default:
+		return mapLLMErr(err)
+	}
+}
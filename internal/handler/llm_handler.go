@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"manjing-ai-go/internal/model"
@@ -26,33 +29,49 @@ func NewLLMHandler(svc service.LLMService) *LLMHandler {
 
 // CreateLLMModelReq 创建模型配置请求
 type CreateLLMModelReq struct {
-	Name        string  `json:"name"`        // 显示名称（必填）
-	Provider    string  `json:"provider"`    // 服务商标识（必填）
-	BaseURL     string  `json:"base_url"`    // API端点（必填）
-	APIKey      string  `json:"api_key"`     // API密钥（必填）
-	Model       string  `json:"model"`       // 模型标识（必填）
-	MaxTokens   int     `json:"max_tokens"`  // 最大输出Token
-	Temperature float64 `json:"temperature"` // 温度参数
-	Timeout     int     `json:"timeout"`     // 超时时间
-	Purpose     string  `json:"purpose"`     // 用途
+	Name             string          `json:"name"`                              // 显示名称（必填）
+	Provider         string          `json:"provider"`                          // 服务商标识（必填），anthropic 使用 Messages API，其余按 OpenAI 兼容协议
+	BaseURL          string          `json:"base_url"`                          // API端点（必填）
+	APIKey           string          `json:"api_key"`                           // API密钥（必填）
+	Model            string          `json:"model"`                             // 模型标识（必填）
+	MaxTokens        int             `json:"max_tokens"`                        // 最大输出Token
+	ContextWindow    int             `json:"context_window"`                    // 上下文窗口（输入+输出Token上限），0 表示不检查
+	Temperature      float64         `json:"temperature"`                       // 温度参数
+	Timeout          int             `json:"timeout"`                           // 超时时间
+	Purpose          string          `json:"purpose"`                           // 用途
+	Priority         int             `json:"priority"`                          // 同用途降级顺序，越小越优先
+	Weight           *int            `json:"weight"`                            // 同优先级内的流量权重（默认100，0 表示只作为降级备选）
+	ExtraConfig      json.RawMessage `json:"extra_config" swaggertype:"object"` // 扩展配置（retry: max_attempts/base_backoff_ms/max_backoff_ms/jitter；limits: max_concurrency/rpm/tpm）
+	InputPrice       float64         `json:"input_price"`                       // 输入单价（每1K Token）
+	OutputPrice      float64         `json:"output_price"`                      // 输出单价（每1K Token）
+	CachedInputPrice float64         `json:"cached_input_price"`                // 缓存命中输入单价（每1K Token），0 表示按输入单价计
+	Currency         string          `json:"currency"`                          // 币种（默认 CNY）
 }
 
 // UpdateLLMModelReq 更新模型配置请求
 type UpdateLLMModelReq struct {
-	Name        *string  `json:"name"`
-	Provider    *string  `json:"provider"`
-	BaseURL     *string  `json:"base_url"`
-	APIKey      *string  `json:"api_key"`
-	Model       *string  `json:"model"`
-	MaxTokens   *int     `json:"max_tokens"`
-	Temperature *float64 `json:"temperature"`
-	Timeout     *int     `json:"timeout"`
-	Purpose     *string  `json:"purpose"`
-	IsActive    *bool    `json:"is_active"`
+	Name             *string         `json:"name"`
+	Provider         *string         `json:"provider"`
+	BaseURL          *string         `json:"base_url"`
+	APIKey           *string         `json:"api_key"`
+	Model            *string         `json:"model"`
+	MaxTokens        *int            `json:"max_tokens"`
+	ContextWindow    *int            `json:"context_window"`
+	Temperature      *float64        `json:"temperature"`
+	Timeout          *int            `json:"timeout"`
+	Purpose          *string         `json:"purpose"`
+	Priority         *int            `json:"priority"`
+	Weight           *int            `json:"weight"`
+	IsActive         *bool           `json:"is_active"`
+	ExtraConfig      json.RawMessage `json:"extra_config" swaggertype:"object"`
+	InputPrice       *float64        `json:"input_price"`
+	OutputPrice      *float64        `json:"output_price"`
+	CachedInputPrice *float64        `json:"cached_input_price"`
+	Currency         *string         `json:"currency"`
 }
 
 // CreateModel 创建模型配置
-// @Summary 创建模型配置
+// @Summary 创建模型配置（仅管理员）
 // @Tags LLM
 // @Accept json
 // @Produce json
@@ -67,15 +86,23 @@ func (h *LLMHandler) CreateModel(c *gin.Context) {
 		return
 	}
 	m, err := h.svc.CreateModel(c.Request.Context(), service.LLMModelCreate{
-		Name:        req.Name,
-		Provider:    req.Provider,
-		BaseURL:     req.BaseURL,
-		APIKey:      req.APIKey,
-		Model:       req.Model,
-		MaxTokens:   req.MaxTokens,
-		Temperature: req.Temperature,
-		Timeout:     req.Timeout,
-		Purpose:     req.Purpose,
+		Name:             req.Name,
+		Provider:         req.Provider,
+		BaseURL:          req.BaseURL,
+		APIKey:           req.APIKey,
+		Model:            req.Model,
+		MaxTokens:        req.MaxTokens,
+		ContextWindow:    req.ContextWindow,
+		Temperature:      req.Temperature,
+		Timeout:          req.Timeout,
+		Purpose:          req.Purpose,
+		Priority:         req.Priority,
+		Weight:           req.Weight,
+		ExtraConfig:      req.ExtraConfig,
+		InputPrice:       req.InputPrice,
+		OutputPrice:      req.OutputPrice,
+		CachedInputPrice: req.CachedInputPrice,
+		Currency:         req.Currency,
 	})
 	if err != nil {
 		fail(c, mapLLMErr(err), err.Error())
@@ -89,7 +116,7 @@ func (h *LLMHandler) CreateModel(c *gin.Context) {
 }
 
 // ListModels 模型配置列表
-// @Summary 模型配置列表
+// @Summary 模型配置列表（仅管理员）
 // @Tags LLM
 // @Produce json
 // @Security BearerAuth
@@ -131,7 +158,7 @@ func (h *LLMHandler) ListModels(c *gin.Context) {
 }
 
 // ModelDetail 模型配置详情
-// @Summary 模型配置详情
+// @Summary 模型配置详情（仅管理员）
 // @Tags LLM
 // @Produce json
 // @Security BearerAuth
@@ -153,7 +180,7 @@ func (h *LLMHandler) ModelDetail(c *gin.Context) {
 }
 
 // UpdateModel 更新模型配置
-// @Summary 更新模型配置
+// @Summary 更新模型配置（仅管理员）
 // @Tags LLM
 // @Accept json
 // @Produce json
@@ -174,16 +201,24 @@ func (h *LLMHandler) UpdateModel(c *gin.Context) {
 		return
 	}
 	m, err := h.svc.UpdateModel(c.Request.Context(), id, service.LLMModelUpdate{
-		Name:        req.Name,
-		Provider:    req.Provider,
-		BaseURL:     req.BaseURL,
-		APIKey:      req.APIKey,
-		Model:       req.Model,
-		MaxTokens:   req.MaxTokens,
-		Temperature: req.Temperature,
-		Timeout:     req.Timeout,
-		Purpose:     req.Purpose,
-		IsActive:    req.IsActive,
+		Name:             req.Name,
+		Provider:         req.Provider,
+		BaseURL:          req.BaseURL,
+		APIKey:           req.APIKey,
+		Model:            req.Model,
+		MaxTokens:        req.MaxTokens,
+		ContextWindow:    req.ContextWindow,
+		Temperature:      req.Temperature,
+		Timeout:          req.Timeout,
+		Purpose:          req.Purpose,
+		Priority:         req.Priority,
+		Weight:           req.Weight,
+		IsActive:         req.IsActive,
+		ExtraConfig:      req.ExtraConfig,
+		InputPrice:       req.InputPrice,
+		OutputPrice:      req.OutputPrice,
+		CachedInputPrice: req.CachedInputPrice,
+		Currency:         req.Currency,
 	})
 	if err != nil {
 		fail(c, mapLLMErr(err), err.Error())
@@ -193,7 +228,7 @@ func (h *LLMHandler) UpdateModel(c *gin.Context) {
 }
 
 // DeleteModel 删除模型配置
-// @Summary 删除模型配置
+// @Summary 删除模型配置（仅管理员）
 // @Tags LLM
 // @Produce json
 // @Security BearerAuth
@@ -213,24 +248,76 @@ func (h *LLMHandler) DeleteModel(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// TestModel 模型连通性测试
+// @Summary 模型连通性测试（仅管理员）
+// @Description 发送最小探测请求，返回耗时、HTTP状态类别与服务商返回的模型标识，并更新模型健康状态
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "模型配置ID"
+// @Success 200 {object} Resp
+// @Router /v1/llm/models/{id}/test [post]
+func (h *LLMHandler) TestModel(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	result, err := h.svc.TestModel(c.Request.Context(), id)
+	if err != nil {
+		fail(c, mapLLMErr(err), err.Error())
+		return
+	}
+	ok(c, result)
+}
+
 // ======= 对话 =======
 
 // ChatReq 对话请求
 type ChatReq struct {
-	Messages []llm.ChatMessage `json:"messages"` // 消息列表
-	Purpose  string            `json:"purpose"`  // 用途
-	ModelID  *int64            `json:"model_id"` // 指定模型配置ID
-	ResponseFormat string     `json:"response_format"` // text / json
-	MaxTokens      *int       `json:"max_tokens"`
-	Temperature    *float32   `json:"temperature"`
+	Messages       []llm.ChatMessage      `json:"messages"`        // 消息列表；content 可为字符串或内容片段数组，图片片段用 image_url.url 或本人图片资源的 resource_id
+	Purpose        string                 `json:"purpose"`         // 用途
+	ModelID        *int64                 `json:"model_id"`        // 指定模型配置ID
+	ResponseFormat string                 `json:"response_format"` // text / json
+	MaxTokens      *int                   `json:"max_tokens"`
+	Temperature    *float32               `json:"temperature"`
+	Stream         bool                   `json:"stream"`                           // 是否以SSE流式返回
+	PromptID       *int64                 `json:"prompt_id"`                        // 提示词模板ID，已停用的版本不可使用；未提供 messages 时按 purpose 使用最新启用模板
+	Variables      map[string]interface{} `json:"variables"`                        // 模板变量
+	Cache          bool                   `json:"cache"`                            // 温度非0时也使用响应缓存（仅非流式）
+	JSONSchema     json.RawMessage        `json:"json_schema" swaggertype:"object"` // 输出需符合的 JSON Schema（仅非流式），不符合时自动要求模型修正
+	Tools          []string               `json:"tools"`                            // 允许模型调用的服务端工具（仅非流式），见 GET /v1/llm/tools
+	Overflow       string                 `json:"overflow"`                         // 输入超出模型上下文窗口时：reject 返回 41301 / trim 丢弃最早的历史消息，为空按服务端配置
+}
+
+// toService 转换为服务层对话请求
+func (r ChatReq) toService() service.LLMChatRequest {
+	return service.LLMChatRequest{
+		Messages:       r.Messages,
+		Purpose:        r.Purpose,
+		ModelID:        r.ModelID,
+		ResponseFormat: r.ResponseFormat,
+		MaxTokens:      r.MaxTokens,
+		Temperature:    r.Temperature,
+		PromptID:       r.PromptID,
+		Variables:      r.Variables,
+		Cache:          r.Cache,
+		JSONSchema:     r.JSONSchema,
+		Tools:          r.Tools,
+		Overflow:       r.Overflow,
+	}
 }
 
 // Chat 发送对话请求
 // @Summary 发送对话请求
+// @Description stream=true 或 Accept: text/event-stream 时以SSE返回：delta 事件携带增量内容，done 事件携带完整结果，error 事件携带错误
+// @Description 多模态消息：content 传数组，如 [{"type":"text","text":"描述这张图"},{"type":"image_url","resource_id":12}]，resource_id 须为本人上传的图片资源
 // @Tags LLM
 // @Accept json
 // @Produce json
+// @Produce text/event-stream
 // @Security BearerAuth
+// @Param stream query bool false "是否流式返回"
 // @Param body body ChatReq true "对话请求"
 // @Success 200 {object} Resp
 // @Router /v1/llm/chat [post]
@@ -241,14 +328,12 @@ func (h *LLMHandler) Chat(c *gin.Context) {
 		fail(c, 40001, "参数错误")
 		return
 	}
-	resp, err := h.svc.Chat(c.Request.Context(), userID, service.LLMChatRequest{
-		Messages:       req.Messages,
-		Purpose:        req.Purpose,
-		ModelID:        req.ModelID,
-		ResponseFormat: req.ResponseFormat,
-		MaxTokens:      req.MaxTokens,
-		Temperature:    req.Temperature,
-	})
+	chatReq := req.toService()
+	if req.Stream || c.Query("stream") == "true" || strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		h.chatStream(c, userID, chatReq)
+		return
+	}
+	resp, err := h.svc.Chat(c.Request.Context(), userID, chatReq)
 	if err != nil {
 		fail(c, mapLLMErr(err), err.Error())
 		return
@@ -256,10 +341,117 @@ func (h *LLMHandler) Chat(c *gin.Context) {
 	ok(c, resp)
 }
 
+// chatStream 以SSE转发增量内容；首个分片到达前出错时仍按普通JSON返回
+func (h *LLMHandler) chatStream(c *gin.Context, userID int64, req service.LLMChatRequest) {
+	started := false
+	resp, err := h.svc.ChatStream(c.Request.Context(), userID, req, func(delta string) error {
+		if !started {
+			started = true
+			setSSEHeaders(c)
+		}
+		c.SSEvent("delta", map[string]interface{}{"content": delta})
+		c.Writer.Flush()
+		return c.Request.Context().Err()
+	})
+	if err != nil {
+		if !started {
+			fail(c, mapLLMErr(err), err.Error())
+			return
+		}
+		if c.Request.Context().Err() == nil {
+			c.SSEvent("error", map[string]interface{}{"code": mapLLMErr(err), "message": err.Error()})
+			c.Writer.Flush()
+		}
+		return
+	}
+	if !started {
+		setSSEHeaders(c)
+	}
+	c.SSEvent("done", resp)
+	c.Writer.Flush()
+}
+
+// TokenizeReq Token 估算请求
+type TokenizeReq struct {
+	Text      string            `json:"text"`       // 待估算文本，与 messages 二选一
+	Messages  []llm.ChatMessage `json:"messages"`   // 待估算消息列表
+	ModelID   *int64            `json:"model_id"`   // 按指定模型估算
+	Purpose   string            `json:"purpose"`    // 未指定 model_id 时按该用途的首选模型估算
+	MaxTokens *int              `json:"max_tokens"` // 回复预留，默认取模型配置
+}
+
+// Tokenize 估算 Token 数
+// @Summary 估算文本或消息的 Token 数
+// @Description 离线估算，不调用模型，用于编辑器实时显示章节 Token 数及是否超出模型上下文窗口
+// @Tags LLM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body TokenizeReq true "估算请求"
+// @Success 200 {object} Resp
+// @Router /v1/llm/tokenize [post]
+func (h *LLMHandler) Tokenize(c *gin.Context) {
+	var req TokenizeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	result, err := h.svc.Tokenize(c.Request.Context(), service.LLMTokenizeRequest{
+		Text:      req.Text,
+		Messages:  req.Messages,
+		ModelID:   req.ModelID,
+		Purpose:   req.Purpose,
+		MaxTokens: req.MaxTokens,
+	})
+	if err != nil {
+		fail(c, mapLLMErr(err), err.Error())
+		return
+	}
+	ok(c, result)
+}
+
+// ListTools 可用的服务端工具
+// @Summary 可用的服务端工具
+// @Description 对话请求的 tools 字段填写工具名称后，模型可在回答前调用这些工具读取项目数据
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Resp
+// @Router /v1/llm/tools [get]
+func (h *LLMHandler) ListTools(c *gin.Context) {
+	tools := h.svc.Tools()
+	list := make([]map[string]interface{}, 0, len(tools))
+	for _, t := range tools {
+		list = append(list, map[string]interface{}{
+			"name":        t.Name,
+			"description": t.Description,
+			"parameters":  t.Parameters,
+		})
+	}
+	ok(c, map[string]interface{}{"items": list})
+}
+
+// PurgeCache 清空响应缓存
+// @Summary 清空LLM响应缓存（仅管理员）
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Resp
+// @Router /v1/llm/cache [delete]
+func (h *LLMHandler) PurgeCache(c *gin.Context) {
+	deleted, err := h.svc.PurgeCache(c.Request.Context())
+	if err != nil {
+		fail(c, mapLLMErr(err), err.Error())
+		return
+	}
+	ok(c, map[string]interface{}{"deleted": deleted})
+}
+
 // ======= 调用日志 =======
 
 // ListLogs 调用日志列表
 // @Summary 调用日志列表
+// @Description 非管理员只返回自己的调用记录，user_id 参数仅管理员可用
 // @Tags LLM
 // @Produce json
 // @Security BearerAuth
@@ -268,10 +460,12 @@ func (h *LLMHandler) Chat(c *gin.Context) {
 // @Param purpose query string false "用途"
 // @Param provider query string false "服务商"
 // @Param status query int false "状态"
+// @Param user_id query int false "用户ID"
 // @Param sort query string false "排序"
 // @Success 200 {object} Resp
 // @Router /v1/llm/logs [get]
 func (h *LLMHandler) ListLogs(c *gin.Context) {
+	userID := c.GetInt64("user_id")
 	query := repository.LLMCallLogListQuery{
 		Page:     parseIntDef(c.Query("page"), 1),
 		PageSize: parseIntDef(c.Query("page_size"), 20),
@@ -280,6 +474,11 @@ func (h *LLMHandler) ListLogs(c *gin.Context) {
 		Status:   parseIntDef(c.Query("status"), 0),
 		Sort:     c.Query("sort"),
 	}
+	if c.GetString("role") == model.UserRoleAdmin {
+		query.UserID = int64(parseIntDef(c.Query("user_id"), 0))
+	} else {
+		query.UserID = userID
+	}
 	if st := c.Query("start_time"); st != "" {
 		if t, err := time.Parse(time.RFC3339, st); err == nil {
 			query.StartTime = &t
@@ -307,18 +506,22 @@ func (h *LLMHandler) ListLogs(c *gin.Context) {
 }
 
 // LogStats 用量统计
-// @Summary 用量统计
+// @Summary 用量统计（仅管理员）
+// @Description 费用按币种返回（costs），groups、series 与 top_users 中不同币种的费用不相加；顶层 total_cost 为不区分币种的合计
 // @Tags LLM
 // @Produce json
 // @Security BearerAuth
 // @Param start_time query string false "起始时间"
 // @Param end_time query string false "结束时间"
-// @Param group_by query string false "分组维度"
+// @Param group_by query string false "分组维度: provider/purpose/model/model_id/user_id/status/day/arm，默认 provider"
+// @Param interval query string false "时间序列粒度: hour/day/week，指定后返回 series 与 top_users"
+// @Param split_by query string false "时间序列第二维度: provider/purpose/model/arm"
+// @Param top_n query int false "用量前N的用户，默认10，最大100"
 // @Success 200 {object} Resp
 // @Router /v1/llm/logs/stats [get]
 func (h *LLMHandler) LogStats(c *gin.Context) {
 	query := repository.LLMCallLogStatsQuery{
-		GroupBy: c.DefaultQuery("group_by", "provider"),
+		GroupBy: repository.LLMStatsDimension(c.DefaultQuery("group_by", string(repository.LLMStatsByProvider))),
 	}
 	if st := c.Query("start_time"); st != "" {
 		if t, err := time.Parse(time.RFC3339, st); err == nil {
@@ -330,41 +533,138 @@ func (h *LLMHandler) LogStats(c *gin.Context) {
 			query.EndTime = &t
 		}
 	}
+
+	// 时间序列模式：汇总统计与序列使用同一时间范围
+	var series *repository.LLMCallLogSeries
+	if interval := c.Query("interval"); interval != "" {
+		seriesQuery := repository.LLMCallLogSeriesQuery{
+			Interval: interval,
+			SplitBy:  c.Query("split_by"),
+			TopN:     parseIntDef(c.Query("top_n"), 10),
+		}
+		if query.StartTime != nil {
+			seriesQuery.StartTime = *query.StartTime
+		}
+		if query.EndTime != nil {
+			seriesQuery.EndTime = *query.EndTime
+		}
+		var err error
+		series, err = h.svc.LogSeries(c.Request.Context(), seriesQuery)
+		if err != nil {
+			fail(c, mapLLMErr(err), err.Error())
+			return
+		}
+		query.StartTime = &series.StartTime
+		query.EndTime = &series.EndTime
+	}
+
 	stats, groups, err := h.svc.LogStats(c.Request.Context(), query)
 	if err != nil {
 		fail(c, mapLLMErr(err), err.Error())
 		return
 	}
-	ok(c, map[string]interface{}{
+	data := map[string]interface{}{
 		"total_calls":             stats.TotalCalls,
 		"success_calls":           stats.SuccessCalls,
 		"failed_calls":            stats.FailedCalls,
 		"total_tokens":            stats.TotalTokens,
 		"total_prompt_tokens":     stats.TotalPromptTokens,
 		"total_completion_tokens": stats.TotalCompletionTokens,
+		"total_cached_tokens":     stats.TotalCachedTokens,
 		"avg_duration_ms":         stats.AvgDurationMs,
+		"total_cost":              stats.TotalCost,
+		"costs":                   stats.Costs,
+		"group_by":                query.GroupBy,
 		"groups":                  groups,
-	})
+	}
+	if series != nil {
+		data["start_time"] = series.StartTime
+		data["end_time"] = series.EndTime
+		data["interval"] = series.Interval
+		data["split_by"] = series.SplitBy
+		data["series"] = series.Buckets
+		data["top_users"] = series.TopUsers
+	}
+	ok(c, data)
+}
+
+// LogPayload 调用内容
+// @Summary 查询调用日志的请求与返回内容（仅管理员）
+// @Description 仅在开启 llm.capture 且命中采样时记录，超过保留期后不可查
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "调用日志ID"
+// @Success 200 {object} Resp
+// @Router /v1/llm/logs/{id}/payload [get]
+func (h *LLMHandler) LogPayload(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	payload, err := h.svc.GetPayload(c.Request.Context(), id)
+	if err != nil {
+		fail(c, mapLLMErr(err), err.Error())
+		return
+	}
+	ok(c, payload)
 }
 
 // ======= 辅助函数 =======
 
+func setSSEHeaders(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+}
+
+// UsageMe 我的Token用量
+// @Summary 查询当前用户的Token用量与剩余额度
+// @Description limit 为 0 表示不限，此时 remaining 为 -1
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} Resp
+// @Router /v1/llm/usage/me [get]
+func (h *LLMHandler) UsageMe(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	usage, err := h.svc.Usage(c.Request.Context(), userID)
+	if err != nil {
+		fail(c, mapLLMErr(err), err.Error())
+		return
+	}
+	ok(c, usage)
+}
+
 func llmModelToMap(m *model.LLMModel) map[string]interface{} {
 	return map[string]interface{}{
-		"id":           m.ID,
-		"name":         m.Name,
-		"provider":     m.Provider,
-		"base_url":     m.BaseURL,
-		"api_key":      m.MaskedAPIKey(),
-		"model":        m.Model,
-		"max_tokens":   m.MaxTokens,
-		"temperature":  m.Temperature,
-		"timeout":      m.Timeout,
-		"purpose":      m.Purpose,
-		"is_active":    m.IsActive,
-		"extra_config": m.ExtraConfig,
-		"created_at":   m.CreatedAt,
-		"updated_at":   m.UpdatedAt,
+		"id":                 m.ID,
+		"name":               m.Name,
+		"provider":           m.Provider,
+		"base_url":           m.BaseURL,
+		"api_key":            m.MaskedAPIKey(),
+		"model":              m.Model,
+		"max_tokens":         m.MaxTokens,
+		"context_window":     m.ContextWindow,
+		"temperature":        m.Temperature,
+		"timeout":            m.Timeout,
+		"purpose":            m.Purpose,
+		"priority":           m.Priority,
+		"weight":             m.Weight,
+		"is_active":          m.IsActive,
+		"extra_config":       m.ExtraConfig,
+		"input_price":        m.InputPrice,
+		"output_price":       m.OutputPrice,
+		"cached_input_price": m.CachedInputPrice,
+		"currency":           m.Currency,
+		"health_status":      m.HealthStatus,
+		"last_checked_at":    m.LastCheckedAt,
+		"last_check_error":   m.LastCheckError,
+		"created_at":         m.CreatedAt,
+		"updated_at":         m.UpdatedAt,
 	}
 }
 
@@ -372,17 +672,55 @@ func mapLLMErr(err error) int {
 	if err == nil {
 		return 0
 	}
+	var schemaErr *service.SchemaValidationError
+	if errors.As(err, &schemaErr) {
+		return 50005
+	}
+	if errors.Is(err, service.ErrContextOverflow) {
+		return 41301
+	}
+	if errors.Is(err, service.ErrQuotaExceeded) {
+		return 42902
+	}
+	if errors.Is(err, service.ErrPayloadNotFound) {
+		return 40403
+	}
+	if errors.Is(err, service.ErrPromptInactive) {
+		return 40402
+	}
+	if errors.Is(err, service.ErrMasterKeyNotConfigured) {
+		return 40004
+	}
+	var llmErr *llm.Error
+	if errors.As(err, &llmErr) {
+		switch llmErr.Kind {
+		case llm.ErrKindRateLimited:
+			return 42901
+		case llm.ErrKindTimeout:
+			return 50002
+		case llm.ErrKindAuth:
+			return 50003
+		case llm.ErrKindMalformedResponse:
+			return 50004
+		case llm.ErrKindBadRequest:
+			return 40003
+		case llm.ErrKindContextLength:
+			return 41301
+		default:
+			return 50001
+		}
+	}
 	switch err.Error() {
 	case "无可用模型配置", "指定的模型配置不存在":
 		return 40002
 	case "模型配置不存在":
 		return 40401
-	case "模型限流":
-		return 42901
-	case "调用超时":
-		return 50002
-	case "模型调用失败":
-		return 50001
+	case "提示词模板不存在":
+		return 40402
+	case "图片资源不存在":
+		return 40404
+	case "无权访问图片资源":
+		return 40301
 	default:
 		return 40001
 	}
@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"net/http"
+
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/internal/service"
+	"manjing-ai-go/pkg/llm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateLLMPromptReq 创建提示词模板请求
+type CreateLLMPromptReq struct {
+	Purpose     string            `json:"purpose"`     // 用途（必填），同用途下自动生成新版本
+	Name        string            `json:"name"`        // 模板名称（必填）
+	Description string            `json:"description"` // 模板说明
+	Messages    []llm.ChatMessage `json:"messages"`    // 消息模板（必填），content 支持 Go text/template 变量，如 {{.content}}
+}
+
+// UpdateLLMPromptReq 更新提示词模板请求（模板内容需通过创建新版本修改）
+type UpdateLLMPromptReq struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+// CreatePrompt 创建提示词模板版本
+// @Summary 创建提示词模板版本（仅管理员）
+// @Tags LLM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param body body CreateLLMPromptReq true "创建提示词模板"
+// @Success 201 {object} Resp
+// @Router /v1/llm/prompts [post]
+func (h *LLMHandler) CreatePrompt(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	var req CreateLLMPromptReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	p, err := h.svc.CreatePrompt(c.Request.Context(), userID, service.LLMPromptCreate{
+		Purpose:     req.Purpose,
+		Name:        req.Name,
+		Description: req.Description,
+		Messages:    req.Messages,
+	})
+	if err != nil {
+		fail(c, mapLLMErr(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, Resp{
+		Code:    0,
+		Message: "success",
+		Data:    p,
+	})
+}
+
+// ListPrompts 提示词模板列表
+// @Summary 提示词模板列表
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Param purpose query string false "用途"
+// @Param is_active query bool false "是否启用"
+// @Success 200 {object} Resp
+// @Router /v1/llm/prompts [get]
+func (h *LLMHandler) ListPrompts(c *gin.Context) {
+	query := repository.LLMPromptListQuery{
+		Page:     parseIntDef(c.Query("page"), 1),
+		PageSize: parseIntDef(c.Query("page_size"), 20),
+		Purpose:  c.Query("purpose"),
+	}
+	if isActiveStr := c.Query("is_active"); isActiveStr != "" {
+		v := isActiveStr == "true"
+		query.IsActive = &v
+	}
+	items, total, err := h.svc.ListPrompts(c.Request.Context(), query)
+	if err != nil {
+		fail(c, mapLLMErr(err), err.Error())
+		return
+	}
+	if items == nil {
+		items = []model.LLMPrompt{}
+	}
+	ok(c, map[string]interface{}{
+		"items": items,
+		"pagination": map[string]interface{}{
+			"page":        query.Page,
+			"page_size":   query.PageSize,
+			"total":       total,
+			"total_pages": (total + int64(query.PageSize) - 1) / int64(query.PageSize),
+		},
+	})
+}
+
+// PromptDetail 提示词模板详情
+// @Summary 提示词模板详情
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "模板ID"
+// @Success 200 {object} Resp
+// @Router /v1/llm/prompts/{id} [get]
+func (h *LLMHandler) PromptDetail(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	p, err := h.svc.GetPrompt(c.Request.Context(), id)
+	if err != nil {
+		fail(c, mapLLMErr(err), err.Error())
+		return
+	}
+	ok(c, p)
+}
+
+// UpdatePrompt 更新提示词模板
+// @Summary 更新提示词模板（名称、说明、启用状态）（仅管理员）
+// @Tags LLM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "模板ID"
+// @Param body body UpdateLLMPromptReq true "更新提示词模板"
+// @Success 200 {object} Resp
+// @Router /v1/llm/prompts/{id} [put]
+func (h *LLMHandler) UpdatePrompt(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	var req UpdateLLMPromptReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	p, err := h.svc.UpdatePrompt(c.Request.Context(), id, service.LLMPromptUpdate{
+		Name:        req.Name,
+		Description: req.Description,
+		IsActive:    req.IsActive,
+	})
+	if err != nil {
+		fail(c, mapLLMErr(err), err.Error())
+		return
+	}
+	ok(c, p)
+}
+
+// DeletePrompt 删除提示词模板
+// @Summary 删除提示词模板（仅管理员）
+// @Tags LLM
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "模板ID"
+// @Success 204
+// @Router /v1/llm/prompts/{id} [delete]
+func (h *LLMHandler) DeletePrompt(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	if err := h.svc.DeletePrompt(c.Request.Context(), id); err != nil {
+		fail(c, mapLLMErr(err), err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
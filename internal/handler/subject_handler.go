@@ -0,0 +1,315 @@
+package handler
+
+import (
+	"net/http"
+
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubjectHandler 主体（角色）库处理器
+type SubjectHandler struct {
+	svc service.SubjectService
+}
+
+// NewSubjectHandler 创建处理器
+func NewSubjectHandler(svc service.SubjectService) *SubjectHandler {
+	return &SubjectHandler{svc: svc}
+}
+
+// CreateSubjectReq 创建主体请求
+type CreateSubjectReq struct {
+	Name             string   `json:"name"`              // 主体名称（必填，项目内唯一）
+	Aliases          []string `json:"aliases"`           // 别名（可选）
+	Description      string   `json:"description"`       // 人物描述（可选）
+	AppearancePrompt string   `json:"appearance_prompt"` // 外观提示词（可选）
+	ResourceID       *int64   `json:"resource_id"`       // 参考图资源ID（可选）
+	VoiceID          *int64   `json:"voice_id"`          // 绑定声音ID（可选）
+}
+
+// UpdateSubjectReq 更新主体请求
+type UpdateSubjectReq struct {
+	Name             *string   `json:"name"`              // 主体名称（可选）
+	Aliases          *[]string `json:"aliases"`           // 别名，整体替换（可选）
+	Description      *string   `json:"description"`       // 人物描述（可选）
+	AppearancePrompt *string   `json:"appearance_prompt"` // 外观提示词（可选）
+	ResourceID       *int64    `json:"resource_id"`       // 参考图资源ID（可选，传0解绑）
+	VoiceID          *int64    `json:"voice_id"`          // 绑定声音ID（可选，传0解绑）
+}
+
+// MergeSubjectsReq 合并主体请求
+type MergeSubjectsReq struct {
+	TargetID  int64   `json:"target_id"`  // 保留的主体ID（必填）
+	SourceIDs []int64 `json:"source_ids"` // 并入目标后删除的主体ID（必填）
+}
+
+// Extract 从章节提取主体
+// @Summary 从项目章节提取主体
+// @Description 使用 subject_extract 用途的模型逐章提取人物，按名称与别名去重合并入项目主体库
+// @Tags Subject
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "项目ID"
+// @Success 200 {object} Resp
+// @Router /v1/projects/{id}/subjects/extract [post]
+func (h *SubjectHandler) Extract(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	projectID, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	result, err := h.svc.Extract(c.Request.Context(), userID, projectID)
+	if err != nil {
+		fail(c, mapSubjectErr(err), err.Error())
+		return
+	}
+	items := make([]map[string]interface{}, 0, len(result.Subjects))
+	for i := range result.Subjects {
+		items = append(items, subjectToMap(&result.Subjects[i]))
+	}
+	ok(c, map[string]interface{}{
+		"chapters": result.Chapters,
+		"created":  result.Created,
+		"updated":  result.Updated,
+		"items":    items,
+	})
+}
+
+// Create 创建主体
+// @Summary 创建主体
+// @Tags Subject
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "项目ID"
+// @Param body body CreateSubjectReq true "创建主体"
+// @Success 201 {object} Resp
+// @Router /v1/projects/{id}/subjects [post]
+func (h *SubjectHandler) Create(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	projectID, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	var req CreateSubjectReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	subject, err := h.svc.Create(c.Request.Context(), userID, projectID, service.SubjectCreate{
+		Name:             req.Name,
+		Aliases:          req.Aliases,
+		Description:      req.Description,
+		AppearancePrompt: req.AppearancePrompt,
+		ResourceID:       req.ResourceID,
+		VoiceID:          req.VoiceID,
+	})
+	if err != nil {
+		fail(c, mapSubjectErr(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, Resp{
+		Code:    0,
+		Message: "success",
+		Data:    subjectToMap(subject),
+	})
+}
+
+// List 主体列表
+// @Summary 项目主体列表
+// @Tags Subject
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "项目ID"
+// @Param page query int false "页码"
+// @Param page_size query int false "每页数量"
+// @Param keyword query string false "名称或别名关键字"
+// @Success 200 {object} Resp
+// @Router /v1/projects/{id}/subjects [get]
+func (h *SubjectHandler) List(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	projectID, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	query := repository.SubjectListQuery{
+		Page:     parseIntDef(c.Query("page"), 1),
+		PageSize: parseIntDef(c.Query("page_size"), 20),
+		Keyword:  c.Query("keyword"),
+	}
+	items, total, err := h.svc.List(c.Request.Context(), userID, projectID, query)
+	if err != nil {
+		fail(c, mapSubjectErr(err), err.Error())
+		return
+	}
+	list := make([]map[string]interface{}, 0, len(items))
+	for i := range items {
+		list = append(list, subjectToMap(&items[i]))
+	}
+	ok(c, map[string]interface{}{
+		"items": list,
+		"pagination": map[string]interface{}{
+			"page":        query.Page,
+			"page_size":   query.PageSize,
+			"total":       total,
+			"total_pages": (total + int64(query.PageSize) - 1) / int64(query.PageSize),
+		},
+	})
+}
+
+// Merge 合并主体
+// @Summary 合并主体
+// @Description 来源主体的名称与别名并入目标主体别名，目标缺失的描述、参考图、声音用来源补齐，来源主体被删除
+// @Tags Subject
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "项目ID"
+// @Param body body MergeSubjectsReq true "合并主体"
+// @Success 200 {object} Resp
+// @Router /v1/projects/{id}/subjects/merge [post]
+func (h *SubjectHandler) Merge(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	projectID, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	var req MergeSubjectsReq
+	if err := c.ShouldBindJSON(&req); err != nil || req.TargetID == 0 {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	subject, err := h.svc.Merge(c.Request.Context(), userID, projectID, req.TargetID, req.SourceIDs)
+	if err != nil {
+		fail(c, mapSubjectErr(err), err.Error())
+		return
+	}
+	ok(c, subjectToMap(subject))
+}
+
+// Detail 主体详情
+// @Summary 主体详情
+// @Tags Subject
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "主体ID"
+// @Success 200 {object} Resp
+// @Router /v1/subjects/{id} [get]
+func (h *SubjectHandler) Detail(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	subject, err := h.svc.Get(c.Request.Context(), userID, id)
+	if err != nil {
+		fail(c, mapSubjectErr(err), err.Error())
+		return
+	}
+	ok(c, subjectToMap(subject))
+}
+
+// Update 更新主体
+// @Summary 更新主体
+// @Tags Subject
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "主体ID"
+// @Param body body UpdateSubjectReq true "更新主体"
+// @Success 200 {object} Resp
+// @Router /v1/subjects/{id} [put]
+func (h *SubjectHandler) Update(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	var req UpdateSubjectReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	subject, err := h.svc.Update(c.Request.Context(), userID, id, service.SubjectUpdate{
+		Name:             req.Name,
+		Aliases:          req.Aliases,
+		Description:      req.Description,
+		AppearancePrompt: req.AppearancePrompt,
+		ResourceID:       req.ResourceID,
+		VoiceID:          req.VoiceID,
+	})
+	if err != nil {
+		fail(c, mapSubjectErr(err), err.Error())
+		return
+	}
+	ok(c, subjectToMap(subject))
+}
+
+// Delete 删除主体
+// @Summary 删除主体
+// @Tags Subject
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "主体ID"
+// @Success 204 {object} Resp
+// @Router /v1/subjects/{id} [delete]
+func (h *SubjectHandler) Delete(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		fail(c, 40001, "参数错误")
+		return
+	}
+	if err := h.svc.Delete(c.Request.Context(), userID, id); err != nil {
+		fail(c, mapSubjectErr(err), err.Error())
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func subjectToMap(s *model.Subject) map[string]interface{} {
+	aliases := []string(s.Aliases)
+	if aliases == nil {
+		aliases = []string{}
+	}
+	return map[string]interface{}{
+		"id":                s.ID,
+		"project_id":        s.ProjectID,
+		"name":              s.Name,
+		"aliases":           aliases,
+		"description":       s.Description,
+		"appearance_prompt": s.AppearancePrompt,
+		"resource_id":       s.ResourceID,
+		"voice_id":          s.VoiceID,
+		"source":            s.Source,
+		"created_at":        s.CreatedAt,
+		"updated_at":        s.UpdatedAt,
+	}
+}
+
+func mapSubjectErr(err error) int {
+	if err == nil {
+		return 0
+	}
+	switch err.Error() {
+	case "未授权", "无权访问":
+		return 40301
+	case "项目不存在":
+		return 40402
+	case "主体不存在", "参考图资源不存在", "声音不存在":
+		return 40401
+	case "主体名称已存在":
+		return 40901
+	default:
+		return mapLLMErr(err)
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+
+	"manjing-ai-go/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoadRole 每次请求从数据库加载用户角色写入 "role"，角色变更无需重新登录即可生效；需在 AuthMiddleware 之后使用
+func LoadRole(userRepo repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !loadRole(c, userRepo) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole 加载用户角色并校验是否属于指定角色之一，需在 AuthMiddleware 之后使用
+func RequireRole(userRepo repository.UserRepository, roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !loadRole(c, userRepo) {
+			return
+		}
+		role := c.GetString("role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"code": 40301, "message": "无权访问", "data": gin.H{}})
+		c.Abort()
+	}
+}
+
+// loadRole 同一请求内只查询一次；用户不存在或已禁用时中止请求
+func loadRole(c *gin.Context, userRepo repository.UserRepository) bool {
+	if _, exists := c.Get("role"); exists {
+		return true
+	}
+	user, err := userRepo.FindByID(c.Request.Context(), c.GetInt64("user_id"))
+	if err != nil || user.Status != 1 {
+		c.JSON(http.StatusOK, gin.H{"code": 10004, "message": "未授权", "data": gin.H{}})
+		c.Abort()
+		return false
+	}
+	c.Set("role", user.Role)
+	return true
+}
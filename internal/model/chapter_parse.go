@@ -0,0 +1,57 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// 章节解析状态
+const (
+	ChapterParseStatusPending int16 = 1 // 排队
+	ChapterParseStatusRunning int16 = 2 // 解析中
+	ChapterParseStatusSuccess int16 = 3 // 成功
+	ChapterParseStatusFailed  int16 = 4 // 失败
+)
+
+// ChapterParse 章节解析任务表
+type ChapterParse struct {
+	ID           int64          `gorm:"primaryKey" json:"id"`
+	ChapterID    int64          `json:"chapter_id"`
+	ProjectID    int64          `json:"project_id"`
+	UserID       int64          `json:"user_id"`
+	Status       int16          `gorm:"default:1" json:"status"`     // 状态：1排队/2解析中/3成功/4失败
+	TotalChunks  int            `json:"total_chunks"`                // 分段总数
+	DoneChunks   int            `json:"done_chunks"`                 // 已完成分段数
+	ContentHash  string         `gorm:"size:64" json:"content_hash"` // 解析时章节内容哈希
+	Result       datatypes.JSON `gorm:"type:jsonb" json:"result"`    // 解析结果，结构见 ChapterParseResult
+	ErrorMessage *string        `json:"error_message"`               // 错误信息
+	StartedAt    *time.Time     `json:"started_at"`
+	FinishedAt   *time.Time     `json:"finished_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// ChapterParseResult 章节解析结果
+type ChapterParseResult struct {
+	Scenes   []ChapterScene `json:"scenes"`
+	Speakers []string       `json:"speakers"` // 出现过的说话人（去重）
+}
+
+// ChapterScene 场景
+type ChapterScene struct {
+	Index    int           `json:"index"`    // 场景序号，从1开始
+	Title    string        `json:"title"`    // 场景标题
+	Location string        `json:"location"` // 地点
+	Time     string        `json:"time"`     // 时间
+	Summary  string        `json:"summary"`  // 场景概要
+	Lines    []ChapterLine `json:"lines"`
+}
+
+// ChapterLine 台词/旁白
+type ChapterLine struct {
+	Type    string `json:"type"`    // dialogue 对白 / narration 旁白
+	Speaker string `json:"speaker"` // 说话人，旁白为空
+	Content string `json:"content"` // 文本
+	Emotion string `json:"emotion"` // 情绪（可选）
+}
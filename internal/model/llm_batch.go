@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// LLM 批量任务状态
+const (
+	LLMJobStatusPending  int16 = 1 // 排队
+	LLMJobStatusRunning  int16 = 2 // 执行中
+	LLMJobStatusSuccess  int16 = 3 // 成功
+	LLMJobStatusFailed   int16 = 4 // 失败
+	LLMJobStatusCanceled int16 = 5 // 已取消
+)
+
+// LLMBatch LLM批量任务批次表
+type LLMBatch struct {
+	ID         int64      `gorm:"primaryKey" json:"id"`
+	UserID     int64      `json:"user_id"`
+	Name       string     `gorm:"size:128" json:"name"`
+	TotalJobs  int        `json:"total_jobs"`
+	CanceledAt *time.Time `json:"canceled_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// LLMJob LLM批量任务明细表
+type LLMJob struct {
+	ID           int64          `gorm:"primaryKey" json:"id"`
+	BatchID      int64          `json:"batch_id"`
+	UserID       int64          `json:"user_id"`
+	Seq          int            `json:"seq"`                       // 批次内序号
+	Status       int16          `gorm:"default:1" json:"status"`   // 状态：1排队/2执行中/3成功/4失败/5已取消
+	RouteKey     string         `gorm:"size:64" json:"route_key"`  // 并发分组
+	Request      datatypes.JSON `gorm:"type:jsonb" json:"request"` // 对话请求
+	Result       datatypes.JSON `gorm:"type:jsonb" json:"result"`  // 对话结果
+	ErrorMessage *string        `json:"error_message"`             // 失败原因
+	Attempts     int            `json:"attempts"`                  // 已执行次数
+	NextRunAt    time.Time      `json:"next_run_at"`               // 最早可执行时间
+	WorkerID     *string        `gorm:"size:64" json:"-"`          // 执行中的 worker
+	LockedUntil  *time.Time     `json:"-"`                         // 执行租约到期时间
+	StartedAt    *time.Time     `json:"started_at"`
+	FinishedAt   *time.Time     `json:"finished_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+}
+
+// LLMBatchProgress 批次进度
+type LLMBatchProgress struct {
+	Pending  int `json:"pending"`
+	Running  int `json:"running"`
+	Success  int `json:"success"`
+	Failed   int `json:"failed"`
+	Canceled int `json:"canceled"`
+}
+
+// Done 是否全部结束
+func (p LLMBatchProgress) Done() bool {
+	return p.Pending == 0 && p.Running == 0
+}
@@ -2,19 +2,40 @@ package model
 
 import "time"
 
+// 调用日志状态
+const (
+	LLMCallStatusSuccess       int16 = 1 // 成功
+	LLMCallStatusFailed        int16 = 2 // 其他失败
+	LLMCallStatusTimeout       int16 = 3 // 超时
+	LLMCallStatusRateLimited   int16 = 4 // 限流
+	LLMCallStatusAuthFailed    int16 = 5 // 鉴权失败
+	LLMCallStatusBadRequest    int16 = 6 // 请求参数错误
+	LLMCallStatusUpstream      int16 = 7 // 上游5xx/网络错误
+	LLMCallStatusMalformed     int16 = 8 // 响应无法解析
+	LLMCallStatusContextLength int16 = 9 // 上下文超长
+)
+
 // LLMCallLog LLM调用日志表
 type LLMCallLog struct {
-	ID               int64      `gorm:"primaryKey" json:"id"`
-	UserID           int64      `json:"user_id"`                                  // 调用用户ID
-	ModelID          *int64     `json:"model_id"`                                 // 关联模型配置ID
-	Provider         string     `gorm:"size:32" json:"provider"`                  // 服务商标识
-	Model            string     `gorm:"size:64" json:"model"`                     // 实际使用的模型标识
-	Purpose          string     `gorm:"size:32" json:"purpose"`                   // 调用用途
-	PromptTokens     int        `gorm:"default:0" json:"prompt_tokens"`           // 输入Token数
-	CompletionTokens int        `gorm:"default:0" json:"completion_tokens"`       // 输出Token数
-	TotalTokens      int        `gorm:"default:0" json:"total_tokens"`            // 总Token数
-	DurationMs       int        `gorm:"default:0" json:"duration_ms"`             // 调用耗时（毫秒）
-	Status           int16      `gorm:"default:1" json:"status"`                  // 状态：1成功/2失败/3超时
-	ErrorMessage     *string    `json:"error_message"`                            // 错误信息
-	CreatedAt        time.Time  `json:"created_at"`
+	ID               int64     `gorm:"primaryKey" json:"id"`
+	UserID           int64     `json:"user_id"`                            // 调用用户ID
+	ModelID          *int64    `json:"model_id"`                           // 关联模型配置ID
+	Provider         string    `gorm:"size:32" json:"provider"`            // 服务商标识
+	Model            string    `gorm:"size:64" json:"model"`               // 实际使用的模型标识
+	Purpose          string    `gorm:"size:32" json:"purpose"`             // 调用用途
+	PromptTokens     int       `gorm:"default:0" json:"prompt_tokens"`     // 输入Token数
+	CompletionTokens int       `gorm:"default:0" json:"completion_tokens"` // 输出Token数
+	TotalTokens      int       `gorm:"default:0" json:"total_tokens"`      // 总Token数
+	DurationMs       int       `gorm:"default:0" json:"duration_ms"`       // 调用耗时（毫秒）
+	Status           int16     `gorm:"default:1" json:"status"`            // 状态，见 LLMCallStatus* 常量
+	ErrorMessage     *string   `json:"error_message"`                      // 错误信息
+	Attempt          int16     `gorm:"default:1" json:"attempt"`           // 第几次尝试（降级链序号）
+	PromptID         *int64    `json:"prompt_id"`                          // 使用的提示词模板ID
+	PromptVersion    *int      `json:"prompt_version"`                     // 使用的提示词模板版本
+	CachedTokens     int       `gorm:"default:0" json:"cached_tokens"`     // 命中缓存的输入Token数
+	Cost             float64   `json:"cost"`                               // 调用费用，按调用时单价计算
+	Currency         string    `gorm:"size:8" json:"currency"`             // 费用币种
+	ArmModelID       *int64    `json:"arm_model_id"`                       // 按权重分配到的模型ID，降级时与 ModelID 不同
+	CacheHit         bool      `json:"cache_hit"`                          // 是否命中响应缓存，命中时Token与费用为0
+	CreatedAt        time.Time `json:"created_at"`
 }
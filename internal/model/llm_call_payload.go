@@ -0,0 +1,18 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// LLMCallPayload LLM调用内容采样表
+type LLMCallPayload struct {
+	ID        int64          `gorm:"primaryKey" json:"id"`
+	CallLogID int64          `json:"call_log_id"`                // 调用日志ID
+	Messages  datatypes.JSON `gorm:"type:jsonb" json:"messages"` // 发送的消息
+	Response  string         `json:"response"`                   // 模型返回内容
+	Redacted  bool           `json:"redacted"`                   // 是否已脱敏
+	ExpiresAt time.Time      `json:"expires_at"`                 // 过期时间
+	CreatedAt time.Time      `json:"created_at"`
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// LLMConversation LLM多轮对话会话表
+type LLMConversation struct {
+	ID           int64      `gorm:"primaryKey" json:"id"`
+	UserID       int64      `json:"user_id"`                                // 所属用户ID
+	Title        string     `gorm:"size:128" json:"title"`                  // 会话标题
+	Purpose      string     `gorm:"size:32;default:default" json:"purpose"` // 调用用途
+	ModelID      *int64     `json:"model_id"`                               // 固定模型配置ID
+	ProjectID    *int64     `json:"project_id"`                             // 关联项目ID
+	ChapterID    *int64     `json:"chapter_id"`                             // 关联章节ID
+	SystemPrompt string     `json:"system_prompt"`                          // 系统提示词
+	MessageCount int        `json:"message_count"`                          // 消息数
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at"`
+}
+
+// LLMMessage LLM会话消息表
+type LLMMessage struct {
+	ID               int64     `gorm:"primaryKey" json:"id"`
+	ConversationID   int64     `json:"conversation_id"`
+	Role             string    `gorm:"size:16" json:"role"`  // user/assistant
+	Content          string    `json:"content"`              // 消息内容
+	PromptTokens     int       `json:"prompt_tokens"`        // 本轮输入Token
+	CompletionTokens int       `json:"completion_tokens"`    // 输出Token
+	Model            string    `gorm:"size:64" json:"model"` // 生成回复的模型
+	CreatedAt        time.Time `json:"created_at"`
+}
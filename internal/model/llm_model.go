@@ -1,34 +1,142 @@
 package model
 
 import (
+	"encoding/json"
+	"math"
+	"strings"
 	"time"
 
 	"gorm.io/datatypes"
 )
 
+// 模型健康状态
+const (
+	LLMModelHealthUnknown   int16 = 0 // 未检测
+	LLMModelHealthHealthy   int16 = 1 // 健康
+	LLMModelHealthUnhealthy int16 = 2 // 不健康，不参与按用途选择
+)
+
 // LLMModel LLM模型配置表
 type LLMModel struct {
-	ID          int64          `gorm:"primaryKey" json:"id"`
-	Name        string         `gorm:"size:64" json:"name"`               // 模型显示名称
-	Provider    string         `gorm:"size:32" json:"provider"`           // 服务商标识
-	BaseURL     string         `gorm:"size:256" json:"base_url"`          // API端点URL
-	APIKey      string         `gorm:"size:256" json:"-"`                 // API密钥（JSON序列化时不输出）
-	Model       string         `gorm:"size:64" json:"model"`              // 模型标识
-	MaxTokens   int            `gorm:"default:4096" json:"max_tokens"`    // 最大输出Token数
-	Temperature float64        `gorm:"default:0.70" json:"temperature"`   // 温度参数
-	Timeout     int            `gorm:"default:60" json:"timeout"`         // 超时时间（秒）
-	Purpose     string         `gorm:"size:32;default:default" json:"purpose"` // 用途
-	IsActive    bool           `gorm:"default:true" json:"is_active"`     // 是否启用
-	ExtraConfig datatypes.JSON `gorm:"type:jsonb" json:"extra_config"`    // 扩展配置
-	CreatedAt   time.Time      `json:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at"`
-	DeletedAt   *time.Time     `json:"deleted_at"`
+	ID                  int64          `gorm:"primaryKey" json:"id"`
+	Name                string         `gorm:"size:64" json:"name"`                    // 模型显示名称
+	Provider            string         `gorm:"size:32" json:"provider"`                // 服务商标识
+	BaseURL             string         `gorm:"size:256" json:"base_url"`               // API端点URL
+	APIKey              string         `gorm:"size:1024" json:"-"`                     // API密钥密文（JSON序列化时不输出）
+	APIKeyMask          string         `gorm:"size:32" json:"-"`                       // 脱敏后的API密钥，用于展示
+	Model               string         `gorm:"size:64" json:"model"`                   // 模型标识
+	MaxTokens           int            `gorm:"default:4096" json:"max_tokens"`         // 最大输出Token数
+	ContextWindow       int            `gorm:"default:0" json:"context_window"`        // 上下文窗口（输入+输出），0 表示不检查
+	Temperature         float64        `gorm:"default:0.70" json:"temperature"`        // 温度参数
+	Timeout             int            `gorm:"default:60" json:"timeout"`              // 超时时间（秒），流式调用为响应头及相邻分片的最长间隔
+	Purpose             string         `gorm:"size:32;default:default" json:"purpose"` // 用途
+	Priority            int            `gorm:"default:0" json:"priority"`              // 同用途降级顺序，越小越优先
+	Weight              int            `gorm:"default:100" json:"weight"`              // 同优先级内的流量权重，0 表示只作为降级备选
+	IsActive            bool           `gorm:"default:true" json:"is_active"`          // 是否启用
+	ExtraConfig         datatypes.JSON `gorm:"type:jsonb" json:"extra_config"`         // 扩展配置
+	InputPrice          float64        `json:"input_price"`                            // 输入单价（每1K Token）
+	OutputPrice         float64        `json:"output_price"`                           // 输出单价（每1K Token）
+	CachedInputPrice    float64        `json:"cached_input_price"`                     // 缓存命中输入单价（每1K Token），为0时按输入单价计
+	Currency            string         `gorm:"size:8;default:CNY" json:"currency"`     // 币种
+	HealthStatus        int16          `gorm:"default:0" json:"health_status"`         // 健康状态，见 LLMModelHealth* 常量
+	ConsecutiveFailures int            `gorm:"default:0" json:"consecutive_failures"`  // 连续探测失败次数
+	LastCheckedAt       *time.Time     `json:"last_checked_at"`                        // 最近探测时间
+	LastCheckError      string         `gorm:"size:512" json:"last_check_error"`       // 最近一次探测失败原因
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           *time.Time     `json:"deleted_at"`
 }
 
 // MaskedAPIKey 返回脱敏后的API Key
 func (m *LLMModel) MaskedAPIKey() string {
-	if len(m.APIKey) <= 6 {
+	if m.APIKeyMask != "" {
+		return m.APIKeyMask
+	}
+	if strings.HasPrefix(m.APIKey, "enc:") {
+		return "sk-***"
+	}
+	return MaskAPIKey(m.APIKey)
+}
+
+// MaskAPIKey 对明文API Key脱敏
+func MaskAPIKey(key string) string {
+	if len(key) <= 6 {
 		return "sk-***"
 	}
-	return m.APIKey[:3] + "***" + m.APIKey[len(m.APIKey)-3:]
+	return key[:3] + "***" + key[len(key)-3:]
+}
+
+// LLMExtraConfig extra_config 中约定的结构化配置
+type LLMExtraConfig struct {
+	Retry   *LLMRetryConfig   `json:"retry,omitempty"`   // 重试策略，未配置时使用全局默认
+	Capture *LLMCaptureConfig `json:"capture,omitempty"` // 调用内容采样，未配置时按用途或全局配置
+	Limits  *LLMLimitConfig   `json:"limits,omitempty"`  // 调用限额，未配置时不限制
+}
+
+// LLMLimitConfig 单个模型的调用限额，在所有 API 实例间共同生效；0 表示不限制
+type LLMLimitConfig struct {
+	MaxConcurrency int `json:"max_concurrency"` // 最大并发请求数
+	RPM            int `json:"rpm"`             // 每分钟请求数
+	TPM            int `json:"tpm"`             // 每分钟 Token 数（输入按估算预扣，完成后按实际用量修正）
+}
+
+// Enabled 是否配置了任一限额
+func (c *LLMLimitConfig) Enabled() bool {
+	return c != nil && (c.MaxConcurrency > 0 || c.RPM > 0 || c.TPM > 0)
+}
+
+// LLMCaptureConfig 单个模型的调用内容采样配置
+type LLMCaptureConfig struct {
+	SampleRate float64 `json:"sample_rate"` // 采样率 0~1
+}
+
+// LLMRetryConfig 单个模型的重试策略
+type LLMRetryConfig struct {
+	MaxAttempts   int     `json:"max_attempts"`    // 最大尝试次数（含首次）
+	BaseBackoffMs int     `json:"base_backoff_ms"` // 初始退避（毫秒）
+	MaxBackoffMs  int     `json:"max_backoff_ms"`  // 最大退避（毫秒）
+	Jitter        float64 `json:"jitter"`          // 抖动比例 0~1
+}
+
+// ParseExtraConfig 解析扩展配置
+func (m *LLMModel) ParseExtraConfig() (LLMExtraConfig, error) {
+	var extra LLMExtraConfig
+	if len(m.ExtraConfig) == 0 || string(m.ExtraConfig) == "null" {
+		return extra, nil
+	}
+	err := json.Unmarshal(m.ExtraConfig, &extra)
+	return extra, err
+}
+
+// LLMPrice 模型单价（每1K Token）
+type LLMPrice struct {
+	Input       float64
+	Output      float64
+	CachedInput float64 // 为0时按 Input 计
+	Currency    string
+}
+
+// Price 返回模型单价
+func (m *LLMModel) Price() LLMPrice {
+	return LLMPrice{
+		Input:       m.InputPrice,
+		Output:      m.OutputPrice,
+		CachedInput: m.CachedInputPrice,
+		Currency:    m.Currency,
+	}
+}
+
+// Cost 按单价计算费用，保留6位小数
+func (p LLMPrice) Cost(promptTokens, cachedTokens, completionTokens int) float64 {
+	if cachedTokens > promptTokens {
+		cachedTokens = promptTokens
+	}
+	cachedPrice := p.CachedInput
+	if cachedPrice <= 0 {
+		cachedPrice = p.Input
+	}
+	cost := float64(promptTokens-cachedTokens)/1000*p.Input +
+		float64(cachedTokens)/1000*cachedPrice +
+		float64(completionTokens)/1000*p.Output
+	return math.Round(cost*1e6) / 1e6
 }
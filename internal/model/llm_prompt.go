@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// LLMPrompt LLM提示词模板表
+type LLMPrompt struct {
+	ID          int64          `gorm:"primaryKey" json:"id"`
+	Purpose     string         `gorm:"size:32" json:"purpose"`        // 用途
+	Version     int            `json:"version"`                       // 同用途内递增的版本号
+	Name        string         `gorm:"size:64" json:"name"`           // 模板名称
+	Description string         `gorm:"size:256" json:"description"`   // 模板说明
+	Messages    datatypes.JSON `gorm:"type:jsonb" json:"messages"`    // 消息模板：[{"role","content"}]，content 为 Go text/template
+	IsActive    bool           `gorm:"default:true" json:"is_active"` // 是否启用
+	CreatedBy   int64          `json:"created_by"`                    // 创建人用户ID
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   *time.Time     `json:"deleted_at"`
+}
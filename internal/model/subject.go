@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// 主体来源
+const (
+	SubjectSourceManual  int16 = 1 // 手动创建
+	SubjectSourceExtract int16 = 2 // 模型提取
+)
+
+// Subject 项目主体（角色）表
+type Subject struct {
+	ID               int64                       `gorm:"primaryKey" json:"id"`
+	ProjectID        int64                       `json:"project_id"`
+	Name             string                      `gorm:"size:64" json:"name"`       // 主体名称，项目内唯一
+	Aliases          datatypes.JSONSlice[string] `gorm:"type:jsonb" json:"aliases"` // 别名
+	Description      string                      `json:"description"`               // 人物描述
+	AppearancePrompt string                      `json:"appearance_prompt"`         // 外观提示词
+	ResourceID       *int64                      `json:"resource_id"`               // 参考图资源ID
+	VoiceID          *int64                      `json:"voice_id"`                  // 绑定声音ID
+	Source           int16                       `gorm:"default:1" json:"source"`   // 来源：1手动/2提取
+	CreatedAt        time.Time                   `json:"created_at"`
+	UpdatedAt        time.Time                   `json:"updated_at"`
+	DeletedAt        *time.Time                  `json:"deleted_at"`
+}
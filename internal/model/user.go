@@ -2,6 +2,12 @@ package model
 
 import "time"
 
+// 用户角色
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
+
 // User 用户表结构
 type User struct {
 	ID           int64     `gorm:"primaryKey" json:"id"`
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"manjing-ai-go/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChapterParseRepository 章节解析数据访问接口
+type ChapterParseRepository interface {
+	CreateIfIdle(ctx context.Context, p *model.ChapterParse) (bool, error)
+	ExpireStale(ctx context.Context, chapterID int64, before time.Time) error
+	Update(ctx context.Context, id int64, updates map[string]interface{}) error
+	FindByID(ctx context.Context, id int64) (*model.ChapterParse, error)
+	FindLatestByChapter(ctx context.Context, chapterID int64) (*model.ChapterParse, error)
+	FindLatestSuccessByChapters(ctx context.Context, chapterIDs []int64) ([]model.ChapterParse, error)
+}
+
+// ChapterParseRepo 实现
+type ChapterParseRepo struct {
+	db *gorm.DB
+}
+
+// NewChapterParseRepo 创建仓库
+func NewChapterParseRepo(db *gorm.DB) *ChapterParseRepo {
+	return &ChapterParseRepo{db: db}
+}
+
+// CreateIfIdle 章节没有进行中的任务时创建，否则不插入并返回 false；
+// 依赖唯一索引 uniq_chapter_parses_in_progress，并发请求只有一个能创建成功
+func (r *ChapterParseRepo) CreateIfIdle(ctx context.Context, p *model.ChapterParse) (bool, error) {
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "chapter_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "status IN (1, 2)"}}},
+		DoNothing:   true,
+	}).Create(p)
+	return res.RowsAffected > 0, res.Error
+}
+
+// ExpireStale 把 before 之后未再更新的进行中任务标记为失败（进程重启遗留），释放唯一索引
+func (r *ChapterParseRepo) ExpireStale(ctx context.Context, chapterID int64, before time.Time) error {
+	msg := "任务超时未完成"
+	now := time.Now()
+	return r.db.WithContext(ctx).Model(&model.ChapterParse{}).
+		Where("chapter_id = ? AND status IN ? AND updated_at < ?", chapterID,
+			[]int16{model.ChapterParseStatusPending, model.ChapterParseStatusRunning}, before).
+		Updates(map[string]interface{}{
+			"status":        model.ChapterParseStatusFailed,
+			"error_message": &msg,
+			"finished_at":   &now,
+			"updated_at":    now,
+		}).Error
+}
+
+func (r *ChapterParseRepo) Update(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&model.ChapterParse{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *ChapterParseRepo) FindByID(ctx context.Context, id int64) (*model.ChapterParse, error) {
+	var p model.ChapterParse
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindLatestByChapter 查找章节最近一次解析
+func (r *ChapterParseRepo) FindLatestByChapter(ctx context.Context, chapterID int64) (*model.ChapterParse, error) {
+	var p model.ChapterParse
+	err := r.db.WithContext(ctx).Where("chapter_id = ?", chapterID).Order("created_at DESC").First(&p).Error
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindLatestSuccessByChapters 查找每个章节最近一次成功的解析
+func (r *ChapterParseRepo) FindLatestSuccessByChapters(ctx context.Context, chapterIDs []int64) ([]model.ChapterParse, error) {
+	var items []model.ChapterParse
+	if len(chapterIDs) == 0 {
+		return items, nil
+	}
+	err := r.db.WithContext(ctx).
+		Raw(`SELECT DISTINCT ON (chapter_id) * FROM chapter_parses
+			WHERE chapter_id IN ? AND status = ?
+			ORDER BY chapter_id, created_at DESC`, chapterIDs, model.ChapterParseStatusSuccess).
+		Scan(&items).Error
+	return items, err
+}
@@ -14,6 +14,7 @@ type ChapterRepository interface {
 	Update(ctx context.Context, id int64, updates map[string]interface{}) error
 	FindByID(ctx context.Context, id int64) (*model.Chapter, error)
 	List(ctx context.Context, projectID int64, query ChapterListQuery) ([]model.Chapter, int64, error)
+	ListAllByProject(ctx context.Context, projectID int64) ([]model.Chapter, error)
 }
 
 // ChapterListQuery 章节列表查询
@@ -95,3 +96,13 @@ func (r *ChapterRepo) List(ctx context.Context, projectID int64, query ChapterLi
 	err := db.Offset((query.Page - 1) * query.PageSize).Limit(query.PageSize).Find(&items).Error
 	return items, total, err
 }
+
+// ListAllByProject 项目下全部未删除章节，按章节顺序排列
+func (r *ChapterRepo) ListAllByProject(ctx context.Context, projectID int64) ([]model.Chapter, error) {
+	var items []model.Chapter
+	err := r.db.WithContext(ctx).
+		Where("project_id = ? AND status <> ?", projectID, 3).
+		Order("order_index ASC, id ASC").
+		Find(&items).Error
+	return items, err
+}
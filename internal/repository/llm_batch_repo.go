@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"manjing-ai-go/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// LLMBatchRepository 批量任务数据访问接口
+type LLMBatchRepository interface {
+	Create(ctx context.Context, batch *model.LLMBatch, jobs []*model.LLMJob) error
+	FindByID(ctx context.Context, id int64) (*model.LLMBatch, error)
+	List(ctx context.Context, userID int64, query LLMBatchListQuery) ([]model.LLMBatch, int64, error)
+	Progress(ctx context.Context, batchIDs []int64) (map[int64]model.LLMBatchProgress, error)
+	Cancel(ctx context.Context, batchID int64) (int64, error)
+	ListJobs(ctx context.Context, batchID int64, query LLMJobListQuery) ([]model.LLMJob, int64, error)
+	RetryFailed(ctx context.Context, batchID int64, jobID *int64) (int64, error)
+
+	// 以下供 worker 使用
+	ClaimJob(ctx context.Context, workerID string, excludeKeys []string, lease time.Duration) (*model.LLMJob, error)
+	ExtendLease(ctx context.Context, workerID string, jobIDs []int64, lease time.Duration) ([]int64, error)
+	FinishJob(ctx context.Context, workerID string, jobID int64, updates map[string]interface{}) (bool, error)
+}
+
+// LLMBatchListQuery 批次列表查询参数
+type LLMBatchListQuery struct {
+	Page     int
+	PageSize int
+}
+
+// LLMJobListQuery 任务明细查询参数
+type LLMJobListQuery struct {
+	Page     int
+	PageSize int
+	Status   *int16
+}
+
+// LLMBatchRepo 批量任务仓库实现
+type LLMBatchRepo struct {
+	db *gorm.DB
+}
+
+// NewLLMBatchRepo 创建批量任务仓库
+func NewLLMBatchRepo(db *gorm.DB) *LLMBatchRepo {
+	return &LLMBatchRepo{db: db}
+}
+
+// Create 在同一事务中创建批次与全部任务
+func (r *LLMBatchRepo) Create(ctx context.Context, batch *model.LLMBatch, jobs []*model.LLMJob) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+		for _, j := range jobs {
+			j.BatchID = batch.ID
+		}
+		return tx.CreateInBatches(jobs, 500).Error
+	})
+}
+
+func (r *LLMBatchRepo) FindByID(ctx context.Context, id int64) (*model.LLMBatch, error) {
+	var b model.LLMBatch
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&b).Error; err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *LLMBatchRepo) List(ctx context.Context, userID int64, query LLMBatchListQuery) ([]model.LLMBatch, int64, error) {
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 20
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+
+	db := r.db.WithContext(ctx).Model(&model.LLMBatch{}).Where("user_id = ?", userID)
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []model.LLMBatch
+	offset := (query.Page - 1) * query.PageSize
+	if err := db.Order("created_at DESC").Offset(offset).Limit(query.PageSize).Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// Progress 按状态统计各批次的任务数
+func (r *LLMBatchRepo) Progress(ctx context.Context, batchIDs []int64) (map[int64]model.LLMBatchProgress, error) {
+	out := make(map[int64]model.LLMBatchProgress, len(batchIDs))
+	if len(batchIDs) == 0 {
+		return out, nil
+	}
+	var rows []struct {
+		BatchID int64
+		Status  int16
+		Count   int
+	}
+	err := r.db.WithContext(ctx).Model(&model.LLMJob{}).
+		Select("batch_id, status, COUNT(*) AS count").
+		Where("batch_id IN ?", batchIDs).
+		Group("batch_id, status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		p := out[row.BatchID]
+		switch row.Status {
+		case model.LLMJobStatusPending:
+			p.Pending = row.Count
+		case model.LLMJobStatusRunning:
+			p.Running = row.Count
+		case model.LLMJobStatusSuccess:
+			p.Success = row.Count
+		case model.LLMJobStatusFailed:
+			p.Failed = row.Count
+		case model.LLMJobStatusCanceled:
+			p.Canceled = row.Count
+		}
+		out[row.BatchID] = p
+	}
+	return out, nil
+}
+
+// Cancel 取消批次中排队与执行中的任务，执行中的任务由 worker 续租时发现并中止
+func (r *LLMBatchRepo) Cancel(ctx context.Context, batchID int64) (int64, error) {
+	var affected int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&model.LLMBatch{}).Where("id = ?", batchID).
+			Updates(map[string]interface{}{"canceled_at": now, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		res := tx.Model(&model.LLMJob{}).
+			Where("batch_id = ? AND status IN ?", batchID, []int16{model.LLMJobStatusPending, model.LLMJobStatusRunning}).
+			Updates(map[string]interface{}{
+				"status":       model.LLMJobStatusCanceled,
+				"locked_until": nil,
+				"finished_at":  now,
+				"updated_at":   now,
+			})
+		affected = res.RowsAffected
+		return res.Error
+	})
+	return affected, err
+}
+
+func (r *LLMBatchRepo) ListJobs(ctx context.Context, batchID int64, query LLMJobListQuery) ([]model.LLMJob, int64, error) {
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 20
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+
+	db := r.db.WithContext(ctx).Model(&model.LLMJob{}).Where("batch_id = ?", batchID)
+	if query.Status != nil {
+		db = db.Where("status = ?", *query.Status)
+	}
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []model.LLMJob
+	offset := (query.Page - 1) * query.PageSize
+	if err := db.Order("seq ASC").Offset(offset).Limit(query.PageSize).Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// RetryFailed 把失败的任务重新排队；jobID 为空时重试批次内全部失败任务
+func (r *LLMBatchRepo) RetryFailed(ctx context.Context, batchID int64, jobID *int64) (int64, error) {
+	now := time.Now()
+	db := r.db.WithContext(ctx).Model(&model.LLMJob{}).
+		Where("batch_id = ? AND status = ?", batchID, model.LLMJobStatusFailed)
+	if jobID != nil {
+		db = db.Where("id = ?", *jobID)
+	}
+	res := db.Updates(map[string]interface{}{
+		"status":        model.LLMJobStatusPending,
+		"attempts":      0,
+		"error_message": nil,
+		"result":        nil,
+		"next_run_at":   now,
+		"started_at":    nil,
+		"finished_at":   nil,
+		"updated_at":    now,
+	})
+	return res.RowsAffected, res.Error
+}
+
+// ClaimJob 领取一个可执行的任务：排队且已到执行时间，或执行中但租约过期（worker 异常退出）。
+// excludeKeys 为本 worker 已达并发上限的分组；FOR UPDATE SKIP LOCKED 保证多个 worker 不会领到同一任务。
+func (r *LLMBatchRepo) ClaimJob(ctx context.Context, workerID string, excludeKeys []string, lease time.Duration) (*model.LLMJob, error) {
+	args := []interface{}{model.LLMJobStatusRunning, workerID, time.Now().Add(lease), model.LLMJobStatusPending, model.LLMJobStatusRunning}
+	filter := ""
+	if len(excludeKeys) > 0 {
+		filter = " AND route_key NOT IN ?"
+		args = append(args, excludeKeys)
+	}
+	sql := `UPDATE llm_jobs SET status = ?, worker_id = ?, locked_until = ?, attempts = attempts + 1,
+			started_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM llm_jobs
+			WHERE ((status = ? AND next_run_at <= NOW()) OR (status = ? AND locked_until < NOW()))` + filter + `
+			ORDER BY id LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`
+	var jobs []model.LLMJob
+	if err := r.db.WithContext(ctx).Raw(sql, args...).Scan(&jobs).Error; err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0], nil
+}
+
+// ExtendLease 为本 worker 仍持有的任务续租，返回已不再持有的任务ID（被取消或租约被其他 worker 接管）
+func (r *LLMBatchRepo) ExtendLease(ctx context.Context, workerID string, jobIDs []int64, lease time.Duration) ([]int64, error) {
+	if len(jobIDs) == 0 {
+		return nil, nil
+	}
+	var held []int64
+	err := r.db.WithContext(ctx).Raw(`UPDATE llm_jobs SET locked_until = ?
+		WHERE id IN ? AND status = ? AND worker_id = ?
+		RETURNING id`, time.Now().Add(lease), jobIDs, model.LLMJobStatusRunning, workerID).
+		Scan(&held).Error
+	if err != nil {
+		return nil, err
+	}
+	heldSet := make(map[int64]bool, len(held))
+	for _, id := range held {
+		heldSet[id] = true
+	}
+	var lost []int64
+	for _, id := range jobIDs {
+		if !heldSet[id] {
+			lost = append(lost, id)
+		}
+	}
+	return lost, nil
+}
+
+// FinishJob 写回执行结果；任务已被取消或被其他 worker 接管时不更新并返回 false
+func (r *LLMBatchRepo) FinishJob(ctx context.Context, workerID string, jobID int64, updates map[string]interface{}) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&model.LLMJob{}).
+		Where("id = ? AND status = ? AND worker_id = ?", jobID, model.LLMJobStatusRunning, workerID).
+		Updates(updates)
+	return res.RowsAffected > 0, res.Error
+}
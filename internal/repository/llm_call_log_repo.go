@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"manjing-ai-go/internal/model"
@@ -14,12 +15,15 @@ type LLMCallLogRepository interface {
 	Create(ctx context.Context, log *model.LLMCallLog) error
 	List(ctx context.Context, query LLMCallLogListQuery) ([]model.LLMCallLog, int64, error)
 	Stats(ctx context.Context, query LLMCallLogStatsQuery) (*LLMCallLogStats, []LLMCallLogGroupStats, error)
+	SumTokensByUser(ctx context.Context, userID int64, since time.Time) (int64, error)
+	Series(ctx context.Context, query LLMCallLogSeriesQuery) (*LLMCallLogSeries, error)
 }
 
 // LLMCallLogListQuery 调用日志列表查询参数
 type LLMCallLogListQuery struct {
 	Page      int
 	PageSize  int
+	UserID    int64 // 为 0 时不过滤
 	Purpose   string
 	Provider  string
 	Status    int
@@ -32,26 +36,90 @@ type LLMCallLogListQuery struct {
 type LLMCallLogStatsQuery struct {
 	StartTime *time.Time
 	EndTime   *time.Time
-	GroupBy   string // provider / purpose / model
+	GroupBy   LLMStatsDimension // 分组维度，见 LLMStatsBy* 常量
+}
+
+// LLMStatsDimension 用量统计分组维度
+type LLMStatsDimension string
+
+// 用量统计分组维度
+const (
+	LLMStatsByProvider LLMStatsDimension = "provider"
+	LLMStatsByPurpose  LLMStatsDimension = "purpose"
+	LLMStatsByModel    LLMStatsDimension = "model"
+	LLMStatsByModelID  LLMStatsDimension = "model_id"
+	LLMStatsByUserID   LLMStatsDimension = "user_id"
+	LLMStatsByStatus   LLMStatsDimension = "status"
+	LLMStatsByDay      LLMStatsDimension = "day"
+	LLMStatsByArm      LLMStatsDimension = "arm"
+)
+
+// statsDimensionExprs 分组维度对应的 SQL 表达式，统一转为文本作为 group_key。
+// 只有这里的常量表达式会拼入 SQL，调用方传入的字符串不会出现在语句中。
+var statsDimensionExprs = map[LLMStatsDimension]string{
+	LLMStatsByProvider: "provider",
+	LLMStatsByPurpose:  "purpose",
+	LLMStatsByModel:    "model",
+	LLMStatsByModelID:  "COALESCE(model_id::text, '')",
+	LLMStatsByUserID:   "user_id::text",
+	LLMStatsByStatus:   "status::text",
+	LLMStatsByDay:      "to_char(date_trunc('day', created_at), 'YYYY-MM-DD')",
+	LLMStatsByArm:      "COALESCE(arm_model_id::text, '')",
+}
+
+// Valid 是否为受支持的分组维度
+func (d LLMStatsDimension) Valid() bool {
+	_, ok := statsDimensionExprs[d]
+	return ok
 }
 
 // LLMCallLogStats 用量统计结果
 type LLMCallLogStats struct {
-	TotalCalls            int64 `json:"total_calls"`
-	SuccessCalls          int64 `json:"success_calls"`
-	FailedCalls           int64 `json:"failed_calls"`
-	TotalTokens           int64 `json:"total_tokens"`
-	TotalPromptTokens     int64 `json:"total_prompt_tokens"`
-	TotalCompletionTokens int64 `json:"total_completion_tokens"`
-	AvgDurationMs         int64 `json:"avg_duration_ms"`
+	TotalCalls            int64             `json:"total_calls"`
+	SuccessCalls          int64             `json:"success_calls"`
+	FailedCalls           int64             `json:"failed_calls"`
+	TotalTokens           int64             `json:"total_tokens"`
+	TotalPromptTokens     int64             `json:"total_prompt_tokens"`
+	TotalCompletionTokens int64             `json:"total_completion_tokens"`
+	TotalCachedTokens     int64             `json:"total_cached_tokens"`
+	AvgDurationMs         int64             `json:"avg_duration_ms"`
+	TotalCost             float64           `json:"total_cost"`     // 费用合计（不区分币种）
+	Costs                 []LLMCurrencyCost `json:"costs" gorm:"-"` // 按币种的费用
+}
+
+// LLMCurrencyCost 单一币种的费用
+type LLMCurrencyCost struct {
+	Currency string  `json:"currency"`
+	Cost     float64 `json:"cost"`
 }
 
 // LLMCallLogGroupStats 分组统计结果
 type LLMCallLogGroupStats struct {
-	Key           string `json:"key" gorm:"column:group_key"`
-	CallCount     int64  `json:"call_count"`
-	TotalTokens   int64  `json:"total_tokens"`
-	AvgDurationMs int64  `json:"avg_duration_ms"`
+	Key           string            `json:"key" gorm:"column:group_key"`
+	CallCount     int64             `json:"call_count"`
+	TotalTokens   int64             `json:"total_tokens"`
+	AvgDurationMs int64             `json:"avg_duration_ms"`
+	Costs         []LLMCurrencyCost `json:"costs" gorm:"-"` // 按币种的费用，不同币种不相加
+}
+
+// currencyCostRow 按分组键与币种汇总的费用
+type currencyCostRow struct {
+	Key      string `gorm:"column:group_key"`
+	Bucket   time.Time
+	Currency string
+	Cost     float64
+}
+
+// statsScope 按时间范围过滤的调用日志查询
+func (r *LLMCallLogRepo) statsScope(ctx context.Context, start, end *time.Time) *gorm.DB {
+	db := r.db.WithContext(ctx).Model(&model.LLMCallLog{})
+	if start != nil {
+		db = db.Where("created_at >= ?", *start)
+	}
+	if end != nil {
+		db = db.Where("created_at <= ?", *end)
+	}
+	return db
 }
 
 // LLMCallLogRepo 调用日志仓库实现
@@ -81,6 +149,9 @@ func (r *LLMCallLogRepo) List(ctx context.Context, query LLMCallLogListQuery) ([
 
 	db := r.db.WithContext(ctx).Model(&model.LLMCallLog{})
 
+	if query.UserID > 0 {
+		db = db.Where("user_id = ?", query.UserID)
+	}
 	if query.Purpose != "" {
 		db = db.Where("purpose = ?", query.Purpose)
 	}
@@ -117,53 +188,254 @@ func (r *LLMCallLogRepo) List(ctx context.Context, query LLMCallLogListQuery) ([
 }
 
 func (r *LLMCallLogRepo) Stats(ctx context.Context, query LLMCallLogStatsQuery) (*LLMCallLogStats, []LLMCallLogGroupStats, error) {
-	db := r.db.WithContext(ctx).Model(&model.LLMCallLog{})
-
-	if query.StartTime != nil {
-		db = db.Where("created_at >= ?", *query.StartTime)
+	groupBy := query.GroupBy
+	if groupBy == "" {
+		groupBy = LLMStatsByProvider
 	}
-	if query.EndTime != nil {
-		db = db.Where("created_at <= ?", *query.EndTime)
+	groupExpr, ok := statsDimensionExprs[groupBy]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported group dimension: %q", groupBy)
 	}
 
 	// 总体统计
 	var stats LLMCallLogStats
-	err := db.Select(`
+	err := r.statsScope(ctx, query.StartTime, query.EndTime).Select(`
 		COUNT(*) AS total_calls,
 		COUNT(CASE WHEN status = 1 THEN 1 END) AS success_calls,
 		COUNT(CASE WHEN status != 1 THEN 1 END) AS failed_calls,
 		COALESCE(SUM(total_tokens), 0) AS total_tokens,
 		COALESCE(SUM(prompt_tokens), 0) AS total_prompt_tokens,
 		COALESCE(SUM(completion_tokens), 0) AS total_completion_tokens,
-		COALESCE(AVG(duration_ms), 0) AS avg_duration_ms
+		COALESCE(SUM(cached_tokens), 0) AS total_cached_tokens,
+		COALESCE(AVG(duration_ms), 0) AS avg_duration_ms,
+		COALESCE(SUM(cost), 0) AS total_cost
 	`).Scan(&stats).Error
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// 分组统计
-	groupBy := query.GroupBy
-	if groupBy == "" {
-		groupBy = "provider"
-	}
-
-	db2 := r.db.WithContext(ctx).Model(&model.LLMCallLog{})
-	if query.StartTime != nil {
-		db2 = db2.Where("created_at >= ?", *query.StartTime)
-	}
-	if query.EndTime != nil {
-		db2 = db2.Where("created_at <= ?", *query.EndTime)
+	// 按币种汇总费用
+	stats.Costs = []LLMCurrencyCost{}
+	if err := r.statsScope(ctx, query.StartTime, query.EndTime).Where("cost > 0").Select("currency, SUM(cost) AS cost").Group("currency").Order("currency").Scan(&stats.Costs).Error; err != nil {
+		return nil, nil, err
 	}
 
+	// 分组统计
 	var groups []LLMCallLogGroupStats
-	err = db2.Select(groupBy + ` AS group_key,
+	err = r.statsScope(ctx, query.StartTime, query.EndTime).Select(groupExpr + ` AS group_key,
 		COUNT(*) AS call_count,
 		COALESCE(SUM(total_tokens), 0) AS total_tokens,
 		COALESCE(AVG(duration_ms), 0) AS avg_duration_ms
-	`).Group(groupBy).Scan(&groups).Error
+	`).Group("1").Order("1").Scan(&groups).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 分组费用按币种汇总
+	var costRows []currencyCostRow
+	err = r.statsScope(ctx, query.StartTime, query.EndTime).Where("cost > 0").
+		Select(groupExpr + " AS group_key, currency, SUM(cost) AS cost").
+		Group("1, 2").Order("1, 2").Scan(&costRows).Error
 	if err != nil {
 		return nil, nil, err
 	}
+	costs := map[string][]LLMCurrencyCost{}
+	for _, row := range costRows {
+		costs[row.Key] = append(costs[row.Key], LLMCurrencyCost{Currency: row.Currency, Cost: row.Cost})
+	}
+	for i := range groups {
+		groups[i].Costs = nonNilCosts(costs[groups[i].Key])
+	}
 
 	return &stats, groups, nil
 }
+
+// SumTokensByUser 用户自 since 起消耗的 Token 总数
+func (r *LLMCallLogRepo) SumTokensByUser(ctx context.Context, userID int64, since time.Time) (int64, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Model(&model.LLMCallLog{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Select("COALESCE(SUM(total_tokens), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// LLMCallLogSeriesQuery 时间序列统计查询参数
+type LLMCallLogSeriesQuery struct {
+	StartTime time.Time
+	EndTime   time.Time
+	Interval  string // hour / day / week
+	SplitBy   string // 可选第二维度：provider / purpose / model / arm
+	TopN      int    // 用量前 N 的用户
+}
+
+// LLMCallLogSeries 时间序列统计结果
+type LLMCallLogSeries struct {
+	StartTime time.Time             `json:"start_time"`
+	EndTime   time.Time             `json:"end_time"`
+	Interval  string                `json:"interval"`
+	SplitBy   string                `json:"split_by"`
+	Buckets   []LLMCallLogBucket    `json:"buckets"`
+	TopUsers  []LLMCallLogUserStats `json:"top_users"`
+}
+
+// LLMCallLogBucket 单个时间桶（及第二维度取值）的统计
+type LLMCallLogBucket struct {
+	Bucket      time.Time         `json:"bucket"`
+	SplitKey    string            `json:"split_key,omitempty"`
+	CallCount   int64             `json:"call_count"`
+	FailedCount int64             `json:"failed_count"`
+	ErrorRate   float64           `json:"error_rate"`
+	TotalTokens int64             `json:"total_tokens"`
+	Costs       []LLMCurrencyCost `json:"costs" gorm:"-"`              // 按币种的费用
+	P50Ms       float64           `json:"p50_ms" gorm:"column:p50_ms"` // 成功调用耗时分位数
+	P95Ms       float64           `json:"p95_ms" gorm:"column:p95_ms"`
+	P99Ms       float64           `json:"p99_ms" gorm:"column:p99_ms"`
+}
+
+// LLMCallLogUserStats 用户用量
+type LLMCallLogUserStats struct {
+	UserID      int64             `json:"user_id"`
+	CallCount   int64             `json:"call_count"`
+	TotalTokens int64             `json:"total_tokens"`
+	Costs       []LLMCurrencyCost `json:"costs" gorm:"-"` // 按币种的费用
+}
+
+// seriesSplitColumns 时间序列第二维度与列名的对应关系，只允许白名单内的列拼入 SQL
+var seriesSplitColumns = map[string]string{
+	"provider": "provider",
+	"purpose":  "purpose",
+	"model":    "model",
+	"arm":      "COALESCE(arm_model_id::text, '')",
+}
+
+// seriesIntervals date_trunc 支持的时间粒度
+var seriesIntervals = map[string]bool{"hour": true, "day": true, "week": true}
+
+// Series 按时间桶统计调用量、错误率、耗时分位数，并返回用量前 N 的用户
+func (r *LLMCallLogRepo) Series(ctx context.Context, query LLMCallLogSeriesQuery) (*LLMCallLogSeries, error) {
+	if !seriesIntervals[query.Interval] {
+		return nil, fmt.Errorf("unsupported interval: %q", query.Interval)
+	}
+	splitExpr := "''"
+	if query.SplitBy != "" {
+		col, ok := seriesSplitColumns[query.SplitBy]
+		if !ok {
+			return nil, fmt.Errorf("unsupported split dimension: %q", query.SplitBy)
+		}
+		splitExpr = col
+	}
+
+	buckets := []LLMCallLogBucket{}
+	err := r.db.WithContext(ctx).Model(&model.LLMCallLog{}).
+		Where("created_at >= ? AND created_at < ?", query.StartTime, query.EndTime).
+		Select(`date_trunc(?, created_at) AS bucket,
+			`+splitExpr+` AS split_key,
+			COUNT(*) AS call_count,
+			COUNT(CASE WHEN status != 1 THEN 1 END) AS failed_count,
+			COUNT(CASE WHEN status != 1 THEN 1 END)::float8 / COUNT(*) AS error_rate,
+			COALESCE(SUM(total_tokens), 0) AS total_tokens,
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms) FILTER (WHERE status = 1), 0) AS p50_ms,
+			COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY duration_ms) FILTER (WHERE status = 1), 0) AS p95_ms,
+			COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY duration_ms) FILTER (WHERE status = 1), 0) AS p99_ms`,
+			query.Interval).
+		Group("1, 2").
+		Order("1, 2").
+		Scan(&buckets).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 各时间桶费用按币种汇总
+	var bucketCosts []currencyCostRow
+	err = r.db.WithContext(ctx).Model(&model.LLMCallLog{}).
+		Where("created_at >= ? AND created_at < ? AND cost > 0", query.StartTime, query.EndTime).
+		Select(`date_trunc(?, created_at) AS bucket, `+splitExpr+` AS group_key, currency, SUM(cost) AS cost`, query.Interval).
+		Group("1, 2, 3").
+		Order("1, 2, 3").
+		Scan(&bucketCosts).Error
+	if err != nil {
+		return nil, err
+	}
+	type bucketKey struct {
+		bucket int64
+		split  string
+	}
+	costs := map[bucketKey][]LLMCurrencyCost{}
+	for _, row := range bucketCosts {
+		k := bucketKey{row.Bucket.UnixNano(), row.Key}
+		costs[k] = append(costs[k], LLMCurrencyCost{Currency: row.Currency, Cost: row.Cost})
+	}
+	for i := range buckets {
+		buckets[i].Costs = nonNilCosts(costs[bucketKey{buckets[i].Bucket.UnixNano(), buckets[i].SplitKey}])
+	}
+
+	topUsers := []LLMCallLogUserStats{}
+	if query.TopN > 0 {
+		err = r.db.WithContext(ctx).Model(&model.LLMCallLog{}).
+			Where("created_at >= ? AND created_at < ?", query.StartTime, query.EndTime).
+			Select(`user_id,
+				COUNT(*) AS call_count,
+				COALESCE(SUM(total_tokens), 0) AS total_tokens`).
+			Group("user_id").
+			Order("total_tokens DESC, user_id").
+			Limit(query.TopN).
+			Scan(&topUsers).Error
+		if err != nil {
+			return nil, err
+		}
+		if err := r.fillUserCosts(ctx, query, topUsers); err != nil {
+			return nil, err
+		}
+	}
+
+	return &LLMCallLogSeries{
+		StartTime: query.StartTime,
+		EndTime:   query.EndTime,
+		Interval:  query.Interval,
+		SplitBy:   query.SplitBy,
+		Buckets:   buckets,
+		TopUsers:  topUsers,
+	}, nil
+}
+
+// fillUserCosts 按币种汇总用户费用
+func (r *LLMCallLogRepo) fillUserCosts(ctx context.Context, query LLMCallLogSeriesQuery, users []LLMCallLogUserStats) error {
+	if len(users) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(users))
+	for i, u := range users {
+		ids[i] = u.UserID
+	}
+	var rows []struct {
+		UserID   int64
+		Currency string
+		Cost     float64
+	}
+	err := r.db.WithContext(ctx).Model(&model.LLMCallLog{}).
+		Where("created_at >= ? AND created_at < ? AND cost > 0 AND user_id IN ?", query.StartTime, query.EndTime, ids).
+		Select("user_id, currency, SUM(cost) AS cost").
+		Group("user_id, currency").
+		Order("user_id, currency").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	costs := map[int64][]LLMCurrencyCost{}
+	for _, row := range rows {
+		costs[row.UserID] = append(costs[row.UserID], LLMCurrencyCost{Currency: row.Currency, Cost: row.Cost})
+	}
+	for i := range users {
+		users[i].Costs = nonNilCosts(costs[users[i].UserID])
+	}
+	return nil
+}
+
+// nonNilCosts 无费用时返回空数组，JSON 输出 [] 而不是 null
+func nonNilCosts(costs []LLMCurrencyCost) []LLMCurrencyCost {
+	if costs == nil {
+		return []LLMCurrencyCost{}
+	}
+	return costs
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// recordedQuery 替身数据库收到的一条语句及其绑定参数
+type recordedQuery struct {
+	sql  string
+	args []driver.NamedValue
+}
+
+// recorder 记录语句的 Postgres 替身：不连接真实数据库，所有查询返回空结果集
+type recorder struct {
+	mu      sync.Mutex
+	queries []recordedQuery
+}
+
+func (r *recorder) Connect(context.Context) (driver.Conn, error) { return &recorderConn{r: r}, nil }
+func (r *recorder) Driver() driver.Driver                        { return nil }
+
+func (r *recorder) all() []recordedQuery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]recordedQuery(nil), r.queries...)
+}
+
+type recorderConn struct{ r *recorder }
+
+func (c *recorderConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepare not supported")
+}
+func (c *recorderConn) Close() error              { return nil }
+func (c *recorderConn) Begin() (driver.Tx, error) { return nil, fmt.Errorf("tx not supported") }
+
+func (c *recorderConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.r.mu.Lock()
+	c.r.queries = append(c.r.queries, recordedQuery{sql: query, args: args})
+	c.r.mu.Unlock()
+	return emptyRows{}, nil
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+// newRecordingRepo 基于替身数据库创建调用日志仓库
+func newRecordingRepo(t *testing.T) (*LLMCallLogRepo, *recorder) {
+	t.Helper()
+	rec := &recorder{}
+	sqlDB := sql.OpenDB(rec)
+	t.Cleanup(func() { sqlDB.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 logger.Discard,
+	})
+	if err != nil {
+		t.Fatalf("open gorm: %v", err)
+	}
+	return NewLLMCallLogRepo(db), rec
+}
+
+// hostileInputs 常见的注入片段
+var hostileInputs = []string{
+	"provider; DROP TABLE llm_call_logs; --",
+	"1) UNION SELECT password FROM users --",
+	"' OR '1'='1",
+	"pg_sleep(10)",
+}
+
+// nearMissDimensions 与合法维度相近但不在白名单内的取值
+var nearMissDimensions = []string{"provider\x00", "PROVIDER", " provider", "provider,purpose", "created_at"}
+
+// assertNotInSQL 断言输入没有出现在任何语句中
+func assertNotInSQL(t *testing.T, queries []recordedQuery, input string) {
+	t.Helper()
+	for _, q := range queries {
+		if strings.Contains(q.sql, input) {
+			t.Fatalf("input %q was concatenated into SQL: %s", input, q.sql)
+		}
+	}
+}
+
+// assertBound 断言输入作为绑定参数传入
+func assertBound(t *testing.T, queries []recordedQuery, input string) {
+	t.Helper()
+	for _, q := range queries {
+		for _, a := range q.args {
+			if s, ok := a.Value.(string); ok && s == input {
+				return
+			}
+		}
+	}
+	t.Fatalf("input %q was not bound as a parameter", input)
+}
+
+func TestStatsRejectsUnknownGroupBy(t *testing.T) {
+	repo, rec := newRecordingRepo(t)
+	for _, input := range append(hostileInputs, nearMissDimensions...) {
+		_, _, err := repo.Stats(context.Background(), LLMCallLogStatsQuery{GroupBy: LLMStatsDimension(input)})
+		if err == nil {
+			t.Fatalf("group_by %q: expected error", input)
+		}
+	}
+	if n := len(rec.all()); n != 0 {
+		t.Fatalf("expected no queries for rejected group_by, got %d", n)
+	}
+}
+
+func TestStatsGroupByUsesWhitelistedExpr(t *testing.T) {
+	for dim, expr := range statsDimensionExprs {
+		repo, rec := newRecordingRepo(t)
+		if _, _, err := repo.Stats(context.Background(), LLMCallLogStatsQuery{GroupBy: dim}); err != nil {
+			t.Fatalf("group_by %q: %v", dim, err)
+		}
+		queries := rec.all()
+		last := queries[len(queries)-1].sql
+		if !strings.Contains(last, expr+" AS group_key") {
+			t.Fatalf("group_by %q: expected expression %q in %s", dim, expr, last)
+		}
+	}
+}
+
+func TestStatsBindsTimeRange(t *testing.T) {
+	repo, rec := newRecordingRepo(t)
+	// 起止时间颠倒时仓库不做拼接，只作为参数传入，结果为空
+	start := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, _, err := repo.Stats(context.Background(), LLMCallLogStatsQuery{StartTime: &start, EndTime: &end}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	queries := rec.all()
+	if len(queries) != 4 {
+		t.Fatalf("expected 4 queries, got %d", len(queries))
+	}
+	for _, q := range queries {
+		if strings.Contains(q.sql, "2026") {
+			t.Fatalf("time range was concatenated into SQL: %s", q.sql)
+		}
+		var times int
+		for _, a := range q.args {
+			if _, ok := a.Value.(time.Time); ok {
+				times++
+			}
+		}
+		if times != 2 {
+			t.Fatalf("expected start and end bound as parameters, got %v in %s", q.args, q.sql)
+		}
+	}
+}
+
+func TestGroupedCostsSplitByCurrency(t *testing.T) {
+	repo, rec := newRecordingRepo(t)
+	if _, _, err := repo.Stats(context.Background(), LLMCallLogStatsQuery{GroupBy: LLMStatsByModel}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	end := time.Now()
+	if _, err := repo.Series(context.Background(), LLMCallLogSeriesQuery{StartTime: end.Add(-time.Hour), EndTime: end, Interval: "hour", SplitBy: "provider"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// 分组与时间桶的费用不跨币种相加
+	for _, q := range rec.all() {
+		if strings.Contains(q.sql, "SUM(cost)") && strings.Contains(q.sql, "GROUP BY") && !strings.Contains(q.sql, "currency") {
+			t.Fatalf("cost summed without currency: %s", q.sql)
+		}
+	}
+}
+
+func TestSeriesRejectsHostileIntervalAndSplit(t *testing.T) {
+	repo, rec := newRecordingRepo(t)
+	end := time.Now()
+	start := end.Add(-time.Hour)
+	for _, input := range append(append(hostileInputs, nearMissDimensions...), "minute", "'day'") {
+		if _, err := repo.Series(context.Background(), LLMCallLogSeriesQuery{StartTime: start, EndTime: end, Interval: input}); err == nil {
+			t.Fatalf("interval %q: expected error", input)
+		}
+		if _, err := repo.Series(context.Background(), LLMCallLogSeriesQuery{StartTime: start, EndTime: end, Interval: "day", SplitBy: input}); err == nil {
+			t.Fatalf("split_by %q: expected error", input)
+		}
+	}
+	if n := len(rec.all()); n != 0 {
+		t.Fatalf("expected no queries for rejected input, got %d", n)
+	}
+}
+
+func TestSeriesBindsIntervalAndInvertedRange(t *testing.T) {
+	repo, rec := newRecordingRepo(t)
+	start := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := repo.Series(context.Background(), LLMCallLogSeriesQuery{StartTime: start, EndTime: end, Interval: "week", TopN: 5}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	queries := rec.all()
+	if len(queries) != 3 {
+		t.Fatalf("expected 3 queries, got %d", len(queries))
+	}
+	assertNotInSQL(t, queries, "'week'")
+	assertNotInSQL(t, queries, "2026")
+	assertBound(t, queries, "week")
+}
+
+func TestListBindsHostileFilters(t *testing.T) {
+	for _, input := range hostileInputs {
+		repo, rec := newRecordingRepo(t)
+		_, _, err := repo.List(context.Background(), LLMCallLogListQuery{Purpose: input, Provider: input, Sort: input})
+		if err != nil {
+			t.Fatalf("filter %q: unexpected error %v", input, err)
+		}
+		queries := rec.all()
+		assertNotInSQL(t, queries, input)
+		assertBound(t, queries, input)
+		// 未知排序回退到默认排序
+		if last := queries[len(queries)-1].sql; !strings.Contains(last, "ORDER BY created_at DESC") {
+			t.Fatalf("sort %q: expected default order in %s", input, last)
+		}
+	}
+}
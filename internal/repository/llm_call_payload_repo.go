@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"manjing-ai-go/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// LLMCallPayloadRepository 调用内容采样数据访问接口
+type LLMCallPayloadRepository interface {
+	Create(ctx context.Context, p *model.LLMCallPayload) error
+	FindByCallLogID(ctx context.Context, callLogID int64) (*model.LLMCallPayload, error)
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// LLMCallPayloadRepo 调用内容采样仓库实现
+type LLMCallPayloadRepo struct {
+	db *gorm.DB
+}
+
+// NewLLMCallPayloadRepo 创建调用内容采样仓库
+func NewLLMCallPayloadRepo(db *gorm.DB) *LLMCallPayloadRepo {
+	return &LLMCallPayloadRepo{db: db}
+}
+
+func (r *LLMCallPayloadRepo) Create(ctx context.Context, p *model.LLMCallPayload) error {
+	return r.db.WithContext(ctx).Create(p).Error
+}
+
+// FindByCallLogID 查询未过期的采样内容
+func (r *LLMCallPayloadRepo) FindByCallLogID(ctx context.Context, callLogID int64) (*model.LLMCallPayload, error) {
+	var p model.LLMCallPayload
+	err := r.db.WithContext(ctx).Where("call_log_id = ? AND expires_at > ?", callLogID, time.Now()).First(&p).Error
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// DeleteExpired 删除已过期的采样内容
+func (r *LLMCallPayloadRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&model.LLMCallPayload{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"manjing-ai-go/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// LLMConversationRepository 会话数据访问接口
+type LLMConversationRepository interface {
+	Create(ctx context.Context, conv *model.LLMConversation) error
+	Update(ctx context.Context, id int64, updates map[string]interface{}) error
+	FindByID(ctx context.Context, id int64) (*model.LLMConversation, error)
+	List(ctx context.Context, userID int64, query LLMConversationListQuery) ([]model.LLMConversation, int64, error)
+	ListMessages(ctx context.Context, conversationID int64) ([]model.LLMMessage, error)
+	AppendMessages(ctx context.Context, conversationID int64, msgs []*model.LLMMessage) error
+}
+
+// LLMConversationListQuery 会话列表查询参数
+type LLMConversationListQuery struct {
+	Page      int
+	PageSize  int
+	ProjectID *int64
+	ChapterID *int64
+}
+
+// LLMConversationRepo 会话仓库实现
+type LLMConversationRepo struct {
+	db *gorm.DB
+}
+
+// NewLLMConversationRepo 创建会话仓库
+func NewLLMConversationRepo(db *gorm.DB) *LLMConversationRepo {
+	return &LLMConversationRepo{db: db}
+}
+
+func (r *LLMConversationRepo) Create(ctx context.Context, conv *model.LLMConversation) error {
+	return r.db.WithContext(ctx).Create(conv).Error
+}
+
+func (r *LLMConversationRepo) Update(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&model.LLMConversation{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *LLMConversationRepo) FindByID(ctx context.Context, id int64) (*model.LLMConversation, error) {
+	var conv model.LLMConversation
+	if err := r.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).First(&conv).Error; err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+func (r *LLMConversationRepo) List(ctx context.Context, userID int64, query LLMConversationListQuery) ([]model.LLMConversation, int64, error) {
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 20
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+
+	db := r.db.WithContext(ctx).Model(&model.LLMConversation{}).Where("user_id = ? AND deleted_at IS NULL", userID)
+	if query.ProjectID != nil {
+		db = db.Where("project_id = ?", *query.ProjectID)
+	}
+	if query.ChapterID != nil {
+		db = db.Where("chapter_id = ?", *query.ChapterID)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []model.LLMConversation
+	offset := (query.Page - 1) * query.PageSize
+	if err := db.Order("updated_at DESC").Offset(offset).Limit(query.PageSize).Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// ListMessages 会话全部消息，按时间正序
+func (r *LLMConversationRepo) ListMessages(ctx context.Context, conversationID int64) ([]model.LLMMessage, error) {
+	var items []model.LLMMessage
+	err := r.db.WithContext(ctx).Where("conversation_id = ?", conversationID).Order("id ASC").Find(&items).Error
+	return items, err
+}
+
+// AppendMessages 在同一事务中写入消息并更新会话消息数
+func (r *LLMConversationRepo) AppendMessages(ctx context.Context, conversationID int64, msgs []*model.LLMMessage) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, m := range msgs {
+			m.ConversationID = conversationID
+			if err := tx.Create(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&model.LLMConversation{}).Where("id = ?", conversationID).Updates(map[string]interface{}{
+			"message_count": gorm.Expr("message_count + ?", len(msgs)),
+			"updated_at":    time.Now(),
+		}).Error
+	})
+}
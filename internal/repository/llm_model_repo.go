@@ -12,9 +12,13 @@ import (
 type LLMModelRepository interface {
 	Create(ctx context.Context, m *model.LLMModel) error
 	Update(ctx context.Context, id int64, updates map[string]interface{}) error
+	UpdateColumns(ctx context.Context, id int64, updates map[string]interface{}) error
 	FindByID(ctx context.Context, id int64) (*model.LLMModel, error)
 	FindActiveByPurpose(ctx context.Context, purpose string) (*model.LLMModel, error)
+	FindActiveChainByPurpose(ctx context.Context, purpose string) ([]model.LLMModel, error)
 	List(ctx context.Context, query LLMModelListQuery) ([]model.LLMModel, int64, error)
+	FindAll(ctx context.Context) ([]model.LLMModel, error)
+	ListActive(ctx context.Context) ([]model.LLMModel, error)
 }
 
 // LLMModelListQuery 模型配置列表查询参数
@@ -44,6 +48,11 @@ func (r *LLMModelRepo) Update(ctx context.Context, id int64, updates map[string]
 	return r.db.WithContext(ctx).Model(&model.LLMModel{}).Where("id = ?", id).Updates(updates).Error
 }
 
+// UpdateColumns 更新指定字段，不刷新 updated_at
+func (r *LLMModelRepo) UpdateColumns(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&model.LLMModel{}).Where("id = ?", id).UpdateColumns(updates).Error
+}
+
 func (r *LLMModelRepo) FindByID(ctx context.Context, id int64) (*model.LLMModel, error) {
 	var m model.LLMModel
 	if err := r.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).First(&m).Error; err != nil {
@@ -52,12 +61,12 @@ func (r *LLMModelRepo) FindByID(ctx context.Context, id int64) (*model.LLMModel,
 	return &m, nil
 }
 
-// FindActiveByPurpose 查找指定用途的启用模型
+// FindActiveByPurpose 查找指定用途的首选启用模型，跳过不健康的模型
 func (r *LLMModelRepo) FindActiveByPurpose(ctx context.Context, purpose string) (*model.LLMModel, error) {
 	var m model.LLMModel
 	err := r.db.WithContext(ctx).
-		Where("purpose = ? AND is_active = true AND deleted_at IS NULL", purpose).
-		Order("updated_at DESC").
+		Where("purpose = ? AND is_active = true AND deleted_at IS NULL AND health_status <> ?", purpose, model.LLMModelHealthUnhealthy).
+		Order("priority ASC, updated_at DESC").
 		First(&m).Error
 	if err != nil {
 		return nil, err
@@ -65,6 +74,16 @@ func (r *LLMModelRepo) FindActiveByPurpose(ctx context.Context, purpose string)
 	return &m, nil
 }
 
+// FindActiveChainByPurpose 按降级顺序返回指定用途的全部启用模型，跳过不健康的模型
+func (r *LLMModelRepo) FindActiveChainByPurpose(ctx context.Context, purpose string) ([]model.LLMModel, error) {
+	var items []model.LLMModel
+	err := r.db.WithContext(ctx).
+		Where("purpose = ? AND is_active = true AND deleted_at IS NULL AND health_status <> ?", purpose, model.LLMModelHealthUnhealthy).
+		Order("priority ASC, updated_at DESC").
+		Find(&items).Error
+	return items, err
+}
+
 func (r *LLMModelRepo) List(ctx context.Context, query LLMModelListQuery) ([]model.LLMModel, int64, error) {
 	if query.Page <= 0 {
 		query.Page = 1
@@ -100,3 +119,20 @@ func (r *LLMModelRepo) List(ctx context.Context, query LLMModelListQuery) ([]mod
 		Find(&items).Error
 	return items, total, err
 }
+
+// FindAll 返回全部模型配置（含已删除），用于密钥加密与轮换
+func (r *LLMModelRepo) FindAll(ctx context.Context) ([]model.LLMModel, error) {
+	var items []model.LLMModel
+	err := r.db.WithContext(ctx).Order("id ASC").Find(&items).Error
+	return items, err
+}
+
+// ListActive 返回全部启用的模型配置（含不健康的），用于健康检查
+func (r *LLMModelRepo) ListActive(ctx context.Context) ([]model.LLMModel, error) {
+	var items []model.LLMModel
+	err := r.db.WithContext(ctx).
+		Where("is_active = true AND deleted_at IS NULL").
+		Order("id ASC").
+		Find(&items).Error
+	return items, err
+}
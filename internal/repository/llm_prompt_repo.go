@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"manjing-ai-go/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LLMPromptRepository 提示词模板数据访问接口
+type LLMPromptRepository interface {
+	CreateNextVersion(ctx context.Context, p *model.LLMPrompt) error
+	Update(ctx context.Context, id int64, updates map[string]interface{}) error
+	FindByID(ctx context.Context, id int64) (*model.LLMPrompt, error)
+	FindLatestActiveByPurpose(ctx context.Context, purpose string) (*model.LLMPrompt, error)
+	MaxVersion(ctx context.Context, purpose string) (int, error)
+	List(ctx context.Context, query LLMPromptListQuery) ([]model.LLMPrompt, int64, error)
+}
+
+// LLMPromptListQuery 提示词模板列表查询参数
+type LLMPromptListQuery struct {
+	Page     int
+	PageSize int
+	Purpose  string
+	IsActive *bool
+}
+
+// LLMPromptRepo 提示词模板仓库实现
+type LLMPromptRepo struct {
+	db *gorm.DB
+}
+
+// NewLLMPromptRepo 创建提示词模板仓库
+func NewLLMPromptRepo(db *gorm.DB) *LLMPromptRepo {
+	return &LLMPromptRepo{db: db}
+}
+
+// promptVersionAttempts 并发创建同用途版本时的最大取号次数
+const promptVersionAttempts = 5
+
+// CreateNextVersion 以同用途最大版本号+1 创建新版本并回填 p.Version；
+// 并发创建取到同一版本号时由唯一索引 idx_llm_prompts_purpose_version 拦下，重新取号
+func (r *LLMPromptRepo) CreateNextVersion(ctx context.Context, p *model.LLMPrompt) error {
+	for i := 0; i < promptVersionAttempts; i++ {
+		maxVersion, err := r.MaxVersion(ctx, p.Purpose)
+		if err != nil {
+			return err
+		}
+		p.Version = maxVersion + 1
+		res := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "purpose"}, {Name: "version"}},
+			DoNothing: true,
+		}).Create(p)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			return nil
+		}
+	}
+	return errors.New("提示词版本号冲突，请重试")
+}
+
+func (r *LLMPromptRepo) Update(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&model.LLMPrompt{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *LLMPromptRepo) FindByID(ctx context.Context, id int64) (*model.LLMPrompt, error) {
+	var p model.LLMPrompt
+	if err := r.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).First(&p).Error; err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// FindLatestActiveByPurpose 查找指定用途的最新启用版本
+func (r *LLMPromptRepo) FindLatestActiveByPurpose(ctx context.Context, purpose string) (*model.LLMPrompt, error) {
+	var p model.LLMPrompt
+	err := r.db.WithContext(ctx).
+		Where("purpose = ? AND is_active = true AND deleted_at IS NULL", purpose).
+		Order("version DESC").
+		First(&p).Error
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// MaxVersion 返回指定用途的最大版本号（含已删除版本，保证版本号不复用）
+func (r *LLMPromptRepo) MaxVersion(ctx context.Context, purpose string) (int, error) {
+	var v int
+	err := r.db.WithContext(ctx).Model(&model.LLMPrompt{}).
+		Where("purpose = ?", purpose).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&v).Error
+	return v, err
+}
+
+func (r *LLMPromptRepo) List(ctx context.Context, query LLMPromptListQuery) ([]model.LLMPrompt, int64, error) {
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 20
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+
+	db := r.db.WithContext(ctx).Model(&model.LLMPrompt{}).Where("deleted_at IS NULL")
+	if query.Purpose != "" {
+		db = db.Where("purpose = ?", query.Purpose)
+	}
+	if query.IsActive != nil {
+		db = db.Where("is_active = ?", *query.IsActive)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var items []model.LLMPrompt
+	err := db.Order("purpose ASC, version DESC").
+		Offset((query.Page - 1) * query.PageSize).
+		Limit(query.PageSize).
+		Find(&items).Error
+	return items, total, err
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+
+	"manjing-ai-go/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// SubjectRepository 主体数据访问接口
+type SubjectRepository interface {
+	Create(ctx context.Context, subject *model.Subject) error
+	Update(ctx context.Context, id int64, updates map[string]interface{}) error
+	FindByID(ctx context.Context, id int64) (*model.Subject, error)
+	List(ctx context.Context, projectID int64, query SubjectListQuery) ([]model.Subject, int64, error)
+	ListAllByProject(ctx context.Context, projectID int64) ([]model.Subject, error)
+}
+
+// SubjectListQuery 主体列表查询
+type SubjectListQuery struct {
+	Page     int
+	PageSize int
+	Keyword  string
+}
+
+// SubjectRepo 实现
+type SubjectRepo struct {
+	db *gorm.DB
+}
+
+// NewSubjectRepo 创建仓库
+func NewSubjectRepo(db *gorm.DB) *SubjectRepo {
+	return &SubjectRepo{db: db}
+}
+
+func (r *SubjectRepo) Create(ctx context.Context, subject *model.Subject) error {
+	return r.db.WithContext(ctx).Create(subject).Error
+}
+
+func (r *SubjectRepo) Update(ctx context.Context, id int64, updates map[string]interface{}) error {
+	return r.db.WithContext(ctx).Model(&model.Subject{}).Where("id = ?", id).Updates(updates).Error
+}
+
+func (r *SubjectRepo) FindByID(ctx context.Context, id int64) (*model.Subject, error) {
+	var subject model.Subject
+	if err := r.db.WithContext(ctx).Where("id = ? AND deleted_at IS NULL", id).First(&subject).Error; err != nil {
+		return nil, err
+	}
+	return &subject, nil
+}
+
+func (r *SubjectRepo) List(ctx context.Context, projectID int64, query SubjectListQuery) ([]model.Subject, int64, error) {
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+	if query.PageSize <= 0 {
+		query.PageSize = 20
+	}
+	if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+
+	db := r.db.WithContext(ctx).Model(&model.Subject{}).Where("project_id = ? AND deleted_at IS NULL", projectID)
+	if query.Keyword != "" {
+		like := "%" + query.Keyword + "%"
+		db = db.Where("name ILIKE ? OR aliases::text ILIKE ?", like, like)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var items []model.Subject
+	offset := (query.Page - 1) * query.PageSize
+	if err := db.Order("id ASC").Offset(offset).Limit(query.PageSize).Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+// ListAllByProject 项目下全部未删除主体
+func (r *SubjectRepo) ListAllByProject(ctx context.Context, projectID int64) ([]model.Subject, error) {
+	var items []model.Subject
+	err := r.db.WithContext(ctx).Where("project_id = ? AND deleted_at IS NULL", projectID).Order("id ASC").Find(&items).Error
+	return items, err
+}
@@ -4,6 +4,8 @@ import (
 	"manjing-ai-go/config"
 	"manjing-ai-go/internal/handler"
 	"manjing-ai-go/internal/middleware"
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
 	redisclient "manjing-ai-go/pkg/redis"
 	swaggerDocs "manjing-ai-go/swagger"
 
@@ -13,7 +15,7 @@ import (
 )
 
 // NewRouter 构建路由
-func NewRouter(cfg *config.Config, authHandler *handler.AuthHandler, resHandler *handler.ResourceHandler, projectHandler *handler.ProjectHandler, chapterHandler *handler.ChapterHandler, emailHandler *handler.EmailHandler, voiceHandler *handler.VoiceHandler, llmHandler *handler.LLMHandler, rdb *redisclient.Client) *gin.Engine {
+func NewRouter(cfg *config.Config, authHandler *handler.AuthHandler, resHandler *handler.ResourceHandler, projectHandler *handler.ProjectHandler, chapterHandler *handler.ChapterHandler, subjectHandler *handler.SubjectHandler, emailHandler *handler.EmailHandler, voiceHandler *handler.VoiceHandler, llmHandler *handler.LLMHandler, convHandler *handler.LLMConversationHandler, batchHandler *handler.LLMBatchHandler, userRepo repository.UserRepository, rdb *redisclient.Client) *gin.Engine {
 	r := gin.New()
 	r.Use(gin.Recovery())
 
@@ -54,6 +56,7 @@ func NewRouter(cfg *config.Config, authHandler *handler.AuthHandler, resHandler
 
 	v1 := r.Group("/v1")
 	v1.Use(middleware.AuthMiddleware(cfg.JWT, rdb))
+	adminOnly := middleware.RequireRole(userRepo, model.UserRoleAdmin)
 	{
 		v1.POST("/resources", resHandler.Upload)
 		v1.GET("/resources", resHandler.List)
@@ -69,6 +72,14 @@ func NewRouter(cfg *config.Config, authHandler *handler.AuthHandler, resHandler
 		v1.POST("/projects/:id/restore", projectHandler.Restore)
 		v1.POST("/projects/:id/archive", projectHandler.Archive)
 
+		v1.POST("/projects/:id/subjects/extract", subjectHandler.Extract)
+		v1.POST("/projects/:id/subjects/merge", subjectHandler.Merge)
+		v1.POST("/projects/:id/subjects", subjectHandler.Create)
+		v1.GET("/projects/:id/subjects", subjectHandler.List)
+		v1.GET("/subjects/:id", subjectHandler.Detail)
+		v1.PUT("/subjects/:id", subjectHandler.Update)
+		v1.DELETE("/subjects/:id", subjectHandler.Delete)
+
 		v1.POST("/chapters", chapterHandler.Create)
 		v1.GET("/chapters", chapterHandler.List)
 		v1.GET("/chapters/:id", chapterHandler.Detail)
@@ -76,6 +87,8 @@ func NewRouter(cfg *config.Config, authHandler *handler.AuthHandler, resHandler
 		v1.DELETE("/chapters/:id", chapterHandler.Delete)
 		v1.POST("/chapters/:id/restore", chapterHandler.Restore)
 		v1.POST("/chapters/:id/archive", chapterHandler.Archive)
+		v1.POST("/chapters/:id/parse", chapterHandler.Parse)
+		v1.GET("/chapters/:id/parse", chapterHandler.ParseResult)
 
 		v1.POST("/voices", voiceHandler.Create)
 		v1.GET("/voices", voiceHandler.List)
@@ -83,17 +96,44 @@ func NewRouter(cfg *config.Config, authHandler *handler.AuthHandler, resHandler
 		v1.PUT("/voices/:id", voiceHandler.Update)
 		v1.DELETE("/voices/:id", voiceHandler.Delete)
 
-		// LLM 模型配置
-		v1.POST("/llm/models", llmHandler.CreateModel)
-		v1.GET("/llm/models", llmHandler.ListModels)
-		v1.GET("/llm/models/:id", llmHandler.ModelDetail)
-		v1.PUT("/llm/models/:id", llmHandler.UpdateModel)
-		v1.DELETE("/llm/models/:id", llmHandler.DeleteModel)
+		// LLM 模型配置（仅管理员）
+		v1.POST("/llm/models", adminOnly, llmHandler.CreateModel)
+		v1.GET("/llm/models", adminOnly, llmHandler.ListModels)
+		v1.GET("/llm/models/:id", adminOnly, llmHandler.ModelDetail)
+		v1.PUT("/llm/models/:id", adminOnly, llmHandler.UpdateModel)
+		v1.DELETE("/llm/models/:id", adminOnly, llmHandler.DeleteModel)
+		v1.POST("/llm/models/:id/test", adminOnly, llmHandler.TestModel)
+		// LLM 提示词模板：所有用户的调用共用，修改仅管理员
+		v1.POST("/llm/prompts", adminOnly, llmHandler.CreatePrompt)
+		v1.GET("/llm/prompts", llmHandler.ListPrompts)
+		v1.GET("/llm/prompts/:id", llmHandler.PromptDetail)
+		v1.PUT("/llm/prompts/:id", adminOnly, llmHandler.UpdatePrompt)
+		v1.DELETE("/llm/prompts/:id", adminOnly, llmHandler.DeletePrompt)
 		// LLM 对话
 		v1.POST("/llm/chat", llmHandler.Chat)
-		// LLM 调用日志
-		v1.GET("/llm/logs", llmHandler.ListLogs)
-		v1.GET("/llm/logs/stats", llmHandler.LogStats)
+		v1.GET("/llm/tools", llmHandler.ListTools)
+		v1.POST("/llm/tokenize", llmHandler.Tokenize)
+		v1.DELETE("/llm/cache", adminOnly, llmHandler.PurgeCache)
+		// LLM 多轮会话
+		v1.POST("/llm/conversations", convHandler.Create)
+		v1.GET("/llm/conversations", convHandler.List)
+		v1.GET("/llm/conversations/:id", convHandler.Detail)
+		v1.DELETE("/llm/conversations/:id", convHandler.Delete)
+		v1.POST("/llm/conversations/:id/messages", convHandler.Send)
+		// LLM 批量任务
+		v1.POST("/llm/batches", batchHandler.Submit)
+		v1.GET("/llm/batches", batchHandler.List)
+		v1.GET("/llm/batches/:id", batchHandler.Detail)
+		v1.POST("/llm/batches/:id/cancel", batchHandler.Cancel)
+		v1.GET("/llm/batches/:id/jobs", batchHandler.Jobs)
+		v1.POST("/llm/batches/:id/retry", batchHandler.Retry)
+		v1.POST("/llm/batches/:id/jobs/:job_id/retry", batchHandler.RetryJob)
+		// LLM 调用日志：非管理员只能查看自己的调用，统计与调用内容仅管理员
+		v1.GET("/llm/logs", middleware.LoadRole(userRepo), llmHandler.ListLogs)
+		v1.GET("/llm/logs/stats", adminOnly, llmHandler.LogStats)
+		v1.GET("/llm/logs/:id/payload", adminOnly, llmHandler.LogPayload)
+		// LLM 用量配额
+		v1.GET("/llm/usage/me", llmHandler.UsageMe)
 	}
 
 	return r
@@ -0,0 +1,412 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/pkg/llm"
+	"manjing-ai-go/pkg/tokenizer"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ChapterParsePurpose 章节解析使用的模型用途
+const ChapterParsePurpose = "chapter_parse"
+
+// chapterParseOutputRatio 解析输出的 JSON 包含原文全部对白与旁白，Token 数按原文的 2 倍预估
+const chapterParseOutputRatio = 2
+
+// ErrChapterParseBusy 同时运行的解析任务数已达上限
+var ErrChapterParseBusy = errors.New("解析任务繁忙，请稍后重试")
+
+// ChapterParseService 章节解析服务
+type ChapterParseService interface {
+	StartParse(ctx context.Context, userID, chapterID int64) (*model.ChapterParse, error)
+	GetParse(ctx context.Context, userID, chapterID int64) (*model.ChapterParse, error)
+}
+
+// ChapterParseServiceImpl 实现
+type ChapterParseServiceImpl struct {
+	repo       repository.ChapterParseRepository
+	chapterSvc ChapterService
+	llmSvc     LLMService
+	cfg        config.LLMChapterParseConfig
+	sem        chan struct{} // 限制同时运行的解析任务数，在任务落库前占用，不排队
+}
+
+// NewChapterParseService 创建服务
+func NewChapterParseService(repo repository.ChapterParseRepository, chapterSvc ChapterService, llmSvc LLMService, cfg config.LLMChapterParseConfig) *ChapterParseServiceImpl {
+	if cfg.ChunkChars <= 0 {
+		cfg.ChunkChars = 3000
+	}
+	if cfg.TimeoutSeconds <= 0 {
+		cfg.TimeoutSeconds = 600
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 2
+	}
+	return &ChapterParseServiceImpl{
+		repo:       repo,
+		chapterSvc: chapterSvc,
+		llmSvc:     llmSvc,
+		cfg:        cfg,
+		sem:        make(chan struct{}, cfg.Concurrency),
+	}
+}
+
+// StartParse 创建解析任务并在后台执行；同一章节已有进行中的任务时直接返回该任务，
+// 运行中的任务数已达上限时返回 ErrChapterParseBusy
+func (s *ChapterParseServiceImpl) StartParse(ctx context.Context, userID, chapterID int64) (*model.ChapterParse, error) {
+	chapter, err := s.chapterSvc.Get(ctx, userID, chapterID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(chapter.Content) == "" {
+		return nil, errors.New("章节内容为空")
+	}
+
+	latest, err := s.repo.FindLatestByChapter(ctx, chapterID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if latest != nil && s.inProgress(latest) {
+		return latest, nil
+	}
+
+	// 先占用名额再落库：任务一经创建即开始执行，不会因排队超过超时时间被判定为失效
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		return nil, ErrChapterParseBusy
+	}
+	started := false
+	defer func() {
+		if !started {
+			<-s.sem
+		}
+	}()
+
+	chunks := s.splitChunks(ctx, chapter.Name, chapter.Content)
+	now := time.Now()
+	p := &model.ChapterParse{
+		ChapterID:   chapter.ID,
+		ProjectID:   chapter.ProjectID,
+		UserID:      userID,
+		Status:      model.ChapterParseStatusPending,
+		TotalChunks: len(chunks),
+		ContentHash: contentHash(chapter.Content),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	timeout := time.Duration(s.cfg.TimeoutSeconds) * time.Second
+	if err := s.repo.ExpireStale(ctx, chapterID, now.Add(-timeout)); err != nil {
+		return nil, err
+	}
+	created, err := s.repo.CreateIfIdle(ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		// 并发请求已创建了任务
+		return s.repo.FindLatestByChapter(ctx, chapterID)
+	}
+
+	started = true
+	go s.run(p.ID, userID, chapter.Name, chunks)
+	return p, nil
+}
+
+// GetParse 查询章节最近一次解析
+func (s *ChapterParseServiceImpl) GetParse(ctx context.Context, userID, chapterID int64) (*model.ChapterParse, error) {
+	if _, err := s.chapterSvc.Get(ctx, userID, chapterID); err != nil {
+		return nil, err
+	}
+	p, err := s.repo.FindLatestByChapter(ctx, chapterID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("解析任务不存在")
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+// inProgress 任务未结束且未超时（进程重启会遗留未结束的任务，超时后允许重新发起）
+func (s *ChapterParseServiceImpl) inProgress(p *model.ChapterParse) bool {
+	if p.Status != model.ChapterParseStatusPending && p.Status != model.ChapterParseStatusRunning {
+		return false
+	}
+	return time.Since(p.UpdatedAt) < time.Duration(s.cfg.TimeoutSeconds)*time.Second
+}
+
+// run 后台执行解析，逐段调用模型并记录进度；结束时释放 StartParse 占用的名额
+func (s *ChapterParseServiceImpl) run(parseID, userID int64, chapterName string, chunks []string) {
+	defer func() { <-s.sem }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.cfg.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("章节解析异常 parse_id=%d: %v", parseID, r)
+			s.finish(parseID, nil, fmt.Errorf("解析异常: %v", r))
+		}
+	}()
+
+	startedAt := time.Now()
+	if err := s.repo.Update(ctx, parseID, map[string]interface{}{
+		"status":     model.ChapterParseStatusRunning,
+		"started_at": &startedAt,
+		"updated_at": startedAt,
+	}); err != nil {
+		log.Errorf("更新章节解析状态失败 parse_id=%d: %v", parseID, err)
+	}
+
+	var result model.ChapterParseResult
+	for i, chunk := range chunks {
+		scenes, err := s.parseChunk(ctx, userID, chapterName, chunk, i, len(chunks))
+		if err != nil {
+			s.finish(parseID, nil, fmt.Errorf("第%d段解析失败: %w", i+1, err))
+			return
+		}
+		result.Scenes = append(result.Scenes, scenes...)
+		if err := s.repo.Update(ctx, parseID, map[string]interface{}{
+			"done_chunks": i + 1,
+			"updated_at":  time.Now(),
+		}); err != nil {
+			log.Errorf("更新章节解析进度失败 parse_id=%d: %v", parseID, err)
+		}
+	}
+	finalizeParseResult(&result)
+	s.finish(parseID, &result, nil)
+}
+
+// finish 写入最终状态；任务超时后仍需落库，使用独立的上下文
+func (s *ChapterParseServiceImpl) finish(parseID int64, result *model.ChapterParseResult, parseErr error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"finished_at": &now,
+		"updated_at":  now,
+	}
+	if parseErr != nil {
+		msg := parseErr.Error()
+		updates["status"] = model.ChapterParseStatusFailed
+		updates["error_message"] = &msg
+	} else {
+		data, err := json.Marshal(result)
+		if err != nil {
+			msg := err.Error()
+			updates["status"] = model.ChapterParseStatusFailed
+			updates["error_message"] = &msg
+		} else {
+			updates["status"] = model.ChapterParseStatusSuccess
+			updates["result"] = data
+		}
+	}
+	if err := s.repo.Update(ctx, parseID, updates); err != nil {
+		log.Errorf("保存章节解析结果失败 parse_id=%d: %v", parseID, err)
+	}
+}
+
+// parseChunk 解析单段文本；未配置 chapter_parse 提示词模板时使用内置提示词
+func (s *ChapterParseServiceImpl) parseChunk(ctx context.Context, userID int64, chapterName, chunk string, index, total int) ([]model.ChapterScene, error) {
+	req := LLMChatRequest{
+		Purpose:        ChapterParsePurpose,
+		ResponseFormat: "json",
+		Cache:          true, // 章节内容未变时重新解析直接复用结果
+		Variables: map[string]interface{}{
+			"chapter_name": chapterName,
+			"content":      chunk,
+			"chunk_index":  index + 1,
+			"chunk_total":  total,
+		},
+	}
+	resp, err := s.llmSvc.Chat(ctx, userID, req)
+	if errors.Is(err, ErrPromptNotFound) {
+		req.Messages = builtinChapterParseMessages(chapterName, chunk, index, total)
+		resp, err = s.llmSvc.Chat(ctx, userID, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeChapterScenes(resp.Content)
+}
+
+// builtinChapterParseMessages 内置章节解析提示词
+func builtinChapterParseMessages(chapterName, chunk string, index, total int) []llm.ChatMessage {
+	system := `你是小说改编漫剧的剧本拆解助手。请把用户给出的小说片段拆分为场景，并把每个场景的正文拆成对白和旁白。
+只输出 JSON，格式如下：
+{"scenes":[{"title":"场景标题","location":"地点","time":"时间","summary":"场景概要","lines":[{"type":"dialogue","speaker":"说话人","content":"台词","emotion":"情绪"},{"type":"narration","speaker":"","content":"旁白"}]}]}
+要求：
+1. type 只能是 dialogue 或 narration；dialogue 必须给出 speaker，使用人物在文中的名字；
+2. 保持原文顺序，不要遗漏或改写情节；
+3. 不确定的字段留空字符串。`
+	user := fmt.Sprintf("章节：%s（第%d/%d段）\n\n%s", chapterName, index+1, total, chunk)
+	return []llm.ChatMessage{
+		{Role: "system", Content: system},
+		{Role: "user", Content: user},
+	}
+}
+
+// decodeChapterScenes 解析并校验模型输出
+func decodeChapterScenes(content string) ([]model.ChapterScene, error) {
+	var out struct {
+		Scenes []model.ChapterScene `json:"scenes"`
+	}
+	if err := json.Unmarshal([]byte(trimJSONFence(content)), &out); err != nil {
+		return nil, fmt.Errorf("模型输出不是合法JSON: %v", err)
+	}
+
+	scenes := make([]model.ChapterScene, 0, len(out.Scenes))
+	for _, scene := range out.Scenes {
+		lines := make([]model.ChapterLine, 0, len(scene.Lines))
+		for _, line := range scene.Lines {
+			line.Content = strings.TrimSpace(line.Content)
+			line.Speaker = strings.TrimSpace(line.Speaker)
+			if line.Content == "" {
+				continue
+			}
+			switch line.Type {
+			case "dialogue":
+				if line.Speaker == "" {
+					line.Type = "narration"
+				}
+			case "narration":
+				line.Speaker = ""
+			default:
+				// 类型不规范时按是否有说话人判断
+				if line.Speaker != "" {
+					line.Type = "dialogue"
+				} else {
+					line.Type = "narration"
+				}
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		scene.Lines = lines
+		scenes = append(scenes, scene)
+	}
+	if len(scenes) == 0 {
+		return nil, errors.New("模型输出未包含有效场景")
+	}
+	return scenes, nil
+}
+
+// finalizeParseResult 重新编号场景并汇总说话人
+func finalizeParseResult(result *model.ChapterParseResult) {
+	seen := map[string]bool{}
+	result.Speakers = []string{}
+	for i := range result.Scenes {
+		result.Scenes[i].Index = i + 1
+		for _, line := range result.Scenes[i].Lines {
+			if line.Type == "dialogue" && !seen[line.Speaker] {
+				seen[line.Speaker] = true
+				result.Speakers = append(result.Speakers, line.Speaker)
+			}
+		}
+	}
+}
+
+// splitChunks 按 chapter_parse 首选模型切分章节：每段 Token 数不超过上下文窗口扣除提示词与回复预留后的剩余，
+// 且预估输出不超过 max_tokens；无法确定模型参数时按 chunk_chars 个字符切分
+func (s *ChapterParseServiceImpl) splitChunks(ctx context.Context, chapterName, content string) []string {
+	runeCount := utf8.RuneCountInString
+	res, err := s.llmSvc.Tokenize(ctx, LLMTokenizeRequest{
+		Purpose:  ChapterParsePurpose,
+		Messages: builtinChapterParseMessages(chapterName, "", 0, 1),
+	})
+	if err != nil {
+		log.Warnf("估算章节解析分段大小失败，按 chunk_chars 切分: %v", err)
+		return splitChapterContent(content, s.cfg.ChunkChars, runeCount)
+	}
+	budget := res.MaxTokens / chapterParseOutputRatio
+	if res.ContextWindow > 0 && res.Available < budget {
+		budget = res.Available
+	}
+	if budget <= 0 {
+		return splitChapterContent(content, s.cfg.ChunkChars, runeCount)
+	}
+	return splitChapterContent(content, budget, tokenizer.ForModel(res.Model).Count)
+}
+
+// splitChapterContent 按段落切分章节，每段的 size 不超过 maxSize；超长段落按字符硬切
+func splitChapterContent(content string, maxSize int, size func(string) int) []string {
+	var (
+		chunks []string
+		buf    strings.Builder
+		used   int
+	)
+	flush := func() {
+		if strings.TrimSpace(buf.String()) != "" {
+			chunks = append(chunks, strings.TrimSpace(buf.String()))
+		}
+		buf.Reset()
+		used = 0
+	}
+	for _, para := range strings.Split(content, "\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		n := size(para)
+		if n > maxSize {
+			flush()
+			chunks = append(chunks, hardSplit(para, maxSize, size)...)
+			continue
+		}
+		if used > 0 && used+n+1 > maxSize {
+			flush()
+		}
+		if used > 0 {
+			buf.WriteString("\n")
+			used++
+		}
+		buf.WriteString(para)
+		used += n
+	}
+	flush()
+	return chunks
+}
+
+// hardSplit 把超长段落按字符切成 size 不超过 maxSize 的片段，片段长度按平均密度估算后逐步收缩
+func hardSplit(para string, maxSize int, size func(string) int) []string {
+	var pieces []string
+	runes := []rune(para)
+	for len(runes) > 0 {
+		n := len(runes)
+		if total := size(string(runes)); total > maxSize {
+			n = len(runes) * maxSize / total
+		}
+		for n > 1 && size(string(runes[:n])) > maxSize {
+			n = n * 9 / 10
+		}
+		if n < 1 {
+			n = 1
+		}
+		pieces = append(pieces, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return pieces
+}
+
+func contentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/pkg/jsonschema"
+
+	"gorm.io/gorm"
+)
+
+// ErrBatchNotFound 批次不存在
+var ErrBatchNotFound = errors.New("批次不存在")
+
+// LLMBatchService 批量任务服务：提交后由 cmd/worker 异步执行
+type LLMBatchService interface {
+	Submit(ctx context.Context, userID int64, req LLMBatchSubmit) (*model.LLMBatch, *model.LLMBatchProgress, error)
+	List(ctx context.Context, userID int64, query repository.LLMBatchListQuery) ([]model.LLMBatch, map[int64]model.LLMBatchProgress, int64, error)
+	Get(ctx context.Context, userID, id int64) (*model.LLMBatch, *model.LLMBatchProgress, error)
+	Cancel(ctx context.Context, userID, id int64) (int64, error)
+	ListJobs(ctx context.Context, userID, batchID int64, query repository.LLMJobListQuery) ([]model.LLMJob, int64, error)
+	Retry(ctx context.Context, userID, batchID int64, jobID *int64) (int64, error)
+}
+
+// LLMBatchSubmit 提交批次请求
+type LLMBatchSubmit struct {
+	Name  string
+	Items []LLMChatRequest
+}
+
+// LLMBatchServiceImpl 实现
+type LLMBatchServiceImpl struct {
+	repo repository.LLMBatchRepository
+	cfg  config.LLMBatchConfig
+}
+
+// NewLLMBatchService 创建服务
+func NewLLMBatchService(repo repository.LLMBatchRepository, cfg config.LLMBatchConfig) *LLMBatchServiceImpl {
+	if cfg.MaxItems <= 0 {
+		cfg.MaxItems = 1000
+	}
+	return &LLMBatchServiceImpl{repo: repo, cfg: cfg}
+}
+
+func (s *LLMBatchServiceImpl) Submit(ctx context.Context, userID int64, req LLMBatchSubmit) (*model.LLMBatch, *model.LLMBatchProgress, error) {
+	if len(req.Items) == 0 {
+		return nil, nil, errors.New("items不能为空")
+	}
+	if len(req.Items) > s.cfg.MaxItems {
+		return nil, nil, fmt.Errorf("单个批次最多 %d 条任务", s.cfg.MaxItems)
+	}
+
+	now := time.Now()
+	jobs := make([]*model.LLMJob, 0, len(req.Items))
+	for i, item := range req.Items {
+		if err := validateBatchItem(item); err != nil {
+			return nil, nil, fmt.Errorf("第 %d 条任务: %v", i+1, err)
+		}
+		raw, err := json.Marshal(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		jobs = append(jobs, &model.LLMJob{
+			UserID:    userID,
+			Seq:       i,
+			Status:    model.LLMJobStatusPending,
+			RouteKey:  batchRouteKey(item),
+			Request:   raw,
+			NextRunAt: now,
+			CreatedAt: now,
+			UpdatedAt: now,
+		})
+	}
+	batch := &model.LLMBatch{
+		UserID:    userID,
+		Name:      req.Name,
+		TotalJobs: len(jobs),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.Create(ctx, batch, jobs); err != nil {
+		return nil, nil, err
+	}
+	return batch, &model.LLMBatchProgress{Pending: len(jobs)}, nil
+}
+
+// validateBatchItem 提交时检查能提前发现的参数错误，避免整批任务执行后才失败
+func validateBatchItem(item LLMChatRequest) error {
+	if len(item.Messages) == 0 && item.PromptID == nil && item.Purpose == "" {
+		return errors.New("messages、prompt_id、purpose 不能同时为空")
+	}
+	if len(item.JSONSchema) > 0 {
+		if _, err := jsonschema.Compile(item.JSONSchema); err != nil {
+			return fmt.Errorf("json_schema 格式错误: %v", err)
+		}
+		if len(item.Tools) > 0 {
+			return errors.New("json_schema 与 tools 不能同时使用")
+		}
+	}
+	if item.Overflow != "" && item.Overflow != LLMOverflowReject && item.Overflow != LLMOverflowTrim {
+		return errors.New("overflow 只能是 reject 或 trim")
+	}
+	return nil
+}
+
+// batchRouteKey worker 按该分组限制并发：指定模型时按模型，否则按用途
+func batchRouteKey(item LLMChatRequest) string {
+	if item.ModelID != nil {
+		return fmt.Sprintf("model:%d", *item.ModelID)
+	}
+	purpose := item.Purpose
+	if purpose == "" {
+		purpose = "default"
+	}
+	return "purpose:" + purpose
+}
+
+func (s *LLMBatchServiceImpl) List(ctx context.Context, userID int64, query repository.LLMBatchListQuery) ([]model.LLMBatch, map[int64]model.LLMBatchProgress, int64, error) {
+	items, total, err := s.repo.List(ctx, userID, query)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	ids := make([]int64, 0, len(items))
+	for _, b := range items {
+		ids = append(ids, b.ID)
+	}
+	progress, err := s.repo.Progress(ctx, ids)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return items, progress, total, nil
+}
+
+func (s *LLMBatchServiceImpl) Get(ctx context.Context, userID, id int64) (*model.LLMBatch, *model.LLMBatchProgress, error) {
+	batch, err := s.findOwned(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	progress, err := s.repo.Progress(ctx, []int64{id})
+	if err != nil {
+		return nil, nil, err
+	}
+	p := progress[id]
+	return batch, &p, nil
+}
+
+// Cancel 取消批次，返回被取消的任务数；已完成的任务结果保留
+func (s *LLMBatchServiceImpl) Cancel(ctx context.Context, userID, id int64) (int64, error) {
+	if _, err := s.findOwned(ctx, userID, id); err != nil {
+		return 0, err
+	}
+	return s.repo.Cancel(ctx, id)
+}
+
+func (s *LLMBatchServiceImpl) ListJobs(ctx context.Context, userID, batchID int64, query repository.LLMJobListQuery) ([]model.LLMJob, int64, error) {
+	if _, err := s.findOwned(ctx, userID, batchID); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.ListJobs(ctx, batchID, query)
+}
+
+// Retry 重新排队失败的任务，jobID 为空时重试批次内全部失败任务，返回重新排队的任务数
+func (s *LLMBatchServiceImpl) Retry(ctx context.Context, userID, batchID int64, jobID *int64) (int64, error) {
+	if _, err := s.findOwned(ctx, userID, batchID); err != nil {
+		return 0, err
+	}
+	n, err := s.repo.RetryFailed(ctx, batchID, jobID)
+	if err != nil {
+		return 0, err
+	}
+	if jobID != nil && n == 0 {
+		return 0, errors.New("任务不存在或不是失败状态")
+	}
+	return n, nil
+}
+
+func (s *LLMBatchServiceImpl) findOwned(ctx context.Context, userID, id int64) (*model.LLMBatch, error) {
+	batch, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBatchNotFound
+		}
+		return nil, err
+	}
+	if batch.UserID != userID {
+		return nil, ErrBatchNotFound
+	}
+	return batch, nil
+}
@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// LLMBatchWorker 从 llm_jobs 领取并执行批量任务。
+// 并发上限按单个进程计算：总数不超过 concurrency，同一分组（模型或用途）不超过 model_concurrency。
+type LLMBatchWorker struct {
+	repo   repository.LLMBatchRepository
+	llmSvc LLMService
+	cfg    config.LLMBatchConfig
+	id     string
+
+	mu      sync.Mutex
+	running map[int64]*runningJob
+	perKey  map[string]int
+	wake    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// runningJob 执行中的任务
+type runningJob struct {
+	routeKey string
+	cancel   context.CancelFunc
+	lost     bool // 已被取消或被其他 worker 接管，结果不再写回
+}
+
+// NewLLMBatchWorker 创建 worker，标识为 主机名-进程号
+func NewLLMBatchWorker(repo repository.LLMBatchRepository, llmSvc LLMService, cfg config.LLMBatchConfig) *LLMBatchWorker {
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 8
+	}
+	if cfg.ModelConcurrency <= 0 || cfg.ModelConcurrency > cfg.Concurrency {
+		cfg.ModelConcurrency = cfg.Concurrency
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 3
+	}
+	if cfg.PollIntervalMs <= 0 {
+		cfg.PollIntervalMs = 1000
+	}
+	if cfg.JobTimeoutSeconds <= 0 {
+		cfg.JobTimeoutSeconds = 600
+	}
+	if cfg.LeaseSeconds <= 0 {
+		cfg.LeaseSeconds = 60
+	}
+	host, _ := os.Hostname()
+	return &LLMBatchWorker{
+		repo:    repo,
+		llmSvc:  llmSvc,
+		cfg:     cfg,
+		id:      fmt.Sprintf("%s-%d", host, os.Getpid()),
+		running: make(map[int64]*runningJob),
+		perKey:  make(map[string]int),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Run 持续拉取并执行任务，ctx 结束后中止执行中的任务并把它们放回队列
+func (w *LLMBatchWorker) Run(ctx context.Context) {
+	log.Infof("LLM批量任务 worker 启动 id=%s concurrency=%d model_concurrency=%d", w.id, w.cfg.Concurrency, w.cfg.ModelConcurrency)
+	ticker := time.NewTicker(time.Duration(w.cfg.PollIntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		w.extendLeases(ctx)
+		w.fill(ctx)
+		select {
+		case <-ctx.Done():
+			w.wg.Wait()
+			log.Infof("LLM批量任务 worker 退出 id=%s", w.id)
+			return
+		case <-ticker.C:
+		case <-w.wake:
+		}
+	}
+}
+
+func (w *LLMBatchWorker) lease() time.Duration {
+	return time.Duration(w.cfg.LeaseSeconds) * time.Second
+}
+
+// fill 领取任务直到达到并发上限或队列为空
+func (w *LLMBatchWorker) fill(ctx context.Context) {
+	for ctx.Err() == nil {
+		w.mu.Lock()
+		if len(w.running) >= w.cfg.Concurrency {
+			w.mu.Unlock()
+			return
+		}
+		var saturated []string
+		for key, n := range w.perKey {
+			if n >= w.cfg.ModelConcurrency {
+				saturated = append(saturated, key)
+			}
+		}
+		w.mu.Unlock()
+
+		job, err := w.repo.ClaimJob(ctx, w.id, saturated, w.lease())
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Errorf("领取LLM批量任务失败: %v", err)
+			}
+			return
+		}
+		if job == nil {
+			return
+		}
+		w.start(ctx, job)
+	}
+}
+
+func (w *LLMBatchWorker) start(ctx context.Context, job *model.LLMJob) {
+	jobCtx, cancel := context.WithTimeout(ctx, time.Duration(w.cfg.JobTimeoutSeconds)*time.Second)
+	rj := &runningJob{routeKey: job.RouteKey, cancel: cancel}
+	w.mu.Lock()
+	w.running[job.ID] = rj
+	w.perKey[job.RouteKey]++
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer cancel()
+		w.execute(ctx, jobCtx, job, rj)
+
+		w.mu.Lock()
+		delete(w.running, job.ID)
+		if w.perKey[job.RouteKey]--; w.perKey[job.RouteKey] <= 0 {
+			delete(w.perKey, job.RouteKey)
+		}
+		w.mu.Unlock()
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}()
+}
+
+// extendLeases 为执行中的任务续租，并中止已被取消或被接管的任务
+func (w *LLMBatchWorker) extendLeases(ctx context.Context) {
+	w.mu.Lock()
+	ids := make([]int64, 0, len(w.running))
+	for id := range w.running {
+		ids = append(ids, id)
+	}
+	w.mu.Unlock()
+	if len(ids) == 0 {
+		return
+	}
+
+	lost, err := w.repo.ExtendLease(ctx, w.id, ids, w.lease())
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Errorf("LLM批量任务续租失败: %v", err)
+		}
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, id := range lost {
+		if rj, ok := w.running[id]; ok {
+			log.Infof("LLM批量任务已取消或被接管，中止执行 job_id=%d", id)
+			rj.lost = true
+			rj.cancel()
+		}
+	}
+}
+
+// execute 执行单个任务并写回结果
+func (w *LLMBatchWorker) execute(workerCtx, ctx context.Context, job *model.LLMJob, rj *runningJob) {
+	var req LLMChatRequest
+	var resp *LLMChatResponse
+	err := json.Unmarshal(job.Request, &req)
+	if err == nil {
+		resp, err = w.llmSvc.Chat(ctx, job.UserID, req)
+	}
+
+	// 写回不受任务超时与 worker 退出影响
+	saveCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	now := time.Now()
+	updates := map[string]interface{}{
+		"locked_until": nil,
+		"updated_at":   now,
+	}
+	w.mu.Lock()
+	lost := rj.lost
+	w.mu.Unlock()
+	switch {
+	case lost:
+		return
+	case err == nil:
+		raw, mErr := json.Marshal(resp)
+		if mErr != nil {
+			log.Errorf("序列化LLM批量任务结果失败 job_id=%d: %v", job.ID, mErr)
+			return
+		}
+		updates["status"] = model.LLMJobStatusSuccess
+		updates["result"] = raw
+		updates["error_message"] = nil
+		updates["finished_at"] = now
+	case workerCtx.Err() != nil:
+		// worker 退出导致中止，不计入执行次数，放回队列
+		updates["status"] = model.LLMJobStatusPending
+		updates["attempts"] = gorm.Expr("attempts - 1")
+		updates["next_run_at"] = now
+	case job.Attempts < w.cfg.MaxAttempts && retryableJobErr(err):
+		msg := err.Error()
+		updates["status"] = model.LLMJobStatusPending
+		updates["error_message"] = &msg
+		updates["next_run_at"] = now.Add(jobBackoff(job.Attempts))
+		log.Warnf("LLM批量任务失败，稍后重试 job_id=%d attempt=%d: %v", job.ID, job.Attempts, err)
+	default:
+		msg := err.Error()
+		updates["status"] = model.LLMJobStatusFailed
+		updates["error_message"] = &msg
+		updates["finished_at"] = now
+	}
+
+	saved, sErr := w.repo.FinishJob(saveCtx, w.id, job.ID, updates)
+	if sErr != nil {
+		log.Errorf("写回LLM批量任务结果失败 job_id=%d: %v", job.ID, sErr)
+	} else if !saved {
+		log.Infof("LLM批量任务已取消或被接管，丢弃结果 job_id=%d", job.ID)
+	}
+}
+
+// retryableJobErr 模型侧的临时错误与任务超时可以重试，参数、配额等错误直接失败
+func retryableJobErr(err error) bool {
+	return isRetryableLLMErr(err) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// jobBackoff 第 n 次失败后的等待时间：30s、60s、120s……最长 10 分钟
+func jobBackoff(attempt int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempt && d < 10*time.Minute; i++ {
+		d *= 2
+	}
+	if d > 10*time.Minute {
+		d = 10 * time.Minute
+	}
+	return d
+}
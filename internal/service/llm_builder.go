@@ -0,0 +1,54 @@
+package service
+
+import (
+	"time"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/pkg/llm"
+	redisclient "manjing-ai-go/pkg/redis"
+	"manjing-ai-go/pkg/secret"
+	"manjing-ai-go/pkg/storage"
+
+	"gorm.io/gorm"
+)
+
+// BuildLLMService 组装 LLM 服务及其依赖，供 API 与 worker 共用。
+// 返回的工具注册表尚未注册内置工具，工具依赖的服务创建后由调用方调用 RegisterBuiltinTools。
+func BuildLLMService(cfg config.LLMConfig, db *gorm.DB, rdb *redisclient.Client, storageSvc storage.Service) (*LLMServiceImpl, *LLMToolRegistry, error) {
+	client := llm.NewClient(llm.ClientConfig{
+		BaseURL:     cfg.Default.BaseURL,
+		APIKey:      cfg.Default.APIKey,
+		Model:       cfg.Default.Model,
+		MaxTokens:   cfg.Default.MaxTokens,
+		Temperature: cfg.Default.Temperature,
+		Timeout:     cfg.Default.Timeout,
+		Retry: llm.RetryPolicy{
+			MaxAttempts: cfg.Default.Retry.MaxAttempts,
+			BaseBackoff: time.Duration(cfg.Default.Retry.BaseBackoffMs) * time.Millisecond,
+			MaxBackoff:  time.Duration(cfg.Default.Retry.MaxBackoffMs) * time.Millisecond,
+			Jitter:      cfg.Default.Retry.Jitter,
+		},
+	})
+
+	// 未配置主密钥时不加载密钥环，模型无法创建
+	var keyring *secret.Keyring
+	if cfg.Secret.MasterKey != "" {
+		var err error
+		keyring, err = secret.NewKeyring(cfg.Secret.MasterKey, cfg.Secret.PreviousKeys)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	logRepo := repository.NewLLMCallLogRepo(db)
+	quota := NewLLMQuota(rdb, logRepo, repository.NewUserRepo(db), cfg.Quota)
+	capture := NewLLMPayloadCapture(repository.NewLLMCallPayloadRepo(db), cfg.Capture)
+	cache := NewLLMResponseCache(rdb, cfg.Cache)
+	tools := NewLLMToolRegistry()
+	images := NewLLMImageResolver(repository.NewResourceRepo(db), storageSvc, cfg.Images)
+	limiter := NewLLMRateLimiter(rdb, cfg.Limits)
+
+	svc := NewLLMService(repository.NewLLMModelRepo(db), logRepo, repository.NewLLMPromptRepo(db), client, cfg, keyring, quota, capture, cache, tools, images, limiter)
+	return svc, tools, nil
+}
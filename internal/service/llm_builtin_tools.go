@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"manjing-ai-go/internal/repository"
+)
+
+// maxToolChapterChars get_chapter_text 单次返回的最大字符数
+const maxToolChapterChars = 8000
+
+// RegisterBuiltinTools 注册内置工具：按名称查找主体、分段读取章节正文、列出项目章节。
+// 工具均以调用用户身份访问数据，项目与章节的归属校验由各服务完成。
+func RegisterBuiltinTools(reg *LLMToolRegistry, subjectSvc SubjectService, chapterSvc ChapterService) {
+	reg.Register(LLMTool{
+		Name:        "find_subject",
+		Description: "按名称或别名查找项目中的主体（人物、物品、场景等），返回描述与外观提示词",
+		Parameters: json.RawMessage(`{"type":"object","required":["project_id","name"],"properties":{
+			"project_id":{"type":"integer","description":"项目ID"},
+			"name":{"type":"string","minLength":1,"description":"主体名称或别名关键词"}}}`),
+		Handler: func(ctx context.Context, userID int64, args json.RawMessage) (interface{}, error) {
+			var p struct {
+				ProjectID int64  `json:"project_id"`
+				Name      string `json:"name"`
+			}
+			if err := json.Unmarshal(args, &p); err != nil {
+				return nil, err
+			}
+			items, _, err := subjectSvc.List(ctx, userID, p.ProjectID, repository.SubjectListQuery{Page: 1, PageSize: 10, Keyword: p.Name})
+			if err != nil {
+				return nil, err
+			}
+			out := make([]map[string]interface{}, 0, len(items))
+			for _, s := range items {
+				out = append(out, map[string]interface{}{
+					"id":                s.ID,
+					"name":              s.Name,
+					"aliases":           s.Aliases,
+					"description":       s.Description,
+					"appearance_prompt": s.AppearancePrompt,
+				})
+			}
+			return map[string]interface{}{"subjects": out}, nil
+		},
+	})
+
+	reg.Register(LLMTool{
+		Name:        "list_chapters",
+		Description: "按顺序列出项目中的章节ID与名称",
+		Parameters: json.RawMessage(`{"type":"object","required":["project_id"],"properties":{
+			"project_id":{"type":"integer","description":"项目ID"},
+			"keyword":{"type":"string","description":"章节名称关键词"}}}`),
+		Handler: func(ctx context.Context, userID int64, args json.RawMessage) (interface{}, error) {
+			var p struct {
+				ProjectID int64  `json:"project_id"`
+				Keyword   string `json:"keyword"`
+			}
+			if err := json.Unmarshal(args, &p); err != nil {
+				return nil, err
+			}
+			items, total, err := chapterSvc.List(ctx, userID, p.ProjectID, repository.ChapterListQuery{Page: 1, PageSize: 100, Keyword: p.Keyword})
+			if err != nil {
+				return nil, err
+			}
+			out := make([]map[string]interface{}, 0, len(items))
+			for _, c := range items {
+				out = append(out, map[string]interface{}{"id": c.ID, "name": c.Name, "order_index": c.OrderIndex, "summary": c.Summary})
+			}
+			return map[string]interface{}{"chapters": out, "total": total}, nil
+		},
+	})
+
+	reg.Register(LLMTool{
+		Name:        "get_chapter_text",
+		Description: "读取章节正文，长章节可通过 offset 分段读取",
+		Parameters: json.RawMessage(`{"type":"object","required":["chapter_id"],"properties":{
+			"chapter_id":{"type":"integer","description":"章节ID"},
+			"offset":{"type":"integer","minimum":0,"description":"起始字符位置，默认0"},
+			"limit":{"type":"integer","minimum":1,"maximum":8000,"description":"读取字符数，默认4000"}}}`),
+		Handler: func(ctx context.Context, userID int64, args json.RawMessage) (interface{}, error) {
+			var p struct {
+				ChapterID int64 `json:"chapter_id"`
+				Offset    int   `json:"offset"`
+				Limit     int   `json:"limit"`
+			}
+			if err := json.Unmarshal(args, &p); err != nil {
+				return nil, err
+			}
+			if p.Limit <= 0 || p.Limit > maxToolChapterChars {
+				p.Limit = 4000
+			}
+			chapter, err := chapterSvc.Get(ctx, userID, p.ChapterID)
+			if err != nil {
+				return nil, err
+			}
+			content := []rune(chapter.Content)
+			start := p.Offset
+			if start > len(content) {
+				start = len(content)
+			}
+			end := start + p.Limit
+			if end > len(content) {
+				end = len(content)
+			}
+			return map[string]interface{}{
+				"chapter_id":  chapter.ID,
+				"name":        chapter.Name,
+				"total_chars": len(content),
+				"offset":      start,
+				"content":     string(content[start:end]),
+				"has_more":    end < len(content),
+			}, nil
+		},
+	})
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/pkg/llm"
+	redisclient "manjing-ai-go/pkg/redis"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// llmCacheKeyPrefix 响应缓存键前缀
+const llmCacheKeyPrefix = "llm:cache:"
+
+// LLMResponseCache 精确匹配的响应缓存：模型、消息与生成参数完全一致时复用上次结果
+type LLMResponseCache struct {
+	rdb *redisclient.Client
+	cfg config.LLMCacheConfig
+}
+
+// NewLLMResponseCache 创建响应缓存；rdb 为空或未启用时不缓存
+func NewLLMResponseCache(rdb *redisclient.Client, cfg config.LLMCacheConfig) *LLMResponseCache {
+	if cfg.TTLSeconds <= 0 {
+		cfg.TTLSeconds = 86400
+	}
+	return &LLMResponseCache{rdb: rdb, cfg: cfg}
+}
+
+// cachedResponse 缓存内容
+type cachedResponse struct {
+	Content      string `json:"content"`
+	FinishReason string `json:"finish_reason,omitempty"`
+}
+
+// enabled 是否可用
+func (c *LLMResponseCache) enabled() bool {
+	return c != nil && c.rdb != nil && c.cfg.Enable
+}
+
+// cacheable 温度为 0 或调用方显式要求时才使用缓存；工具调用依赖实时数据，不缓存
+func cacheable(req LLMChatRequest, target *llmTarget) bool {
+	if len(req.Tools) > 0 {
+		return false
+	}
+	return req.Cache || target.temperature == 0
+}
+
+// cacheKey 由模型、消息与生成参数计算缓存键
+func cacheKey(req LLMChatRequest, target *llmTarget) string {
+	raw, _ := json.Marshal(struct {
+		BaseURL        string            `json:"base_url"`
+		Model          string            `json:"model"`
+		Messages       []llm.ChatMessage `json:"messages"`
+		Temperature    float32           `json:"temperature"`
+		MaxTokens      int               `json:"max_tokens"`
+		ResponseFormat string            `json:"response_format"`
+	}{target.baseURL, target.modelName, req.Messages, target.temperature, target.maxTokens, req.ResponseFormat})
+	sum := sha256.Sum256(raw)
+	return llmCacheKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// Lookup 按候选模型顺序查找缓存，返回命中的模型与结果（Token 用量为 0）
+func (c *LLMResponseCache) Lookup(ctx context.Context, req LLMChatRequest, targets []*llmTarget) (*llmTarget, *llm.ChatResult, bool) {
+	if !c.enabled() {
+		return nil, nil, false
+	}
+	var keys []string
+	var candidates []*llmTarget
+	for _, t := range targets {
+		if cacheable(req, t) {
+			keys = append(keys, cacheKey(req, t))
+			candidates = append(candidates, t)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, nil, false
+	}
+	vals, err := c.rdb.RDB.MGet(ctx, keys...).Result()
+	if err != nil {
+		log.Errorf("读取LLM响应缓存失败: %v", err)
+		return nil, nil, false
+	}
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var cached cachedResponse
+		if err := json.Unmarshal([]byte(s), &cached); err != nil {
+			continue
+		}
+		return candidates[i], &llm.ChatResult{Content: cached.Content, FinishReason: cached.FinishReason}, true
+	}
+	return nil, nil, false
+}
+
+// Store 保存成功的调用结果
+func (c *LLMResponseCache) Store(ctx context.Context, req LLMChatRequest, target *llmTarget, result *llm.ChatResult) {
+	if !c.enabled() || !cacheable(req, target) {
+		return
+	}
+	raw, _ := json.Marshal(cachedResponse{Content: result.Content, FinishReason: result.FinishReason})
+	if err := c.rdb.RDB.Set(ctx, cacheKey(req, target), raw, time.Duration(c.cfg.TTLSeconds)*time.Second).Err(); err != nil {
+		log.Errorf("写入LLM响应缓存失败: %v", err)
+	}
+}
+
+// Purge 清空全部响应缓存，返回删除的键数
+func (c *LLMResponseCache) Purge(ctx context.Context) (int64, error) {
+	if c == nil || c.rdb == nil {
+		return 0, errors.New("未配置Redis")
+	}
+	var deleted int64
+	iter := c.rdb.RDB.Scan(ctx, 0, llmCacheKeyPrefix+"*", 500).Iterator()
+	batch := make([]string, 0, 500)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n, err := c.rdb.RDB.Unlink(ctx, batch...).Result()
+		deleted += n
+		batch = batch[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) >= 500 {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil && !errors.Is(err, redis.Nil) {
+		return deleted, err
+	}
+	return deleted, flush()
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"regexp"
+	"strings"
+	"time"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/pkg/llm"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ErrPayloadNotFound 调用内容不存在（未采样或已过期）
+var ErrPayloadNotFound = errors.New("调用内容不存在")
+
+// PayloadRedactor 脱敏函数，落库前作用于每条消息内容与返回内容
+type PayloadRedactor func(string) string
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phonePattern = regexp.MustCompile(`(?:\+?86[\s\-]?)?1[3-9]\d{9}|\d{3,4}-\d{7,8}`)
+)
+
+// DefaultPayloadRedactor 默认脱敏：邮箱、手机号与固定电话
+func DefaultPayloadRedactor(s string) string {
+	s = emailPattern.ReplaceAllString(s, "[EMAIL]")
+	return phonePattern.ReplaceAllString(s, "[PHONE]")
+}
+
+// LLMPayloadCapture 按采样率记录调用的请求消息与返回内容，默认关闭
+type LLMPayloadCapture struct {
+	repo     repository.LLMCallPayloadRepository
+	cfg      config.LLMCaptureConfig
+	redactor PayloadRedactor
+}
+
+// NewLLMPayloadCapture 创建调用内容采样器
+func NewLLMPayloadCapture(repo repository.LLMCallPayloadRepository, cfg config.LLMCaptureConfig) *LLMPayloadCapture {
+	if cfg.RetentionDays <= 0 {
+		cfg.RetentionDays = 7
+	}
+	return &LLMPayloadCapture{repo: repo, cfg: cfg, redactor: DefaultPayloadRedactor}
+}
+
+// SetRedactor 替换脱敏函数，仅在 redact 开启时生效
+func (p *LLMPayloadCapture) SetRedactor(fn PayloadRedactor) {
+	if fn != nil {
+		p.redactor = fn
+	}
+}
+
+// sampleRate 采样率优先级：模型 extra_config.capture > 用途 > 全局
+func (p *LLMPayloadCapture) sampleRate(purpose string, modelRate *float64) float64 {
+	if modelRate != nil {
+		return *modelRate
+	}
+	if r, ok := p.cfg.Purposes[purpose]; ok {
+		return r
+	}
+	return p.cfg.SampleRate
+}
+
+// Record 命中采样时保存调用内容；失败只记日志，不影响调用
+func (p *LLMPayloadCapture) Record(ctx context.Context, callLogID int64, purpose string, modelRate *float64, messages []llm.ChatMessage, response string) {
+	if p == nil || !p.cfg.Enable || callLogID == 0 {
+		return
+	}
+	rate := p.sampleRate(purpose, modelRate)
+	if rate <= 0 || (rate < 1 && rand.Float64() >= rate) {
+		return
+	}
+
+	stored := make([]llm.ChatMessage, len(messages))
+	for i, m := range messages {
+		if p.cfg.Redact {
+			m.Content = p.redactor(m.Content)
+		}
+		m.Parts = p.captureParts(m.Parts)
+		stored[i] = m
+	}
+	messages = stored
+	if p.cfg.Redact {
+		response = p.redactor(response)
+	}
+	raw, err := json.Marshal(messages)
+	if err != nil {
+		log.Errorf("序列化LLM调用内容失败 call_log_id=%d: %v", callLogID, err)
+		return
+	}
+	now := time.Now()
+	payload := &model.LLMCallPayload{
+		CallLogID: callLogID,
+		Messages:  raw,
+		Response:  response,
+		Redacted:  p.cfg.Redact,
+		ExpiresAt: now.AddDate(0, 0, p.cfg.RetentionDays),
+		CreatedAt: now,
+	}
+	if err := p.repo.Create(ctx, payload); err != nil {
+		log.Errorf("记录LLM调用内容失败 call_log_id=%d: %v", callLogID, err)
+	}
+}
+
+// captureParts 脱敏文本片段；内联图片体积大，只保留类型占位
+func (p *LLMPayloadCapture) captureParts(parts []llm.ContentPart) []llm.ContentPart {
+	if len(parts) == 0 {
+		return nil
+	}
+	out := make([]llm.ContentPart, len(parts))
+	for i, part := range parts {
+		if part.Type == llm.ContentPartText && p.cfg.Redact {
+			part.Text = p.redactor(part.Text)
+		}
+		if part.ImageURL != nil && strings.HasPrefix(part.ImageURL.URL, "data:") {
+			url := part.ImageURL.URL
+			if idx := strings.Index(url, ","); idx >= 0 {
+				url = url[:idx+1] + "[OMITTED]"
+			}
+			part.ImageURL = &llm.ImageURL{URL: url, Detail: part.ImageURL.Detail}
+		}
+		out[i] = part
+	}
+	return out
+}
+
+// Get 按调用日志ID查询采样内容
+func (p *LLMPayloadCapture) Get(ctx context.Context, callLogID int64) (*model.LLMCallPayload, error) {
+	payload, err := p.repo.FindByCallLogID(ctx, callLogID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPayloadNotFound
+		}
+		return nil, err
+	}
+	return payload, nil
+}
+
+// PurgeExpired 清理超过保留期的采样内容
+func (p *LLMPayloadCapture) PurgeExpired(ctx context.Context) (int64, error) {
+	return p.repo.DeleteExpired(ctx, time.Now())
+}
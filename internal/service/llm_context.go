@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"manjing-ai-go/pkg/llm"
+	"manjing-ai-go/pkg/tokenizer"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// 超出上下文窗口时的处理方式
+const (
+	LLMOverflowReject = "reject"
+	LLMOverflowTrim   = "trim"
+)
+
+// ErrContextOverflow 输入超出模型上下文窗口
+var ErrContextOverflow = errors.New("输入超出模型上下文窗口")
+
+// fitContext 估算输入 Token 数，超出目标模型窗口时按 overflow 拒绝或丢弃最早的历史消息。
+// 窗口需同时容纳输入与 max_tokens 的回复预留。
+func (s *LLMServiceImpl) fitContext(req LLMChatRequest, target *llmTarget) ([]llm.ChatMessage, error) {
+	if target.contextWindow <= 0 {
+		return req.Messages, nil
+	}
+	budget := target.contextWindow - target.maxTokens
+	if budget <= 0 {
+		return nil, fmt.Errorf("%w：max_tokens %d 不小于上下文窗口 %d", ErrContextOverflow, target.maxTokens, target.contextWindow)
+	}
+	enc := tokenizer.ForModel(target.modelName)
+	count := func(messages []llm.ChatMessage) int {
+		return llm.CountMessagesTokens(enc, messages)
+	}
+	// 工具定义同样计入输入
+	if len(req.toolDefs) > 0 {
+		if raw, err := json.Marshal(req.toolDefs); err == nil {
+			budget -= enc.Count(string(raw))
+		}
+	}
+	used := count(req.Messages)
+	if used <= budget {
+		return req.Messages, nil
+	}
+	overflow := fmt.Errorf("%w：估算输入 %d Token，模型 %s 可用 %d Token", ErrContextOverflow, used, target.modelName, budget)
+
+	mode := req.Overflow
+	if mode == "" {
+		mode = s.cfg.Context.Overflow
+	}
+	if mode != LLMOverflowTrim {
+		return nil, overflow
+	}
+	// 开头的系统提示词始终保留，其余消息从最早的开始丢弃
+	split := 0
+	for split < len(req.Messages) && req.Messages[split].Role == "system" {
+		split++
+	}
+	system, history := req.Messages[:split], req.Messages[split:]
+	if len(history) == 0 {
+		return nil, overflow
+	}
+	kept, err := truncateHistory(count, system, history, budget)
+	if err != nil || kept[0].Role != "user" {
+		return nil, overflow
+	}
+	log.Infof("输入超出模型上下文窗口，丢弃最早的 %d 条消息 purpose=%s model=%s", len(history)-len(kept), req.Purpose, target.modelName)
+	messages := make([]llm.ChatMessage, 0, len(system)+len(kept))
+	messages = append(messages, system...)
+	return append(messages, kept...), nil
+}
+
+// LLMTokenizeRequest Token 估算请求，text 与 messages 二选一
+type LLMTokenizeRequest struct {
+	Text      string            `json:"text"`
+	Messages  []llm.ChatMessage `json:"messages"`
+	ModelID   *int64            `json:"model_id"`
+	Purpose   string            `json:"purpose"`
+	MaxTokens *int              `json:"max_tokens"`
+}
+
+// LLMTokenizeResult Token 估算结果
+type LLMTokenizeResult struct {
+	Tokens        int    `json:"tokens"`         // 估算的输入 Token 数
+	Chars         int    `json:"chars"`          // 字符数
+	Model         string `json:"model"`          // 用于估算的模型
+	Encoding      string `json:"encoding"`       // 估算使用的分词器
+	ContextWindow int    `json:"context_window"` // 模型上下文窗口，0 表示未配置
+	MaxTokens     int    `json:"max_tokens"`     // 回复预留
+	Available     int    `json:"available"`      // 窗口扣除回复预留与输入后的剩余，未配置窗口时为 0
+	Fits          bool   `json:"fits"`           // 是否能放入上下文窗口
+}
+
+// Tokenize 按 model_id > purpose 首选模型 > 默认配置选择模型，估算文本或消息的 Token 数
+func (s *LLMServiceImpl) Tokenize(ctx context.Context, req LLMTokenizeRequest) (*LLMTokenizeResult, error) {
+	if req.Text == "" && len(req.Messages) == 0 {
+		return nil, errors.New("text 与 messages 不能同时为空")
+	}
+	modelName, window, maxTokens := s.cfg.Default.Model, s.cfg.Default.ContextWindow, s.cfg.Default.MaxTokens
+	if req.ModelID != nil {
+		m, err := s.modelRepo.FindByID(ctx, *req.ModelID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("指定的模型配置不存在")
+			}
+			return nil, err
+		}
+		modelName, window, maxTokens = m.Model, m.ContextWindow, m.MaxTokens
+	} else {
+		purpose := req.Purpose
+		if purpose == "" {
+			purpose = "default"
+		}
+		chain, err := s.modelRepo.FindActiveChainByPurpose(ctx, purpose)
+		if err != nil {
+			return nil, err
+		}
+		if len(chain) > 0 {
+			modelName, window, maxTokens = chain[0].Model, chain[0].ContextWindow, chain[0].MaxTokens
+		}
+	}
+	if req.MaxTokens != nil {
+		maxTokens = *req.MaxTokens
+	}
+
+	enc := tokenizer.ForModel(modelName)
+	result := &LLMTokenizeResult{Model: modelName, Encoding: enc.Name, ContextWindow: window, MaxTokens: maxTokens}
+	if len(req.Messages) > 0 {
+		result.Tokens = llm.CountMessagesTokens(enc, req.Messages)
+		for _, m := range req.Messages {
+			result.Chars += utf8.RuneCountInString(m.Text())
+		}
+	} else {
+		result.Tokens = enc.Count(req.Text)
+		result.Chars = utf8.RuneCountInString(req.Text)
+	}
+	result.Fits = true
+	if window > 0 {
+		result.Available = window - maxTokens - result.Tokens
+		result.Fits = result.Available >= 0
+	}
+	return result, nil
+}
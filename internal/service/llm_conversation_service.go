@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/pkg/llm"
+
+	"gorm.io/gorm"
+)
+
+// ErrConversationNotFound 会话不存在
+var ErrConversationNotFound = errors.New("会话不存在")
+
+// LLMConversationService 多轮对话会话服务
+type LLMConversationService interface {
+	Create(ctx context.Context, userID int64, req LLMConversationCreate) (*model.LLMConversation, error)
+	List(ctx context.Context, userID int64, query repository.LLMConversationListQuery) ([]model.LLMConversation, int64, error)
+	Get(ctx context.Context, userID, id int64) (*model.LLMConversation, []model.LLMMessage, error)
+	Delete(ctx context.Context, userID, id int64) error
+	Send(ctx context.Context, userID, id int64, req LLMConversationSend) (*LLMConversationReply, error)
+}
+
+// LLMConversationCreate 创建会话请求
+type LLMConversationCreate struct {
+	Title        string
+	Purpose      string
+	ModelID      *int64
+	ProjectID    *int64
+	ChapterID    *int64
+	SystemPrompt string
+}
+
+// LLMConversationSend 发送消息请求
+type LLMConversationSend struct {
+	Content        string
+	ResponseFormat string
+	MaxTokens      *int
+	Temperature    *float32
+}
+
+// LLMConversationReply 一轮对话结果
+type LLMConversationReply struct {
+	UserMessage      *model.LLMMessage
+	AssistantMessage *model.LLMMessage
+	Model            string
+	Provider         string
+	Usage            LLMUsage
+	DurationMs       int
+	TruncatedCount   int // 因超出上下文预算未发送的历史消息数
+}
+
+// LLMConversationServiceImpl 实现
+type LLMConversationServiceImpl struct {
+	repo       repository.LLMConversationRepository
+	llmSvc     LLMService
+	projectSvc ProjectService
+	chapterSvc ChapterService
+	cfg        config.LLMConfig
+}
+
+// NewLLMConversationService 创建服务
+func NewLLMConversationService(repo repository.LLMConversationRepository, llmSvc LLMService, projectSvc ProjectService, chapterSvc ChapterService, cfg config.LLMConfig) *LLMConversationServiceImpl {
+	if cfg.Conversation.ContextTokens <= 0 {
+		cfg.Conversation.ContextTokens = 16000
+	}
+	return &LLMConversationServiceImpl{
+		repo:       repo,
+		llmSvc:     llmSvc,
+		projectSvc: projectSvc,
+		chapterSvc: chapterSvc,
+		cfg:        cfg,
+	}
+}
+
+func (s *LLMConversationServiceImpl) Create(ctx context.Context, userID int64, req LLMConversationCreate) (*model.LLMConversation, error) {
+	if userID == 0 {
+		return nil, errors.New("未授权")
+	}
+	if req.Purpose == "" {
+		req.Purpose = "default"
+	}
+	if req.ChapterID != nil {
+		chapter, err := s.chapterSvc.Get(ctx, userID, *req.ChapterID)
+		if err != nil {
+			return nil, err
+		}
+		if req.ProjectID == nil {
+			req.ProjectID = &chapter.ProjectID
+		} else if *req.ProjectID != chapter.ProjectID {
+			return nil, errors.New("章节不属于该项目")
+		}
+	} else if req.ProjectID != nil {
+		if _, err := s.projectSvc.Get(ctx, userID, *req.ProjectID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	conv := &model.LLMConversation{
+		UserID:       userID,
+		Title:        truncateRunes(strings.TrimSpace(req.Title), 128),
+		Purpose:      req.Purpose,
+		ModelID:      req.ModelID,
+		ProjectID:    req.ProjectID,
+		ChapterID:    req.ChapterID,
+		SystemPrompt: req.SystemPrompt,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := s.repo.Create(ctx, conv); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+func (s *LLMConversationServiceImpl) List(ctx context.Context, userID int64, query repository.LLMConversationListQuery) ([]model.LLMConversation, int64, error) {
+	if userID == 0 {
+		return nil, 0, errors.New("未授权")
+	}
+	return s.repo.List(ctx, userID, query)
+}
+
+func (s *LLMConversationServiceImpl) Get(ctx context.Context, userID, id int64) (*model.LLMConversation, []model.LLMMessage, error) {
+	conv, err := s.find(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	msgs, err := s.repo.ListMessages(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conv, msgs, nil
+}
+
+func (s *LLMConversationServiceImpl) Delete(ctx context.Context, userID, id int64) error {
+	if _, err := s.find(ctx, userID, id); err != nil {
+		return err
+	}
+	now := time.Now()
+	return s.repo.Update(ctx, id, map[string]interface{}{
+		"deleted_at": &now,
+		"updated_at": now,
+	})
+}
+
+// Send 追加一轮用户消息并获取回复；调用失败时不保存本轮消息，便于客户端重试
+func (s *LLMConversationServiceImpl) Send(ctx context.Context, userID, id int64, req LLMConversationSend) (*LLMConversationReply, error) {
+	conv, err := s.find(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, errors.New("消息内容不能为空")
+	}
+	history, err := s.repo.ListMessages(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]llm.ChatMessage, 0, len(history)+1)
+	for _, m := range history {
+		messages = append(messages, llm.ChatMessage{Role: m.Role, Content: m.Content})
+	}
+	messages = append(messages, llm.ChatMessage{Role: "user", Content: req.Content})
+
+	var system []llm.ChatMessage
+	if conv.SystemPrompt != "" {
+		system = append(system, llm.ChatMessage{Role: "system", Content: conv.SystemPrompt})
+	}
+	kept, err := truncateHistory(llm.EstimateMessagesTokens, system, messages, s.historyBudget(req.MaxTokens))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.llmSvc.Chat(ctx, userID, LLMChatRequest{
+		Messages:       append(system, kept...),
+		Purpose:        conv.Purpose,
+		ModelID:        conv.ModelID,
+		ResponseFormat: req.ResponseFormat,
+		MaxTokens:      req.MaxTokens,
+		Temperature:    req.Temperature,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	userMsg := &model.LLMMessage{
+		Role:      "user",
+		Content:   req.Content,
+		CreatedAt: now,
+	}
+	assistantMsg := &model.LLMMessage{
+		Role:             "assistant",
+		Content:          resp.Content,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		Model:            resp.Model,
+		CreatedAt:        now,
+	}
+	if err := s.repo.AppendMessages(ctx, id, []*model.LLMMessage{userMsg, assistantMsg}); err != nil {
+		return nil, err
+	}
+	if conv.Title == "" {
+		_ = s.repo.Update(ctx, id, map[string]interface{}{"title": truncateRunes(strings.TrimSpace(req.Content), 32)})
+	}
+
+	return &LLMConversationReply{
+		UserMessage:      userMsg,
+		AssistantMessage: assistantMsg,
+		Model:            resp.Model,
+		Provider:         resp.Provider,
+		Usage:            resp.Usage,
+		DurationMs:       resp.DurationMs,
+		TruncatedCount:   len(messages) - len(kept),
+	}, nil
+}
+
+func (s *LLMConversationServiceImpl) find(ctx context.Context, userID, id int64) (*model.LLMConversation, error) {
+	if userID == 0 {
+		return nil, errors.New("未授权")
+	}
+	conv, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrConversationNotFound
+		}
+		return nil, err
+	}
+	if conv.UserID != userID {
+		return nil, errors.New("无权访问")
+	}
+	return conv, nil
+}
+
+// historyBudget 历史消息可用的 Token 预算：上下文预算扣除回复预留
+func (s *LLMConversationServiceImpl) historyBudget(maxTokens *int) int {
+	reply := s.cfg.Default.MaxTokens
+	if maxTokens != nil && *maxTokens > 0 {
+		reply = *maxTokens
+	}
+	budget := s.cfg.Conversation.ContextTokens - reply
+	// 回复预留过大时至少保留一半上下文给历史
+	if budget < s.cfg.Conversation.ContextTokens/2 {
+		budget = s.cfg.Conversation.ContextTokens / 2
+	}
+	return budget
+}
+
+// truncateHistory 从最早的消息开始丢弃，直到系统提示词与剩余历史不超过预算；最后一条用户消息始终保留。
+// count 为消息 Token 估算函数。
+func truncateHistory(count func([]llm.ChatMessage) int, system, messages []llm.ChatMessage, budget int) ([]llm.ChatMessage, error) {
+	used := count(system)
+	last := messages[len(messages)-1]
+	used += count([]llm.ChatMessage{last})
+	if used > budget {
+		return nil, errors.New("消息过长，超出上下文预算")
+	}
+
+	start := len(messages) - 1
+	for start > 0 {
+		cost := count(messages[start-1 : start])
+		if used+cost > budget {
+			break
+		}
+		used += cost
+		start--
+	}
+	// 避免以助手消息开头，保持 user/assistant 交替
+	for start < len(messages)-1 && messages[start].Role != "user" {
+		start++
+	}
+	return messages[start:], nil
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/pkg/llm"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// LLMModelTestResult 模型连通性测试结果
+type LLMModelTestResult struct {
+	ModelID      int64  `json:"model_id"`
+	Success      bool   `json:"success"`
+	LatencyMs    int    `json:"latency_ms"`
+	StatusCode   int    `json:"status_code"`     // HTTP状态码，未收到响应时为0
+	StatusClass  string `json:"status_class"`    // 2xx/4xx/5xx；未收到响应时为 timeout/network/config
+	Model        string `json:"model"`           // 配置的模型标识
+	EchoedModel  string `json:"echoed_model"`    // 服务商返回的模型标识
+	Error        string `json:"error,omitempty"` // 失败原因
+	HealthStatus int16  `json:"health_status"`   // 测试后的健康状态
+}
+
+// probeMessages 探测请求内容，尽量少消耗Token
+var probeMessages = []llm.ChatMessage{{Role: "user", Content: "ping"}}
+
+// TestModel 向指定模型发送探测请求，并按结果更新健康状态
+func (s *LLMServiceImpl) TestModel(ctx context.Context, id int64) (*LLMModelTestResult, error) {
+	m, err := s.modelRepo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("模型配置不存在")
+		}
+		return nil, err
+	}
+	result := s.probeModel(ctx, m)
+	if err := s.recordHealth(ctx, m, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CheckModelsHealth 探测全部启用的模型，供后台定时任务调用
+func (s *LLMServiceImpl) CheckModelsHealth(ctx context.Context) error {
+	models, err := s.modelRepo.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	for i := range models {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		m := &models[i]
+		result := s.probeModel(ctx, m)
+		if err := s.recordHealth(ctx, m, result); err != nil {
+			log.Errorf("更新模型健康状态失败 id=%d: %v", m.ID, err)
+			continue
+		}
+		if result.HealthStatus != m.HealthStatus {
+			log.Warnf("模型健康状态变更 id=%d name=%s %d -> %d: %s", m.ID, m.Name, m.HealthStatus, result.HealthStatus, result.Error)
+		}
+	}
+	return nil
+}
+
+// probeModel 发送最小探测请求；不重试、不记录调用日志、不计入用户配额
+func (s *LLMServiceImpl) probeModel(ctx context.Context, m *model.LLMModel) *LLMModelTestResult {
+	result := &LLMModelTestResult{ModelID: m.ID, Model: m.Model}
+	target, err := s.targetFromModel(m)
+	if err != nil {
+		result.StatusClass = "config"
+		result.Error = err.Error()
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(s.cfg.Health.TimeoutSeconds)*time.Second)
+	defer cancel()
+	start := time.Now()
+	res, err := s.client.ChatCompletion(ctx, probeMessages,
+		llm.WithProvider(target.provider),
+		llm.WithEndpoint(target.baseURL, target.apiKey),
+		llm.WithModel(target.modelName),
+		llm.WithMaxTokens(8),
+		llm.WithRetryPolicy(llm.RetryPolicy{MaxAttempts: 1}),
+	)
+	result.LatencyMs = int(time.Since(start).Milliseconds())
+	if err != nil {
+		result.Error = err.Error()
+		var llmErr *llm.Error
+		if errors.As(err, &llmErr) {
+			result.StatusCode = llmErr.StatusCode
+			if llmErr.Body != "" {
+				result.Error += ": " + llmErr.ProviderMessage()
+			}
+		}
+		result.StatusClass = probeStatusClass(result.StatusCode, err)
+		return result
+	}
+	result.Success = true
+	result.StatusCode = 200
+	result.StatusClass = "2xx"
+	result.EchoedModel = res.Model
+	return result
+}
+
+func probeStatusClass(statusCode int, err error) string {
+	if statusCode > 0 {
+		return fmt.Sprintf("%dxx", statusCode/100)
+	}
+	var llmErr *llm.Error
+	if errors.As(err, &llmErr) && llmErr.Kind == llm.ErrKindTimeout {
+		return "timeout"
+	}
+	return "network"
+}
+
+// recordHealth 成功即恢复健康；连续失败达到阈值后标记为不健康
+func (s *LLMServiceImpl) recordHealth(ctx context.Context, m *model.LLMModel, result *LLMModelTestResult) error {
+	updates := map[string]interface{}{"last_checked_at": time.Now()}
+	if result.Success {
+		result.HealthStatus = model.LLMModelHealthHealthy
+		updates["consecutive_failures"] = 0
+		updates["last_check_error"] = ""
+	} else {
+		failures := m.ConsecutiveFailures + 1
+		result.HealthStatus = m.HealthStatus
+		if failures >= s.cfg.Health.FailureThreshold {
+			result.HealthStatus = model.LLMModelHealthUnhealthy
+		}
+		updates["consecutive_failures"] = failures
+		updates["last_check_error"] = truncateRunes(result.Error, 512)
+	}
+	updates["health_status"] = result.HealthStatus
+	// 仅更新健康字段，不刷新 updated_at，避免影响同优先级模型的排序
+	return s.modelRepo.UpdateColumns(ctx, m.ID, updates)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/pkg/llm"
+	"manjing-ai-go/pkg/storage"
+
+	"gorm.io/gorm"
+)
+
+// 图片解析方式
+const (
+	LLMImageModeBase64 = "base64"
+	LLMImageModeURL    = "url"
+)
+
+// LLMImageResolver 把消息中的 resource_id 图片片段解析为服务商可用的 image_url
+type LLMImageResolver struct {
+	repo    repository.ResourceRepository
+	storage storage.Service
+	cfg     config.LLMImagesConfig
+}
+
+// NewLLMImageResolver 创建图片解析器
+func NewLLMImageResolver(repo repository.ResourceRepository, storageSvc storage.Service, cfg config.LLMImagesConfig) *LLMImageResolver {
+	if cfg.Mode != LLMImageModeURL {
+		cfg.Mode = LLMImageModeBase64
+	}
+	if cfg.MaxInlineBytes <= 0 {
+		cfg.MaxInlineBytes = 5 << 20
+	}
+	return &LLMImageResolver{repo: repo, storage: storageSvc, cfg: cfg}
+}
+
+// Resolve 校验内容片段并解析 resource_id，返回新的消息列表，不修改入参
+func (r *LLMImageResolver) Resolve(ctx context.Context, userID int64, messages []llm.ChatMessage) ([]llm.ChatMessage, error) {
+	out := messages
+	copied := false
+	for i, m := range messages {
+		if len(m.Parts) == 0 {
+			continue
+		}
+		if m.Role != "user" && m.HasImages() {
+			return nil, errors.New("只有 user 消息可以包含图片")
+		}
+		parts := make([]llm.ContentPart, len(m.Parts))
+		for j, p := range m.Parts {
+			resolved, err := r.resolvePart(ctx, userID, p)
+			if err != nil {
+				return nil, err
+			}
+			parts[j] = resolved
+		}
+		if !copied {
+			out = append([]llm.ChatMessage(nil), messages...)
+			copied = true
+		}
+		out[i].Parts = parts
+	}
+	return out, nil
+}
+
+func (r *LLMImageResolver) resolvePart(ctx context.Context, userID int64, p llm.ContentPart) (llm.ContentPart, error) {
+	switch p.Type {
+	case llm.ContentPartText:
+		return p, nil
+	case llm.ContentPartImageURL:
+	default:
+		return p, fmt.Errorf("不支持的内容片段类型: %s", p.Type)
+	}
+	if p.ResourceID == 0 {
+		if p.ImageURL == nil || p.ImageURL.URL == "" {
+			return p, errors.New("图片片段需提供 image_url.url 或 resource_id")
+		}
+		return p, nil
+	}
+	if r == nil || r.repo == nil {
+		return p, errors.New("未配置图片资源解析")
+	}
+
+	// 归属校验与 ResourceServiceImpl.Get 一致
+	res, err := r.repo.FindByID(ctx, p.ResourceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return p, errors.New("图片资源不存在")
+		}
+		return p, err
+	}
+	if res.DeletedAt != nil {
+		return p, errors.New("图片资源不存在")
+	}
+	if res.UserID != userID {
+		return p, errors.New("无权访问图片资源")
+	}
+	if res.Type != "image" {
+		return p, errors.New("资源不是图片")
+	}
+
+	var url string
+	if r.cfg.Mode == LLMImageModeURL {
+		if url, err = r.storage.URL(ctx, res.ObjectKey); err != nil {
+			return p, err
+		}
+	} else {
+		if res.SizeBytes > r.cfg.MaxInlineBytes {
+			return p, fmt.Errorf("图片过大，内联上限为 %d 字节", r.cfg.MaxInlineBytes)
+		}
+		data, err := r.storage.Read(ctx, res.ObjectKey)
+		if err != nil {
+			return p, err
+		}
+		if int64(len(data)) > r.cfg.MaxInlineBytes {
+			return p, fmt.Errorf("图片过大，内联上限为 %d 字节", r.cfg.MaxInlineBytes)
+		}
+		mimeType := res.MimeType
+		if !strings.HasPrefix(mimeType, "image/") {
+			mimeType = http.DetectContentType(data)
+		}
+		url = "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	}
+
+	detail := ""
+	if p.ImageURL != nil {
+		detail = p.ImageURL.Detail
+	}
+	return llm.ContentPart{Type: llm.ContentPartImageURL, ImageURL: &llm.ImageURL{URL: url, Detail: detail}}, nil
+}
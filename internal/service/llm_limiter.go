@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/pkg/llm"
+	redisclient "manjing-ai-go/pkg/redis"
+	"manjing-ai-go/pkg/tokenizer"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// llmLimitKeyPrefix 限流键前缀，{模型ID} 保证同一模型的键在 Redis Cluster 中落在同一分片
+const llmLimitKeyPrefix = "llm:limit:"
+
+// llmLimitPollInterval 仅因并发已满而等待时的重试间隔
+const llmLimitPollInterval = 100 * time.Millisecond
+
+// luaBucketRefill 令牌桶按 Redis 服务器时间补充：每分钟补满 capacity，不超过 capacity
+const luaBucketRefill = `
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local function refill(key, capacity)
+  local v = redis.call('HMGET', key, 'tokens', 'ts')
+  local tokens = tonumber(v[1])
+  if tokens == nil then
+    return capacity
+  end
+  return math.min(capacity, tokens + (now - tonumber(v[2])) * capacity / 60000)
+end
+local function save(key, capacity, tokens)
+  redis.call('HSET', key, 'tokens', tokens, 'ts', now)
+  redis.call('PEXPIRE', key, math.ceil((capacity - tokens) * 60000 / capacity) + 1000)
+end
+`
+
+// llmLimitAcquireScript 同时检查 RPM、TPM 与并发，全部满足时才扣减，避免只占用一部分名额。
+// KEYS: rpm桶、tpm桶、并发集合；ARGV: rpm、tpm、最大并发、预估Token、并发成员、名额占用毫秒数。
+// 返回 0 表示已取得名额，大于 0 为预计需要等待的毫秒数，-1 表示仅并发已满、等待时间未知。
+var llmLimitAcquireScript = redis.NewScript(luaBucketRefill + `
+local rpm = tonumber(ARGV[1])
+local tpm = tonumber(ARGV[2])
+local conc = tonumber(ARGV[3])
+local cost = math.min(tonumber(ARGV[4]), tpm)
+local wait = 0
+local rpmTokens = 0
+local tpmTokens = 0
+if rpm > 0 then
+  rpmTokens = refill(KEYS[1], rpm)
+  if rpmTokens < 1 then
+    wait = math.max(wait, math.ceil((1 - rpmTokens) * 60000 / rpm))
+  end
+end
+if tpm > 0 then
+  tpmTokens = refill(KEYS[2], tpm)
+  if tpmTokens < cost then
+    wait = math.max(wait, math.ceil((cost - tpmTokens) * 60000 / tpm))
+  end
+end
+if wait > 0 then
+  return wait
+end
+if conc > 0 then
+  redis.call('ZREMRANGEBYSCORE', KEYS[3], '-inf', now)
+  if redis.call('ZCARD', KEYS[3]) >= conc then
+    return -1
+  end
+  redis.call('ZADD', KEYS[3], now + tonumber(ARGV[6]), ARGV[5])
+  redis.call('PEXPIRE', KEYS[3], tonumber(ARGV[6]))
+end
+if rpm > 0 then
+  save(KEYS[1], rpm, rpmTokens - 1)
+end
+if tpm > 0 then
+  save(KEYS[2], tpm, tpmTokens - cost)
+end
+return 0
+`)
+
+// llmLimitAdjustScript 调用结束后按实际用量修正 TPM 桶，delta 为实际用量减去预扣量，可为负（退还）。
+// 余额可以为负，之后的请求需等待补足。
+var llmLimitAdjustScript = redis.NewScript(luaBucketRefill + `
+local tpm = tonumber(ARGV[1])
+local tokens = math.min(tpm, refill(KEYS[1], tpm) - tonumber(ARGV[2]))
+save(KEYS[1], tpm, tokens)
+return 0
+`)
+
+// LLMRateLimiter 按模型 extra_config.limits 限制并发、RPM 与 TPM，状态保存在 Redis 中，多个实例共享。
+// 超出限额的请求排队等待，超过最长等待时间或排队已满时返回限流错误，由调用方降级到下一个模型。
+type LLMRateLimiter struct {
+	rdb *redisclient.Client
+	cfg config.LLMLimitsConfig
+
+	mu      sync.Mutex
+	waiting map[int64]int // 本实例各模型正在排队的请求数
+}
+
+// NewLLMRateLimiter 创建限流器；rdb 为空时不限制
+func NewLLMRateLimiter(rdb *redisclient.Client, cfg config.LLMLimitsConfig) *LLMRateLimiter {
+	if cfg.MaxWaitMs < 0 {
+		cfg.MaxWaitMs = 0
+	}
+	if cfg.QueueSize < 0 {
+		cfg.QueueSize = 0
+	}
+	if cfg.LeaseSeconds <= 0 {
+		cfg.LeaseSeconds = 600
+	}
+	return &LLMRateLimiter{rdb: rdb, cfg: cfg, waiting: make(map[int64]int)}
+}
+
+// llmPermit 已取得的调用名额，调用结束后必须 Release
+type llmPermit struct {
+	limiter *LLMRateLimiter
+	target  *llmTarget
+	member  string // 并发集合中的成员
+	cost    int    // TPM 预扣的Token数
+}
+
+// Acquire 为一次调用取得名额；模型未配置限额或 Redis 不可用时返回空名额
+func (l *LLMRateLimiter) Acquire(ctx context.Context, target *llmTarget, messages []llm.ChatMessage) (*llmPermit, error) {
+	if l == nil || l.rdb == nil || target.dbModelID == nil || !target.limits.Enabled() {
+		return nil, nil
+	}
+	limits := target.limits
+	permit := &llmPermit{
+		limiter: l,
+		target:  target,
+		member:  fmt.Sprintf("%d-%d", time.Now().UnixNano(), rand.Int63()),
+	}
+	if limits.TPM > 0 {
+		permit.cost = llm.CountMessagesTokens(tokenizer.ForModel(target.modelName), messages)
+	}
+
+	deadline := time.Now().Add(time.Duration(l.cfg.MaxWaitMs) * time.Millisecond)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	queued := false
+	defer func() {
+		if queued {
+			l.leave(*target.dbModelID)
+		}
+	}()
+	for {
+		wait, err := l.tryAcquire(ctx, target, permit)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			// Redis 故障时不阻断调用
+			log.Warnf("LLM限流检查失败，本次不限流 model_id=%d: %v", *target.dbModelID, err)
+			return nil, nil
+		}
+		if wait == 0 {
+			return permit, nil
+		}
+		if !queued {
+			if !l.enter(*target.dbModelID) {
+				return nil, limitErr("模型排队已满 model_id=%d", *target.dbModelID)
+			}
+			queued = true
+		}
+		sleep := time.Duration(wait) * time.Millisecond
+		if wait < 0 {
+			sleep = llmLimitPollInterval
+		}
+		if time.Now().Add(sleep).After(deadline) {
+			return nil, limitErr("模型排队超时 model_id=%d", *target.dbModelID)
+		}
+		// 加入少量抖动，避免同时等待的请求一起重试
+		sleep += time.Duration(rand.Int63n(int64(llmLimitPollInterval / 2)))
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// tryAcquire 执行一次原子检查，返回值含义见 llmLimitAcquireScript
+func (l *LLMRateLimiter) tryAcquire(ctx context.Context, target *llmTarget, permit *llmPermit) (int64, error) {
+	keys := llmLimitKeys(*target.dbModelID)
+	lease := time.Duration(l.cfg.LeaseSeconds) * time.Second
+	return llmLimitAcquireScript.Run(ctx, l.rdb.RDB, keys,
+		target.limits.RPM, target.limits.TPM, target.limits.MaxConcurrency,
+		permit.cost, permit.member, lease.Milliseconds()).Int64()
+}
+
+// enter 进入本实例的等待队列，队列已满时返回 false
+func (l *LLMRateLimiter) enter(modelID int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.waiting[modelID] >= l.cfg.QueueSize {
+		return false
+	}
+	l.waiting[modelID]++
+	return true
+}
+
+func (l *LLMRateLimiter) leave(modelID int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.waiting[modelID]--; l.waiting[modelID] <= 0 {
+		delete(l.waiting, modelID)
+	}
+}
+
+// Release 释放并发名额，并按实际Token用量修正 TPM；result 为空（调用失败）时退还预扣量
+func (p *llmPermit) Release(result *llm.ChatResult) {
+	if p == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	l := p.limiter
+	keys := llmLimitKeys(*p.target.dbModelID)
+	if p.target.limits.MaxConcurrency > 0 {
+		if err := l.rdb.RDB.ZRem(ctx, keys[2], p.member).Err(); err != nil {
+			log.Warnf("释放LLM并发名额失败 model_id=%d: %v", *p.target.dbModelID, err)
+		}
+	}
+	if p.target.limits.TPM > 0 {
+		used := 0
+		if result != nil {
+			used = result.TotalTokens
+			if used == 0 {
+				// 未返回用量时按预扣量计
+				used = p.cost
+			}
+		}
+		if delta := used - p.cost; delta != 0 {
+			if err := llmLimitAdjustScript.Run(ctx, l.rdb.RDB, keys[1:2], p.target.limits.TPM, delta).Err(); err != nil {
+				log.Warnf("修正LLM TPM限额失败 model_id=%d: %v", *p.target.dbModelID, err)
+			}
+		}
+	}
+}
+
+// llmLimitKeys 返回 rpm桶、tpm桶、并发集合三个键
+func llmLimitKeys(modelID int64) []string {
+	prefix := fmt.Sprintf("%s{%d}:", llmLimitKeyPrefix, modelID)
+	return []string{prefix + "rpm", prefix + "tpm", prefix + "conc"}
+}
+
+// limitErr 本地限流按模型限流处理，可降级到下一个模型，批量任务稍后重试
+func limitErr(format string, args ...interface{}) error {
+	return &llm.Error{Kind: llm.ErrKindRateLimited, Err: fmt.Errorf(format, args...)}
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/pkg/llm"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ErrPromptNotFound 提示词模板不存在
+var ErrPromptNotFound = errors.New("提示词模板不存在")
+
+// ErrPromptInactive 通过 prompt_id 指定的模板版本已停用
+var ErrPromptInactive = errors.New("提示词模板已停用")
+
+// LLMPromptCreate 创建提示词模板请求（同用途下自动生成新版本）
+type LLMPromptCreate struct {
+	Purpose     string            `json:"purpose"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Messages    []llm.ChatMessage `json:"messages"`
+}
+
+// LLMPromptUpdate 更新提示词模板请求；模板内容不可修改，需创建新版本
+type LLMPromptUpdate struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	IsActive    *bool   `json:"is_active"`
+}
+
+func (s *LLMServiceImpl) CreatePrompt(ctx context.Context, userID int64, req LLMPromptCreate) (*model.LLMPrompt, error) {
+	if req.Purpose == "" {
+		return nil, errors.New("用途不能为空")
+	}
+	if req.Name == "" {
+		return nil, errors.New("名称不能为空")
+	}
+	if len(req.Messages) == 0 {
+		return nil, errors.New("messages不能为空")
+	}
+	for i, msg := range req.Messages {
+		if msg.Role != "system" && msg.Role != "user" && msg.Role != "assistant" {
+			return nil, fmt.Errorf("第%d条消息角色非法", i+1)
+		}
+		if _, err := parsePromptTemplate(msg.Content); err != nil {
+			return nil, fmt.Errorf("第%d条消息模板语法错误: %v", i+1, err)
+		}
+	}
+	messages, err := json.Marshal(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	p := &model.LLMPrompt{
+		Purpose:     req.Purpose,
+		Name:        req.Name,
+		Description: req.Description,
+		Messages:    datatypes.JSON(messages),
+		IsActive:    true,
+		CreatedBy:   userID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.promptRepo.CreateNextVersion(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *LLMServiceImpl) ListPrompts(ctx context.Context, query repository.LLMPromptListQuery) ([]model.LLMPrompt, int64, error) {
+	return s.promptRepo.List(ctx, query)
+}
+
+func (s *LLMServiceImpl) GetPrompt(ctx context.Context, id int64) (*model.LLMPrompt, error) {
+	p, err := s.promptRepo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPromptNotFound
+		}
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *LLMServiceImpl) UpdatePrompt(ctx context.Context, id int64, req LLMPromptUpdate) (*model.LLMPrompt, error) {
+	p, err := s.GetPrompt(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		if *req.Name == "" {
+			return nil, errors.New("名称不能为空")
+		}
+		updates["name"] = *req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+	if len(updates) == 0 {
+		return p, nil
+	}
+
+	updates["updated_at"] = time.Now()
+	if err := s.promptRepo.Update(ctx, id, updates); err != nil {
+		return nil, err
+	}
+	return s.promptRepo.FindByID(ctx, id)
+}
+
+func (s *LLMServiceImpl) DeletePrompt(ctx context.Context, id int64) error {
+	if _, err := s.GetPrompt(ctx, id); err != nil {
+		return err
+	}
+	now := time.Now()
+	return s.promptRepo.Update(ctx, id, map[string]interface{}{
+		"deleted_at": &now,
+		"updated_at": now,
+	})
+}
+
+// applyPrompt 按 prompt_id 或 purpose 渲染模板消息，置于调用方消息之前。
+// 仅在指定 prompt_id 或未提供 messages 时使用模板；停用的版本即使指定 prompt_id 也不可使用。
+func (s *LLMServiceImpl) applyPrompt(ctx context.Context, req *LLMChatRequest) error {
+	var (
+		p   *model.LLMPrompt
+		err error
+	)
+	switch {
+	case req.PromptID != nil:
+		p, err = s.GetPrompt(ctx, *req.PromptID)
+		if err != nil {
+			return err
+		}
+		if !p.IsActive {
+			return ErrPromptInactive
+		}
+	case len(req.Messages) == 0 && req.Purpose != "":
+		p, err = s.promptRepo.FindLatestActiveByPurpose(ctx, req.Purpose)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPromptNotFound
+			}
+			return err
+		}
+	default:
+		return nil
+	}
+
+	rendered, err := renderPrompt(p, req.Variables)
+	if err != nil {
+		return err
+	}
+	req.Messages = append(rendered, req.Messages...)
+	if req.Purpose == "" {
+		req.Purpose = p.Purpose
+	}
+	req.prompt = p
+	return nil
+}
+
+// renderPrompt 使用 text/template 渲染模板消息，缺失变量视为错误
+func renderPrompt(p *model.LLMPrompt, vars map[string]interface{}) ([]llm.ChatMessage, error) {
+	var tpl []llm.ChatMessage
+	if err := json.Unmarshal(p.Messages, &tpl); err != nil {
+		return nil, fmt.Errorf("提示词模板格式错误: %v", err)
+	}
+	if vars == nil {
+		vars = map[string]interface{}{}
+	}
+	out := make([]llm.ChatMessage, 0, len(tpl))
+	for _, msg := range tpl {
+		t, err := parsePromptTemplate(msg.Content)
+		if err != nil {
+			return nil, fmt.Errorf("提示词模板格式错误: %v", err)
+		}
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, vars); err != nil {
+			return nil, fmt.Errorf("提示词变量错误: %v", err)
+		}
+		out = append(out, llm.ChatMessage{Role: msg.Role, Content: buf.String()})
+	}
+	return out, nil
+}
+
+func parsePromptTemplate(content string) (*template.Template, error) {
+	return template.New("prompt").Option("missingkey=error").Parse(content)
+}
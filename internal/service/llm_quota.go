@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/internal/repository"
+	redisclient "manjing-ai-go/pkg/redis"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrQuotaExceeded Token 配额已用尽
+var ErrQuotaExceeded = errors.New("Token配额已用尽")
+
+// LLMQuotaUsage 用户配额使用情况
+type LLMQuotaUsage struct {
+	Enabled bool           `json:"enabled"`
+	Role    string         `json:"role"`
+	Daily   LLMQuotaPeriod `json:"daily"`
+	Monthly LLMQuotaPeriod `json:"monthly"`
+}
+
+// LLMQuotaPeriod 单个周期的额度
+type LLMQuotaPeriod struct {
+	Limit     int64     `json:"limit"` // 0 表示不限
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"` // 不限时为 -1
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// LLMQuota 用户 Token 配额：Redis 记录当日/当月用量，定期按调用日志对账
+type LLMQuota struct {
+	rdb      *redisclient.Client
+	logRepo  repository.LLMCallLogRepository
+	userRepo repository.UserRepository
+	cfg      config.LLMQuotaConfig
+}
+
+// NewLLMQuota 创建配额检查器；rdb 为空时直接按调用日志统计
+func NewLLMQuota(rdb *redisclient.Client, logRepo repository.LLMCallLogRepository, userRepo repository.UserRepository, cfg config.LLMQuotaConfig) *LLMQuota {
+	if cfg.ReconcileSeconds <= 0 {
+		cfg.ReconcileSeconds = 300
+	}
+	return &LLMQuota{rdb: rdb, logRepo: logRepo, userRepo: userRepo, cfg: cfg}
+}
+
+// quotaPeriod 统计周期
+type quotaPeriod struct {
+	key   string
+	start time.Time
+	end   time.Time
+}
+
+func dailyPeriod(userID int64, now time.Time) quotaPeriod {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return quotaPeriod{
+		key:   fmt.Sprintf("llm:usage:%d:d:%s", userID, start.Format("20060102")),
+		start: start,
+		end:   start.AddDate(0, 0, 1),
+	}
+}
+
+func monthlyPeriod(userID int64, now time.Time) quotaPeriod {
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return quotaPeriod{
+		key:   fmt.Sprintf("llm:usage:%d:m:%s", userID, start.Format("200601")),
+		start: start,
+		end:   start.AddDate(0, 1, 0),
+	}
+}
+
+// Check 调用前检查配额，任一周期用尽时返回 ErrQuotaExceeded。
+// 仅做事前检查，并发请求或单次大额调用可能使用量略超额度。
+func (q *LLMQuota) Check(ctx context.Context, userID int64) error {
+	if q == nil || !q.cfg.Enable || userID == 0 {
+		return nil
+	}
+	usage, err := q.Usage(ctx, userID)
+	if err != nil {
+		// 配额统计失败不阻断调用
+		log.Errorf("查询用户Token用量失败 user_id=%d: %v", userID, err)
+		return nil
+	}
+	if usage.Daily.Limit > 0 && usage.Daily.Remaining <= 0 {
+		return ErrQuotaExceeded
+	}
+	if usage.Monthly.Limit > 0 && usage.Monthly.Remaining <= 0 {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Add 调用完成后累加用量
+func (q *LLMQuota) Add(ctx context.Context, userID int64, tokens int) {
+	if q == nil || q.rdb == nil || userID == 0 || tokens <= 0 {
+		return
+	}
+	now := time.Now()
+	pipe := q.rdb.RDB.Pipeline()
+	for _, p := range []quotaPeriod{dailyPeriod(userID, now), monthlyPeriod(userID, now)} {
+		pipe.IncrBy(ctx, p.key, int64(tokens))
+		pipe.ExpireAt(ctx, p.key, p.end.Add(time.Hour))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Errorf("累加用户Token用量失败 user_id=%d: %v", userID, err)
+	}
+}
+
+// Usage 查询用户当日、当月用量与剩余额度
+func (q *LLMQuota) Usage(ctx context.Context, userID int64) (*LLMQuotaUsage, error) {
+	user, err := q.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	limit := q.limitFor(user.Role)
+	now := time.Now()
+
+	daily := dailyPeriod(userID, now)
+	monthly := monthlyPeriod(userID, now)
+	dailyUsed, err := q.used(ctx, userID, daily)
+	if err != nil {
+		return nil, err
+	}
+	monthlyUsed, err := q.used(ctx, userID, monthly)
+	if err != nil {
+		return nil, err
+	}
+	return &LLMQuotaUsage{
+		Enabled: q.cfg.Enable,
+		Role:    user.Role,
+		Daily:   newQuotaPeriod(limit.DailyTokens, dailyUsed, daily.end),
+		Monthly: newQuotaPeriod(limit.MonthlyTokens, monthlyUsed, monthly.end),
+	}, nil
+}
+
+func newQuotaPeriod(limit, used int64, resetAt time.Time) LLMQuotaPeriod {
+	remaining := int64(-1)
+	if limit > 0 {
+		remaining = limit - used
+		if remaining < 0 {
+			remaining = 0
+		}
+	}
+	return LLMQuotaPeriod{Limit: limit, Used: used, Remaining: remaining, ResetAt: resetAt}
+}
+
+// limitFor 按角色取额度，未配置的角色使用默认值
+func (q *LLMQuota) limitFor(role string) config.LLMQuotaLimit {
+	if l, ok := q.cfg.Roles[role]; ok {
+		return l
+	}
+	return config.LLMQuotaLimit{DailyTokens: q.cfg.DailyTokens, MonthlyTokens: q.cfg.MonthlyTokens}
+}
+
+// used 读取周期内用量；计数不存在或对账间隔已到时，以调用日志为准重建计数
+func (q *LLMQuota) used(ctx context.Context, userID int64, p quotaPeriod) (int64, error) {
+	if q.rdb == nil {
+		return q.logRepo.SumTokensByUser(ctx, userID, p.start)
+	}
+	syncedKey := p.key + ":synced"
+	n, err := q.rdb.RDB.Exists(ctx, p.key, syncedKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n == 2 {
+		v, err := q.rdb.RDB.Get(ctx, p.key).Int64()
+		if err == nil {
+			return v, nil
+		}
+		if !errors.Is(err, redis.Nil) {
+			return 0, err
+		}
+	}
+
+	total, err := q.logRepo.SumTokensByUser(ctx, userID, p.start)
+	if err != nil {
+		return 0, err
+	}
+	pipe := q.rdb.RDB.Pipeline()
+	pipe.Set(ctx, p.key, total, time.Until(p.end.Add(time.Hour)))
+	pipe.Set(ctx, syncedKey, 1, time.Duration(q.cfg.ReconcileSeconds)*time.Second)
+	if _, err := pipe.Exec(ctx); err != nil {
+		log.Errorf("重建用户Token用量计数失败 user_id=%d: %v", userID, err)
+	}
+	return total, nil
+}
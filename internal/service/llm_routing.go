@@ -0,0 +1,80 @@
+package service
+
+import (
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+
+	"manjing-ai-go/internal/model"
+)
+
+// defaultModelWeight 未指定权重时的默认值
+const defaultModelWeight = 100
+
+// routeChain 在每个优先级内按权重排序降级链：首个模型即本次分配的分组，其余按权重依次作为降级备选。
+// 开启 sticky 时以用户ID代替随机数，权重不变时同一用户总是分配到同一模型。
+func (s *LLMServiceImpl) routeChain(chain []model.LLMModel, userID int64, purpose string) []model.LLMModel {
+	if len(chain) < 2 {
+		return chain
+	}
+	stickyKey := ""
+	if userID != 0 && s.isSticky(purpose) {
+		stickyKey = strconv.FormatInt(userID, 10) + ":" + purpose
+	}
+	return orderByWeight(chain, stickyKey, rand.Float64)
+}
+
+// isSticky 用途是否按用户固定分配
+func (s *LLMServiceImpl) isSticky(purpose string) bool {
+	if s.cfg.Routing.Sticky {
+		return true
+	}
+	for _, p := range s.cfg.Routing.StickyPurposes {
+		if p == purpose {
+			return true
+		}
+	}
+	return false
+}
+
+// orderByWeight 加权无放回抽样（Efraimidis-Spirakis）：每个模型取 u^(1/w) 作为排序键，降序即抽样顺序。
+// 链已按 priority 排好序，只在相同 priority 内重排；权重为 0 的模型排在同优先级末尾。
+func orderByWeight(chain []model.LLMModel, stickyKey string, random func() float64) []model.LLMModel {
+	ordered := make([]model.LLMModel, len(chain))
+	copy(ordered, chain)
+	keys := make(map[int64]float64, len(ordered))
+	for _, m := range ordered {
+		if m.Weight <= 0 {
+			keys[m.ID] = -1
+			continue
+		}
+		u := random()
+		if stickyKey != "" {
+			u = stickyUniform(stickyKey, m.ID)
+		}
+		keys[m.ID] = math.Pow(u, 1/float64(m.Weight))
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Priority != ordered[j].Priority {
+			return ordered[i].Priority < ordered[j].Priority
+		}
+		return keys[ordered[i].ID] > keys[ordered[j].ID]
+	})
+	return ordered
+}
+
+// stickyUniform 由用户与模型哈希出 (0,1) 内的确定值
+func stickyUniform(key string, modelID int64) float64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key + ":" + strconv.FormatInt(modelID, 10)))
+	// FNV 对末尾字节的差异扩散不充分，再做一次 splitmix64 混合，避免不同模型的取值高度相关
+	x := h.Sum64()
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return (float64(x>>11) + 0.5) / (1 << 53)
+}
@@ -2,15 +2,21 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"manjing-ai-go/config"
 	"manjing-ai-go/internal/model"
 	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/pkg/jsonschema"
 	"manjing-ai-go/pkg/llm"
+	"manjing-ai-go/pkg/secret"
 
 	log "github.com/sirupsen/logrus"
+	"gorm.io/datatypes"
 	"gorm.io/gorm"
 )
 
@@ -22,57 +28,105 @@ type LLMService interface {
 	GetModel(ctx context.Context, id int64) (*model.LLMModel, error)
 	UpdateModel(ctx context.Context, id int64, req LLMModelUpdate) (*model.LLMModel, error)
 	DeleteModel(ctx context.Context, id int64) error
+	TestModel(ctx context.Context, id int64) (*LLMModelTestResult, error)
+	// 提示词模板
+	CreatePrompt(ctx context.Context, userID int64, req LLMPromptCreate) (*model.LLMPrompt, error)
+	ListPrompts(ctx context.Context, query repository.LLMPromptListQuery) ([]model.LLMPrompt, int64, error)
+	GetPrompt(ctx context.Context, id int64) (*model.LLMPrompt, error)
+	UpdatePrompt(ctx context.Context, id int64, req LLMPromptUpdate) (*model.LLMPrompt, error)
+	DeletePrompt(ctx context.Context, id int64) error
 	// 对话
 	Chat(ctx context.Context, userID int64, req LLMChatRequest) (*LLMChatResponse, error)
+	ChatStream(ctx context.Context, userID int64, req LLMChatRequest, onDelta llm.StreamHandler) (*LLMChatResponse, error)
+	PurgeCache(ctx context.Context) (int64, error)
+	Tools() []*LLMTool
+	Tokenize(ctx context.Context, req LLMTokenizeRequest) (*LLMTokenizeResult, error)
 	// 日志
 	ListLogs(ctx context.Context, query repository.LLMCallLogListQuery) ([]model.LLMCallLog, int64, error)
 	LogStats(ctx context.Context, query repository.LLMCallLogStatsQuery) (*repository.LLMCallLogStats, []repository.LLMCallLogGroupStats, error)
+	LogSeries(ctx context.Context, query repository.LLMCallLogSeriesQuery) (*repository.LLMCallLogSeries, error)
+	GetPayload(ctx context.Context, callLogID int64) (*model.LLMCallPayload, error)
+	// 配额
+	Usage(ctx context.Context, userID int64) (*LLMQuotaUsage, error)
 }
 
 // LLMModelCreate 创建模型配置请求
 type LLMModelCreate struct {
-	Name        string  `json:"name"`
-	Provider    string  `json:"provider"`
-	BaseURL     string  `json:"base_url"`
-	APIKey      string  `json:"api_key"`
-	Model       string  `json:"model"`
-	MaxTokens   int     `json:"max_tokens"`
-	Temperature float64 `json:"temperature"`
-	Timeout     int     `json:"timeout"`
-	Purpose     string  `json:"purpose"`
+	Name             string          `json:"name"`
+	Provider         string          `json:"provider"`
+	BaseURL          string          `json:"base_url"`
+	APIKey           string          `json:"api_key"`
+	Model            string          `json:"model"`
+	MaxTokens        int             `json:"max_tokens"`
+	ContextWindow    int             `json:"context_window"`
+	Temperature      float64         `json:"temperature"`
+	Timeout          int             `json:"timeout"`
+	Purpose          string          `json:"purpose"`
+	Priority         int             `json:"priority"`
+	Weight           *int            `json:"weight"` // 为空时默认100
+	ExtraConfig      json.RawMessage `json:"extra_config"`
+	InputPrice       float64         `json:"input_price"`
+	OutputPrice      float64         `json:"output_price"`
+	CachedInputPrice float64         `json:"cached_input_price"`
+	Currency         string          `json:"currency"`
 }
 
 // LLMModelUpdate 更新模型配置请求
 type LLMModelUpdate struct {
-	Name        *string  `json:"name"`
-	Provider    *string  `json:"provider"`
-	BaseURL     *string  `json:"base_url"`
-	APIKey      *string  `json:"api_key"`
-	Model       *string  `json:"model"`
-	MaxTokens   *int     `json:"max_tokens"`
-	Temperature *float64 `json:"temperature"`
-	Timeout     *int     `json:"timeout"`
-	Purpose     *string  `json:"purpose"`
-	IsActive    *bool    `json:"is_active"`
+	Name             *string         `json:"name"`
+	Provider         *string         `json:"provider"`
+	BaseURL          *string         `json:"base_url"`
+	APIKey           *string         `json:"api_key"`
+	Model            *string         `json:"model"`
+	MaxTokens        *int            `json:"max_tokens"`
+	ContextWindow    *int            `json:"context_window"`
+	Temperature      *float64        `json:"temperature"`
+	Timeout          *int            `json:"timeout"`
+	Purpose          *string         `json:"purpose"`
+	Priority         *int            `json:"priority"`
+	Weight           *int            `json:"weight"`
+	IsActive         *bool           `json:"is_active"`
+	ExtraConfig      json.RawMessage `json:"extra_config"`
+	InputPrice       *float64        `json:"input_price"`
+	OutputPrice      *float64        `json:"output_price"`
+	CachedInputPrice *float64        `json:"cached_input_price"`
+	Currency         *string         `json:"currency"`
 }
 
 // LLMChatRequest 对话请求
 type LLMChatRequest struct {
-	Messages       []llm.ChatMessage `json:"messages"`
-	Purpose        string            `json:"purpose"`
-	ModelID        *int64            `json:"model_id"`
-	ResponseFormat string            `json:"response_format"` // text / json
-	MaxTokens      *int              `json:"max_tokens"`
-	Temperature    *float32          `json:"temperature"`
+	Messages       []llm.ChatMessage      `json:"messages"`
+	Purpose        string                 `json:"purpose"`
+	ModelID        *int64                 `json:"model_id"`
+	ResponseFormat string                 `json:"response_format"` // text / json
+	MaxTokens      *int                   `json:"max_tokens"`
+	Temperature    *float32               `json:"temperature"`
+	PromptID       *int64                 `json:"prompt_id"`   // 指定提示词模板ID
+	Variables      map[string]interface{} `json:"variables"`   // 模板变量
+	Cache          bool                   `json:"cache"`       // 温度非0时也使用响应缓存
+	JSONSchema     json.RawMessage        `json:"json_schema"` // 输出需符合的 JSON Schema，不符合时自动要求模型修正
+	Tools          []string               `json:"tools"`       // 允许模型调用的服务端工具名称
+	Overflow       string                 `json:"overflow"`    // 超出上下文窗口时的处理方式 reject / trim，为空时按配置
+
+	prompt     *model.LLMPrompt // 实际使用的提示词模板
+	armModelID *int64           // 按权重分配到的模型ID
+	toolDefs   []llm.Tool       // 工具定义
+	toolChoice string           // 工具选择策略，为空时由模型决定
 }
 
 // LLMChatResponse 对话响应
 type LLMChatResponse struct {
-	Content    string `json:"content"`
-	Model      string `json:"model"`
-	Provider   string `json:"provider"`
-	Usage      LLMUsage `json:"usage"`
-	DurationMs int    `json:"duration_ms"`
+	Content       string             `json:"content"`
+	Model         string             `json:"model"`
+	Provider      string             `json:"provider"`
+	Usage         LLMUsage           `json:"usage"`
+	DurationMs    int                `json:"duration_ms"`
+	PromptID      *int64             `json:"prompt_id,omitempty"`
+	PromptVersion *int               `json:"prompt_version,omitempty"`
+	Cached        bool               `json:"cached,omitempty"` // 是否命中响应缓存
+	FinishReason  string             `json:"finish_reason,omitempty"`
+	RepairCount   int                `json:"repair_count,omitempty"` // 因不符合 json_schema 重新生成的次数
+	ToolCalls     []LLMToolCallTrace `json:"tool_calls,omitempty"`   // 服务端执行的工具调用
 }
 
 // LLMUsage Token用量
@@ -84,19 +138,50 @@ type LLMUsage struct {
 
 // LLMServiceImpl LLM服务实现
 type LLMServiceImpl struct {
-	modelRepo repository.LLMModelRepository
-	logRepo   repository.LLMCallLogRepository
-	client    *llm.Client
-	cfg       config.LLMConfig
+	modelRepo  repository.LLMModelRepository
+	logRepo    repository.LLMCallLogRepository
+	promptRepo repository.LLMPromptRepository
+	client     *llm.Client
+	cfg        config.LLMConfig
+	keyring    *secret.Keyring    // API密钥加密，未配置主密钥时为空
+	quota      *LLMQuota          // 用户Token配额，为空时不限制
+	capture    *LLMPayloadCapture // 调用内容采样，为空时不记录
+	cache      *LLMResponseCache  // 响应缓存，为空时不缓存
+	tools      *LLMToolRegistry   // 服务端工具
+	images     *LLMImageResolver  // 图片资源解析
+	limiter    *LLMRateLimiter    // 模型限流，为空时不限制
 }
 
 // NewLLMService 创建LLM服务
-func NewLLMService(modelRepo repository.LLMModelRepository, logRepo repository.LLMCallLogRepository, client *llm.Client, cfg config.LLMConfig) *LLMServiceImpl {
+func NewLLMService(modelRepo repository.LLMModelRepository, logRepo repository.LLMCallLogRepository, promptRepo repository.LLMPromptRepository, client *llm.Client, cfg config.LLMConfig, keyring *secret.Keyring, quota *LLMQuota, capture *LLMPayloadCapture, cache *LLMResponseCache, tools *LLMToolRegistry, images *LLMImageResolver, limiter *LLMRateLimiter) *LLMServiceImpl {
+	if cfg.Health.TimeoutSeconds <= 0 {
+		cfg.Health.TimeoutSeconds = 15
+	}
+	if cfg.Health.FailureThreshold <= 0 {
+		cfg.Health.FailureThreshold = 3
+	}
+	if cfg.Structured.MaxRepairs < 0 {
+		cfg.Structured.MaxRepairs = 0
+	}
+	if cfg.Tools.MaxRounds <= 0 {
+		cfg.Tools.MaxRounds = 5
+	}
+	if cfg.Context.Overflow != LLMOverflowTrim {
+		cfg.Context.Overflow = LLMOverflowReject
+	}
 	return &LLMServiceImpl{
-		modelRepo: modelRepo,
-		logRepo:   logRepo,
-		client:    client,
-		cfg:       cfg,
+		modelRepo:  modelRepo,
+		logRepo:    logRepo,
+		promptRepo: promptRepo,
+		client:     client,
+		cfg:        cfg,
+		keyring:    keyring,
+		quota:      quota,
+		capture:    capture,
+		cache:      cache,
+		tools:      tools,
+		images:     images,
+		limiter:    limiter,
 	}
 }
 
@@ -130,21 +215,55 @@ func (s *LLMServiceImpl) CreateModel(ctx context.Context, req LLMModelCreate) (*
 	if req.Purpose == "" {
 		req.Purpose = "default"
 	}
+	if req.InputPrice < 0 || req.OutputPrice < 0 || req.CachedInputPrice < 0 {
+		return nil, errors.New("单价不能为负数")
+	}
+	if req.ContextWindow < 0 {
+		return nil, errors.New("上下文窗口不能为负数")
+	}
+	weight := defaultModelWeight
+	if req.Weight != nil {
+		if *req.Weight < 0 {
+			return nil, errors.New("权重不能为负数")
+		}
+		weight = *req.Weight
+	}
+	currency, err := normalizeCurrency(req.Currency)
+	if err != nil {
+		return nil, err
+	}
+	extra, err := validateExtraConfig(req.ExtraConfig)
+	if err != nil {
+		return nil, err
+	}
+	encryptedKey, err := s.encryptAPIKey(req.APIKey)
+	if err != nil {
+		return nil, err
+	}
 
 	now := time.Now()
 	m := &model.LLMModel{
-		Name:        req.Name,
-		Provider:    req.Provider,
-		BaseURL:     req.BaseURL,
-		APIKey:      req.APIKey,
-		Model:       req.Model,
-		MaxTokens:   req.MaxTokens,
-		Temperature: req.Temperature,
-		Timeout:     req.Timeout,
-		Purpose:     req.Purpose,
-		IsActive:    true,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+		Name:             req.Name,
+		Provider:         req.Provider,
+		BaseURL:          req.BaseURL,
+		APIKey:           encryptedKey,
+		APIKeyMask:       model.MaskAPIKey(req.APIKey),
+		Model:            req.Model,
+		MaxTokens:        req.MaxTokens,
+		ContextWindow:    req.ContextWindow,
+		Temperature:      req.Temperature,
+		Timeout:          req.Timeout,
+		Purpose:          req.Purpose,
+		Priority:         req.Priority,
+		Weight:           weight,
+		IsActive:         true,
+		ExtraConfig:      extra,
+		InputPrice:       req.InputPrice,
+		OutputPrice:      req.OutputPrice,
+		CachedInputPrice: req.CachedInputPrice,
+		Currency:         currency,
+		CreatedAt:        now,
+		UpdatedAt:        now,
 	}
 
 	if err := s.modelRepo.Create(ctx, m); err != nil {
@@ -153,6 +272,39 @@ func (s *LLMServiceImpl) CreateModel(ctx context.Context, req LLMModelCreate) (*
 	return m, nil
 }
 
+// validateExtraConfig 校验扩展配置为合法JSON对象且约定字段格式正确
+func validateExtraConfig(raw json.RawMessage) (datatypes.JSON, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	m := model.LLMModel{ExtraConfig: datatypes.JSON(raw)}
+	extra, err := m.ParseExtraConfig()
+	if err != nil {
+		return nil, errors.New("extra_config 格式错误")
+	}
+	if l := extra.Limits; l != nil && (l.MaxConcurrency < 0 || l.RPM < 0 || l.TPM < 0) {
+		return nil, errors.New("extra_config.limits 不能为负数")
+	}
+	return datatypes.JSON(raw), nil
+}
+
+// normalizeCurrency 币种统一为大写三位字母代码，默认 CNY
+func normalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return "CNY", nil
+	}
+	if len(currency) != 3 {
+		return "", errors.New("币种格式错误")
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return "", errors.New("币种格式错误")
+		}
+	}
+	return currency, nil
+}
+
 func (s *LLMServiceImpl) ListModels(ctx context.Context, query repository.LLMModelListQuery) ([]model.LLMModel, int64, error) {
 	return s.modelRepo.List(ctx, query)
 }
@@ -188,7 +340,15 @@ func (s *LLMServiceImpl) UpdateModel(ctx context.Context, id int64, req LLMModel
 		updates["base_url"] = *req.BaseURL
 	}
 	if req.APIKey != nil {
-		updates["api_key"] = *req.APIKey
+		if *req.APIKey == "" {
+			return nil, errors.New("API密钥不能为空")
+		}
+		encryptedKey, err := s.encryptAPIKey(*req.APIKey)
+		if err != nil {
+			return nil, err
+		}
+		updates["api_key"] = encryptedKey
+		updates["api_key_mask"] = model.MaskAPIKey(*req.APIKey)
 	}
 	if req.Model != nil {
 		updates["model"] = *req.Model
@@ -205,9 +365,51 @@ func (s *LLMServiceImpl) UpdateModel(ctx context.Context, id int64, req LLMModel
 	if req.Purpose != nil {
 		updates["purpose"] = *req.Purpose
 	}
+	if req.Priority != nil {
+		updates["priority"] = *req.Priority
+	}
+	if req.Weight != nil {
+		if *req.Weight < 0 {
+			return nil, errors.New("权重不能为负数")
+		}
+		updates["weight"] = *req.Weight
+	}
+	if req.ContextWindow != nil {
+		if *req.ContextWindow < 0 {
+			return nil, errors.New("上下文窗口不能为负数")
+		}
+		updates["context_window"] = *req.ContextWindow
+	}
 	if req.IsActive != nil {
 		updates["is_active"] = *req.IsActive
 	}
+	if len(req.ExtraConfig) > 0 {
+		extra, err := validateExtraConfig(req.ExtraConfig)
+		if err != nil {
+			return nil, err
+		}
+		updates["extra_config"] = extra
+	}
+	for col, price := range map[string]*float64{
+		"input_price":        req.InputPrice,
+		"output_price":       req.OutputPrice,
+		"cached_input_price": req.CachedInputPrice,
+	} {
+		if price == nil {
+			continue
+		}
+		if *price < 0 {
+			return nil, errors.New("单价不能为负数")
+		}
+		updates[col] = *price
+	}
+	if req.Currency != nil {
+		currency, err := normalizeCurrency(*req.Currency)
+		if err != nil {
+			return nil, err
+		}
+		updates["currency"] = currency
+	}
 	if len(updates) == 0 {
 		return s.modelRepo.FindByID(ctx, id)
 	}
@@ -237,26 +439,142 @@ func (s *LLMServiceImpl) DeleteModel(ctx context.Context, id int64) error {
 // ======= 对话 =======
 
 func (s *LLMServiceImpl) Chat(ctx context.Context, userID int64, req LLMChatRequest) (*LLMChatResponse, error) {
+	if err := s.quota.Check(ctx, userID); err != nil {
+		return nil, err
+	}
+	var schema *jsonschema.Schema
+	if len(req.JSONSchema) > 0 {
+		var err error
+		if schema, err = jsonschema.Compile(req.JSONSchema); err != nil {
+			return nil, fmt.Errorf("json_schema 格式错误: %v", err)
+		}
+		req.ResponseFormat = "json"
+	}
+	if len(req.Tools) > 0 {
+		if schema != nil {
+			return nil, errors.New("json_schema 与 tools 不能同时使用")
+		}
+		defs, err := s.tools.Definitions(req.Tools)
+		if err != nil {
+			return nil, err
+		}
+		req.toolDefs = defs
+	}
+	targets, err := s.resolveTargets(ctx, userID, &req)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.toolDefs) > 0 {
+		return s.chatWithTools(ctx, userID, req, targets)
+	}
+	if schema != nil {
+		req.Messages = withSchemaInstruction(req.Messages, req.JSONSchema)
+		return s.chatStructured(ctx, userID, req, targets, schema)
+	}
+
+	target, result, cached, err := s.chatOnce(ctx, userID, req, targets)
+	if err != nil {
+		return nil, err
+	}
+	if !cached {
+		s.cache.Store(ctx, req, target, result)
+	}
+	resp := buildChatResponse(req, target, result)
+	resp.Cached = cached
+	return resp, nil
+}
+
+// chatOnce 先查响应缓存，未命中时按降级链调用；写入缓存由调用方在确认结果可用后进行
+func (s *LLMServiceImpl) chatOnce(ctx context.Context, userID int64, req LLMChatRequest, targets []*llmTarget) (*llmTarget, *llm.ChatResult, bool, error) {
+	start := time.Now()
+	if target, result, hit := s.cache.Lookup(ctx, req, targets); hit {
+		result.DurationMs = int(time.Since(start).Milliseconds())
+		s.recordCacheHit(ctx, userID, req, target, result)
+		return target, result, true, nil
+	}
+	target, result, err := s.callWithFallback(ctx, userID, req, targets, func(messages []llm.ChatMessage, opts []llm.ChatOption) (*llm.ChatResult, error) {
+		return s.client.ChatCompletion(ctx, messages, opts...)
+	}, nil)
+	return target, result, false, err
+}
+
+// Tools 返回可用的服务端工具
+func (s *LLMServiceImpl) Tools() []*LLMTool {
+	return s.tools.List()
+}
+
+// PurgeCache 清空响应缓存
+func (s *LLMServiceImpl) PurgeCache(ctx context.Context) (int64, error) {
+	return s.cache.Purge(ctx)
+}
+
+// ChatStream 流式对话，增量内容通过 onDelta 回调输出；客户端断开时同样记录调用日志
+func (s *LLMServiceImpl) ChatStream(ctx context.Context, userID int64, req LLMChatRequest, onDelta llm.StreamHandler) (*LLMChatResponse, error) {
+	if err := s.quota.Check(ctx, userID); err != nil {
+		return nil, err
+	}
+	if len(req.JSONSchema) > 0 || len(req.Tools) > 0 {
+		return nil, errors.New("流式对话不支持 json_schema 与 tools")
+	}
+	targets, err := s.resolveTargets(ctx, userID, &req)
+	if err != nil {
+		return nil, err
+	}
+	// 已向客户端输出内容后不能再切换模型
+	streamed := false
+	handler := func(delta string) error {
+		streamed = true
+		return onDelta(delta)
+	}
+	target, result, err := s.callWithFallback(ctx, userID, req, targets, func(messages []llm.ChatMessage, opts []llm.ChatOption) (*llm.ChatResult, error) {
+		return s.client.ChatCompletionStream(ctx, messages, handler, opts...)
+	}, func() bool { return !streamed })
+	if err != nil {
+		return nil, err
+	}
+	return buildChatResponse(req, target, result), nil
+}
+
+// llmTarget 本次调用实际使用的模型
+type llmTarget struct {
+	baseURL       string
+	apiKey        string
+	modelName     string
+	provider      string
+	maxTokens     int
+	temperature   float32
+	timeout       time.Duration // 调用超时，见 llm.WithTimeout
+	dbModelID     *int64
+	retry         *llm.RetryPolicy      // 模型级重试策略，为空时使用客户端默认
+	price         model.LLMPrice        // 调用时的单价，用于计算费用
+	captureRate   *float64              // 模型级内容采样率，为空时按用途或全局配置
+	contextWindow int                   // 上下文窗口，0 表示不检查
+	limits        *model.LLMLimitConfig // 模型调用限额，为空时不限制
+}
+
+// resolveTargets 校验请求并确定候选模型链：model_id > purpose 降级链 > config.yaml
+func (s *LLMServiceImpl) resolveTargets(ctx context.Context, userID int64, req *LLMChatRequest) ([]*llmTarget, error) {
+	if err := s.applyPrompt(ctx, req); err != nil {
+		return nil, err
+	}
 	if len(req.Messages) == 0 {
 		return nil, errors.New("messages不能为空")
 	}
+	if req.Overflow != "" && req.Overflow != LLMOverflowReject && req.Overflow != LLMOverflowTrim {
+		return nil, errors.New("overflow 只能是 reject 或 trim")
+	}
+	messages, err := s.images.Resolve(ctx, userID, req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	req.Messages = messages
 	if req.Purpose == "" {
 		req.Purpose = "default"
 	}
 
-	// 确定模型配置：model_id > purpose > config.yaml
-	var (
-		baseURL     = s.cfg.Default.BaseURL
-		apiKey      = s.cfg.Default.APIKey
-		modelName   = s.cfg.Default.Model
-		provider    = "config"
-		maxTokens   = s.cfg.Default.MaxTokens
-		temperature = s.cfg.Default.Temperature
-		dbModelID   *int64
-	)
-
+	var targets []*llmTarget
 	if req.ModelID != nil {
-		// 通过 model_id 指定模型
+		// 通过 model_id 指定模型，不做降级
 		dbModel, err := s.modelRepo.FindByID(ctx, *req.ModelID)
 		if err != nil {
 			if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -264,109 +582,321 @@ func (s *LLMServiceImpl) Chat(ctx context.Context, userID int64, req LLMChatRequ
 			}
 			return nil, err
 		}
-		baseURL = dbModel.BaseURL
-		apiKey = dbModel.APIKey
-		modelName = dbModel.Model
-		provider = dbModel.Provider
-		maxTokens = dbModel.MaxTokens
-		temperature = float32(dbModel.Temperature)
-		dbModelID = &dbModel.ID
+		target, err := s.targetFromModel(dbModel)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
 	} else {
-		// 通过 purpose 查找数据库模型
-		dbModel, err := s.modelRepo.FindActiveByPurpose(ctx, req.Purpose)
-		if err == nil && dbModel != nil {
-			baseURL = dbModel.BaseURL
-			apiKey = dbModel.APIKey
-			modelName = dbModel.Model
-			provider = dbModel.Provider
-			maxTokens = dbModel.MaxTokens
-			temperature = float32(dbModel.Temperature)
-			dbModelID = &dbModel.ID
+		// 通过 purpose 查找数据库模型降级链
+		chain, err := s.modelRepo.FindActiveChainByPurpose(ctx, req.Purpose)
+		if err != nil {
+			log.Errorf("查询模型降级链失败 purpose=%s: %v", req.Purpose, err)
+		}
+		chain = s.routeChain(chain, userID, req.Purpose)
+		if len(chain) > 0 {
+			req.armModelID = &chain[0].ID
+		}
+		for i := range chain {
+			target, err := s.targetFromModel(&chain[i])
+			if err != nil {
+				log.Errorf("模型配置不可用 id=%d: %v", chain[i].ID, err)
+				continue
+			}
+			targets = append(targets, target)
+		}
+		// 无可用数据库模型则使用 config.yaml 默认配置
+		if len(targets) == 0 {
+			targets = append(targets, &llmTarget{
+				baseURL:       s.cfg.Default.BaseURL,
+				apiKey:        s.cfg.Default.APIKey,
+				modelName:     s.cfg.Default.Model,
+				provider:      "config",
+				maxTokens:     s.cfg.Default.MaxTokens,
+				temperature:   s.cfg.Default.Temperature,
+				timeout:       time.Duration(s.cfg.Default.Timeout) * time.Second,
+				contextWindow: s.cfg.Default.ContextWindow,
+				price: model.LLMPrice{
+					Input:       s.cfg.Default.InputPrice,
+					Output:      s.cfg.Default.OutputPrice,
+					CachedInput: s.cfg.Default.CachedInputPrice,
+					Currency:    s.cfg.Default.Currency,
+				},
+			})
 		}
-		// 查找失败则使用 config.yaml 默认配置
 	}
 
-	if apiKey == "" {
+	usable := targets[:0]
+	for _, t := range targets {
+		if t.apiKey == "" {
+			continue
+		}
+		// 请求参数覆盖
+		if req.MaxTokens != nil {
+			t.maxTokens = *req.MaxTokens
+		}
+		if req.Temperature != nil {
+			t.temperature = *req.Temperature
+		}
+		usable = append(usable, t)
+	}
+	if len(usable) == 0 {
 		return nil, errors.New("无可用模型配置")
 	}
+	return usable, nil
+}
 
-	// 请求参数覆盖
-	if req.MaxTokens != nil {
-		maxTokens = *req.MaxTokens
-	}
-	if req.Temperature != nil {
-		temperature = *req.Temperature
+// callWithFallback 依次尝试候选模型，遇到可重试错误时切换到下一个模型；每次尝试均记录日志。
+// 发送前按模型上下文窗口检查或截断消息，放不下的模型直接跳过；超出模型限额时排队，排队超时同样切换。
+// canFallback 为空表示总是允许切换。成功时返回实际使用的模型与结果。
+func (s *LLMServiceImpl) callWithFallback(ctx context.Context, userID int64, req LLMChatRequest, targets []*llmTarget,
+	call func(messages []llm.ChatMessage, opts []llm.ChatOption) (*llm.ChatResult, error), canFallback func() bool) (*llmTarget, *llm.ChatResult, error) {
+	var lastErr error
+	for i, target := range targets {
+		messages, err := s.fitContext(req, target)
+		if err != nil {
+			log.Warnf("输入超出模型上下文窗口，跳过 purpose=%s model=%s: %v", req.Purpose, target.modelName, err)
+			lastErr = err
+			continue
+		}
+		permit, err := s.limiter.Acquire(ctx, target, messages)
+		if err != nil {
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			log.Warnf("模型限流排队失败，跳过 purpose=%s model=%s: %v", req.Purpose, target.modelName, errors.Unwrap(err))
+			continue
+		}
+		sent := req
+		sent.Messages = messages
+		result, err := call(messages, chatOptions(target, sent))
+		if err != nil {
+			permit.Release(nil)
+		} else {
+			permit.Release(result)
+		}
+		s.recordCall(ctx, userID, sent, target, i+1, result, err)
+		if err == nil {
+			return target, result, nil
+		}
+		lastErr = err
+		if !isRetryableLLMErr(err) || ctx.Err() != nil || (canFallback != nil && !canFallback()) {
+			break
+		}
+		if i+1 < len(targets) {
+			log.Warnf("LLM调用失败，降级到下一个模型 purpose=%s model=%s attempt=%d: %v", req.Purpose, target.modelName, i+1, err)
+		}
 	}
+	return nil, nil, lastErr
+}
 
-	// 构建调用选项
+// chatOptions 构建调用选项
+func chatOptions(target *llmTarget, req LLMChatRequest) []llm.ChatOption {
 	opts := []llm.ChatOption{
-		llm.WithEndpoint(baseURL, apiKey),
-		llm.WithModel(modelName),
-		llm.WithMaxTokens(maxTokens),
-		llm.WithTemperature(temperature),
+		llm.WithProvider(target.provider),
+		llm.WithEndpoint(target.baseURL, target.apiKey),
+		llm.WithModel(target.modelName),
+		llm.WithMaxTokens(target.maxTokens),
+		llm.WithTemperature(target.temperature),
+		llm.WithTimeout(target.timeout),
 	}
 	if req.ResponseFormat == "json" {
 		opts = append(opts, llm.WithJSONMode())
 	}
+	if target.retry != nil {
+		opts = append(opts, llm.WithRetryPolicy(*target.retry))
+	}
+	if len(req.toolDefs) > 0 {
+		opts = append(opts, llm.WithTools(req.toolChoice, req.toolDefs...))
+	}
+	return opts
+}
 
-	// 调用 LLM
-	result, err := s.client.ChatCompletion(ctx, req.Messages, opts...)
+// isRetryableLLMErr 限流、超时、网络错误和上游5xx可切换到下一个模型
+func isRetryableLLMErr(err error) bool {
+	var llmErr *llm.Error
+	return errors.As(err, &llmErr) && llmErr.Retryable()
+}
 
-	// 记录日志
-	logStatus := int16(1)
-	var errMsg *string
-	durationMs := 0
-	promptTokens := 0
-	completionTokens := 0
-	totalTokens := 0
+// llmLogStatus 将调用错误映射为日志状态
+func llmLogStatus(err error) int16 {
+	if err == nil {
+		return model.LLMCallStatusSuccess
+	}
+	var llmErr *llm.Error
+	if !errors.As(err, &llmErr) {
+		return model.LLMCallStatusFailed
+	}
+	switch llmErr.Kind {
+	case llm.ErrKindTimeout:
+		return model.LLMCallStatusTimeout
+	case llm.ErrKindRateLimited:
+		return model.LLMCallStatusRateLimited
+	case llm.ErrKindAuth:
+		return model.LLMCallStatusAuthFailed
+	case llm.ErrKindBadRequest:
+		return model.LLMCallStatusBadRequest
+	case llm.ErrKindUpstream, llm.ErrKindNetwork:
+		return model.LLMCallStatusUpstream
+	case llm.ErrKindMalformedResponse:
+		return model.LLMCallStatusMalformed
+	case llm.ErrKindContextLength:
+		return model.LLMCallStatusContextLength
+	}
+	return model.LLMCallStatusFailed
+}
 
+// targetFromModel 由数据库模型构建调用目标，API密钥仅在此处解密
+func (s *LLMServiceImpl) targetFromModel(m *model.LLMModel) (*llmTarget, error) {
+	apiKey, err := s.decryptAPIKey(m.APIKey)
 	if err != nil {
-		logStatus = 2
-		msg := err.Error()
-		errMsg = &msg
-		if msg == "调用超时" {
-			logStatus = 3
+		return nil, err
+	}
+	target := &llmTarget{
+		baseURL:       m.BaseURL,
+		apiKey:        apiKey,
+		modelName:     m.Model,
+		provider:      m.Provider,
+		maxTokens:     m.MaxTokens,
+		temperature:   float32(m.Temperature),
+		timeout:       time.Duration(m.Timeout) * time.Second,
+		dbModelID:     &m.ID,
+		price:         m.Price(),
+		contextWindow: m.ContextWindow,
+	}
+	extra, err := m.ParseExtraConfig()
+	if err != nil {
+		log.Warnf("解析模型扩展配置失败 id=%d: %v", m.ID, err)
+		return target, nil
+	}
+	if extra.Retry != nil {
+		target.retry = &llm.RetryPolicy{
+			MaxAttempts: extra.Retry.MaxAttempts,
+			BaseBackoff: time.Duration(extra.Retry.BaseBackoffMs) * time.Millisecond,
+			MaxBackoff:  time.Duration(extra.Retry.MaxBackoffMs) * time.Millisecond,
+			Jitter:      extra.Retry.Jitter,
 		}
-	} else {
-		durationMs = result.DurationMs
-		promptTokens = result.PromptTokens
-		completionTokens = result.CompletionTokens
-		totalTokens = result.TotalTokens
 	}
+	if extra.Capture != nil {
+		rate := extra.Capture.SampleRate
+		target.captureRate = &rate
+	}
+	if extra.Limits.Enabled() {
+		target.limits = extra.Limits
+	}
+	return target, nil
+}
 
-	callLog := &model.LLMCallLog{
-		UserID:           userID,
-		ModelID:          dbModelID,
-		Provider:         provider,
-		Model:            modelName,
-		Purpose:          req.Purpose,
-		PromptTokens:     promptTokens,
-		CompletionTokens: completionTokens,
-		TotalTokens:      totalTokens,
-		DurationMs:       durationMs,
-		Status:           logStatus,
-		ErrorMessage:     errMsg,
-		CreatedAt:        time.Now(),
+// ErrMasterKeyNotConfigured 未配置 llm.secret.master_key，无法加解密模型API密钥
+var ErrMasterKeyNotConfigured = errors.New("未配置API密钥加密主密钥（llm.secret.master_key），无法保存或使用模型API密钥")
+
+// encryptAPIKey 加密API密钥用于落库
+func (s *LLMServiceImpl) encryptAPIKey(plain string) (string, error) {
+	if s.keyring == nil {
+		return "", ErrMasterKeyNotConfigured
+	}
+	return s.keyring.Encrypt(plain)
+}
+
+// decryptAPIKey 解密API密钥；未加密的历史数据原样返回
+func (s *LLMServiceImpl) decryptAPIKey(stored string) (string, error) {
+	if !secret.IsEncrypted(stored) {
+		return stored, nil
+	}
+	if s.keyring == nil {
+		return "", ErrMasterKeyNotConfigured
 	}
-	if logErr := s.logRepo.Create(ctx, callLog); logErr != nil {
-		log.Errorf("记录LLM调用日志失败: %v", logErr)
+	plain, err := s.keyring.Decrypt(stored)
+	if err != nil {
+		return "", errors.New("API密钥解密失败")
 	}
+	return plain, nil
+}
 
+// recordCall 记录调用日志；result 可能为流式中断时的部分结果
+func (s *LLMServiceImpl) recordCall(ctx context.Context, userID int64, req LLMChatRequest, target *llmTarget, attempt int, result *llm.ChatResult, err error) {
+	callLog := newCallLog(userID, req, target)
+	callLog.Status = llmLogStatus(err)
+	callLog.Attempt = int16(attempt)
 	if err != nil {
-		return nil, err
+		msg := err.Error()
+		var llmErr *llm.Error
+		if errors.As(err, &llmErr) && llmErr.Body != "" {
+			msg += ": " + llmErr.ProviderMessage()
+		}
+		callLog.ErrorMessage = &msg
+	}
+	response := ""
+	if result != nil {
+		callLog.DurationMs = result.DurationMs
+		callLog.PromptTokens = result.PromptTokens
+		callLog.CompletionTokens = result.CompletionTokens
+		callLog.TotalTokens = result.TotalTokens
+		callLog.CachedTokens = result.CachedTokens
+		callLog.Cost = target.price.Cost(result.PromptTokens, result.CachedTokens, result.CompletionTokens)
+		response = result.Content
+	}
+	s.saveCallLog(ctx, userID, req, target, callLog, response)
+}
+
+// recordCacheHit 记录命中响应缓存的调用，Token 与费用均为 0
+func (s *LLMServiceImpl) recordCacheHit(ctx context.Context, userID int64, req LLMChatRequest, target *llmTarget, result *llm.ChatResult) {
+	callLog := newCallLog(userID, req, target)
+	callLog.Status = model.LLMCallStatusSuccess
+	callLog.Attempt = 1
+	callLog.DurationMs = result.DurationMs
+	callLog.CacheHit = true
+	s.saveCallLog(ctx, userID, req, target, callLog, result.Content)
+}
+
+func newCallLog(userID int64, req LLMChatRequest, target *llmTarget) *model.LLMCallLog {
+	callLog := &model.LLMCallLog{
+		UserID:     userID,
+		ModelID:    target.dbModelID,
+		Provider:   target.provider,
+		Model:      target.modelName,
+		Purpose:    req.Purpose,
+		Currency:   target.price.Currency,
+		ArmModelID: req.armModelID,
+		CreatedAt:  time.Now(),
+	}
+	if req.prompt != nil {
+		callLog.PromptID = &req.prompt.ID
+		callLog.PromptVersion = &req.prompt.Version
+	}
+	return callLog
+}
+
+// saveCallLog 写入调用日志并累加配额、采样调用内容
+func (s *LLMServiceImpl) saveCallLog(ctx context.Context, userID int64, req LLMChatRequest, target *llmTarget, callLog *model.LLMCallLog, response string) {
+	// 客户端断开后 ctx 已取消，日志写入不能跟随取消
+	ctx = context.WithoutCancel(ctx)
+	if err := s.logRepo.Create(ctx, callLog); err != nil {
+		log.Errorf("记录LLM调用日志失败: %v", err)
 	}
+	s.quota.Add(ctx, userID, callLog.TotalTokens)
+	s.capture.Record(ctx, callLog.ID, req.Purpose, target.captureRate, req.Messages, response)
+}
 
-	return &LLMChatResponse{
+func buildChatResponse(req LLMChatRequest, target *llmTarget, result *llm.ChatResult) *LLMChatResponse {
+	resp := &LLMChatResponse{
 		Content:  result.Content,
-		Model:    modelName,
-		Provider: provider,
+		Model:    target.modelName,
+		Provider: target.provider,
 		Usage: LLMUsage{
 			PromptTokens:     result.PromptTokens,
 			CompletionTokens: result.CompletionTokens,
 			TotalTokens:      result.TotalTokens,
 		},
-		DurationMs: result.DurationMs,
-	}, nil
+		DurationMs:   result.DurationMs,
+		FinishReason: result.FinishReason,
+	}
+	if req.prompt != nil {
+		resp.PromptID = &req.prompt.ID
+		resp.PromptVersion = &req.prompt.Version
+	}
+	return resp
 }
 
 // ======= 日志查询 =======
@@ -376,5 +906,82 @@ func (s *LLMServiceImpl) ListLogs(ctx context.Context, query repository.LLMCallL
 }
 
 func (s *LLMServiceImpl) LogStats(ctx context.Context, query repository.LLMCallLogStatsQuery) (*repository.LLMCallLogStats, []repository.LLMCallLogGroupStats, error) {
+	if query.GroupBy == "" {
+		query.GroupBy = repository.LLMStatsByProvider
+	}
+	if !query.GroupBy.Valid() {
+		return nil, nil, errors.New("group_by 参数错误，可选 provider/purpose/model/model_id/user_id/status/day/arm")
+	}
 	return s.logRepo.Stats(ctx, query)
 }
+
+// seriesIntervalDurations 各时间粒度的桶宽与未指定起始时间时的默认跨度
+var seriesIntervalDurations = map[string]struct{ bucket, span time.Duration }{
+	"hour": {time.Hour, 24 * time.Hour},
+	"day":  {24 * time.Hour, 30 * 24 * time.Hour},
+	"week": {7 * 24 * time.Hour, 12 * 7 * 24 * time.Hour},
+}
+
+// maxSeriesBuckets 单次查询允许的最大时间桶数
+const maxSeriesBuckets = 1000
+
+// LogSeries 时间序列统计，校验粒度、维度与时间范围
+func (s *LLMServiceImpl) LogSeries(ctx context.Context, query repository.LLMCallLogSeriesQuery) (*repository.LLMCallLogSeries, error) {
+	d, ok := seriesIntervalDurations[query.Interval]
+	if !ok {
+		return nil, errors.New("interval 参数错误，可选 hour/day/week")
+	}
+	switch query.SplitBy {
+	case "", "provider", "purpose", "model", "arm":
+	default:
+		return nil, errors.New("split_by 参数错误，可选 provider/purpose/model/arm")
+	}
+	if query.EndTime.IsZero() {
+		query.EndTime = time.Now()
+	}
+	if query.StartTime.IsZero() {
+		query.StartTime = query.EndTime.Add(-d.span)
+	}
+	if !query.StartTime.Before(query.EndTime) {
+		return nil, errors.New("起始时间必须早于结束时间")
+	}
+	if query.EndTime.Sub(query.StartTime)/d.bucket > maxSeriesBuckets {
+		return nil, errors.New("时间范围过大，请缩小范围或使用更大的时间粒度")
+	}
+	if query.TopN < 0 {
+		query.TopN = 0
+	}
+	if query.TopN > 100 {
+		query.TopN = 100
+	}
+	return s.logRepo.Series(ctx, query)
+}
+
+// GetPayload 查询调用日志对应的采样内容
+func (s *LLMServiceImpl) GetPayload(ctx context.Context, callLogID int64) (*model.LLMCallPayload, error) {
+	if s.capture == nil {
+		return nil, ErrPayloadNotFound
+	}
+	return s.capture.Get(ctx, callLogID)
+}
+
+// PurgeExpiredPayloads 清理过期的调用内容采样
+func (s *LLMServiceImpl) PurgeExpiredPayloads(ctx context.Context) (int64, error) {
+	if s.capture == nil {
+		return 0, nil
+	}
+	return s.capture.PurgeExpired(ctx)
+}
+
+// ======= 配额 =======
+
+// Usage 查询用户Token用量与剩余额度
+func (s *LLMServiceImpl) Usage(ctx context.Context, userID int64) (*LLMQuotaUsage, error) {
+	if userID == 0 {
+		return nil, errors.New("未授权")
+	}
+	if s.quota == nil {
+		return nil, errors.New("未启用配额")
+	}
+	return s.quota.Usage(ctx, userID)
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"manjing-ai-go/pkg/jsonschema"
+	"manjing-ai-go/pkg/llm"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SchemaValidationError 多次修正后模型输出仍不符合 json_schema
+type SchemaValidationError struct {
+	Attempts     int      // 总调用次数（含修正）
+	Errors       []string // 最后一次输出的校验错误
+	Content      string   // 最后一次输出
+	FinishReason string   // 最后一次输出的结束原因
+}
+
+func (e *SchemaValidationError) Error() string {
+	errs := e.Errors
+	if len(errs) > 3 {
+		errs = errs[:3]
+	}
+	return fmt.Sprintf("模型输出不符合JSON Schema（共尝试%d次）: %s", e.Attempts, strings.Join(errs, "; "))
+}
+
+// maxRepairErrors 修正提示中最多列出的错误数
+const maxRepairErrors = 20
+
+// chatStructured 校验输出是否符合 schema，不符合时把错误反馈给模型重新生成，最多 structured.max_repairs 次。
+// 只有校验通过的结果才写入响应缓存，并以原始请求为键，后续相同请求直接命中合格结果。
+func (s *LLMServiceImpl) chatStructured(ctx context.Context, userID int64, req LLMChatRequest, targets []*llmTarget, schema *jsonschema.Schema) (*LLMChatResponse, error) {
+	original := req
+	var usage LLMUsage
+	durationMs := 0
+	for attempt := 0; ; attempt++ {
+		target, result, cached, err := s.chatOnce(ctx, userID, req, targets)
+		if err != nil {
+			return nil, err
+		}
+		usage.PromptTokens += result.PromptTokens
+		usage.CompletionTokens += result.CompletionTokens
+		usage.TotalTokens += result.TotalTokens
+		durationMs += result.DurationMs
+
+		content := trimJSONFence(result.Content)
+		errs := validateStructured(schema, content, result.FinishReason)
+		if len(errs) == 0 {
+			result.Content = content
+			if !cached || attempt > 0 {
+				s.cache.Store(ctx, original, target, result)
+			}
+			resp := buildChatResponse(req, target, result)
+			resp.Usage = usage
+			resp.DurationMs = durationMs
+			resp.Cached = cached && attempt == 0
+			resp.RepairCount = attempt
+			return resp, nil
+		}
+		if attempt >= s.cfg.Structured.MaxRepairs {
+			return nil, &SchemaValidationError{
+				Attempts:     attempt + 1,
+				Errors:       errs,
+				Content:      result.Content,
+				FinishReason: result.FinishReason,
+			}
+		}
+		log.Warnf("模型输出不符合JSON Schema，要求修正 purpose=%s model=%s attempt=%d: %s", req.Purpose, target.modelName, attempt+1, strings.Join(errs, "; "))
+
+		messages := make([]llm.ChatMessage, 0, len(req.Messages)+2)
+		messages = append(messages, req.Messages...)
+		messages = append(messages,
+			llm.ChatMessage{Role: "assistant", Content: result.Content},
+			llm.ChatMessage{Role: "user", Content: repairPrompt(errs)},
+		)
+		req.Messages = messages
+	}
+}
+
+// validateStructured 校验输出；因 max_tokens 截断的输出即使能解析也视为不合格
+func validateStructured(schema *jsonschema.Schema, content, finishReason string) []string {
+	var errs []string
+	if finishReason == "length" {
+		errs = append(errs, "输出因达到 max_tokens 被截断（finish_reason=length），请精简内容后输出完整 JSON")
+	}
+	return append(errs, schema.ValidateJSON([]byte(content))...)
+}
+
+// withSchemaInstruction 在系统提示词中附加 schema 要求，没有系统消息时新增一条
+func withSchemaInstruction(messages []llm.ChatMessage, schema json.RawMessage) []llm.ChatMessage {
+	instruction := "只输出一个符合以下 JSON Schema 的 JSON 对象，不要输出任何其他内容：\n" + string(schema)
+	out := make([]llm.ChatMessage, 0, len(messages)+1)
+	if len(messages) > 0 && messages[0].Role == "system" {
+		first := messages[0]
+		first.Content += "\n\n" + instruction
+		out = append(out, first)
+		return append(out, messages[1:]...)
+	}
+	out = append(out, llm.ChatMessage{Role: "system", Content: instruction})
+	return append(out, messages...)
+}
+
+func repairPrompt(errs []string) string {
+	if len(errs) > maxRepairErrors {
+		errs = append(errs[:maxRepairErrors:maxRepairErrors], fmt.Sprintf("……另有 %d 处错误", len(errs)-maxRepairErrors))
+	}
+	return "上面的输出不符合 JSON Schema，问题如下：\n- " + strings.Join(errs, "\n- ") + "\n请修正后重新输出完整的 JSON，不要输出其他内容。"
+}
+
+// trimJSONFence 去掉模型偶尔包裹的 ```json 代码块
+func trimJSONFence(s string) string {
+	t := strings.TrimSpace(s)
+	if !strings.HasPrefix(t, "```") {
+		return s
+	}
+	t = strings.TrimPrefix(t, "```json")
+	t = strings.TrimPrefix(t, "```")
+	t = strings.TrimSuffix(t, "```")
+	return strings.TrimSpace(t)
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"manjing-ai-go/pkg/jsonschema"
+	"manjing-ai-go/pkg/llm"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// LLMToolHandler 工具实现；args 已按 Parameters 校验，返回值序列化为 JSON 后交给模型
+type LLMToolHandler func(ctx context.Context, userID int64, args json.RawMessage) (interface{}, error)
+
+// LLMTool 可由模型调用的服务端工具
+type LLMTool struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage // 参数的 JSON Schema
+	Handler     LLMToolHandler
+
+	schema *jsonschema.Schema
+}
+
+// LLMToolRegistry 服务端工具注册表，启动时注册，运行期只读
+type LLMToolRegistry struct {
+	tools map[string]*LLMTool
+}
+
+// NewLLMToolRegistry 创建工具注册表
+func NewLLMToolRegistry() *LLMToolRegistry {
+	return &LLMToolRegistry{tools: map[string]*LLMTool{}}
+}
+
+// Register 注册工具，名称重复或参数 schema 不合法时 panic
+func (r *LLMToolRegistry) Register(t LLMTool) {
+	if _, exists := r.tools[t.Name]; exists {
+		panic("LLM工具重复注册: " + t.Name)
+	}
+	schema, err := jsonschema.Compile(t.Parameters)
+	if err != nil {
+		panic(fmt.Sprintf("LLM工具参数定义错误 %s: %v", t.Name, err))
+	}
+	t.schema = schema
+	r.tools[t.Name] = &t
+}
+
+// List 按名称排序返回全部工具
+func (r *LLMToolRegistry) List() []*LLMTool {
+	if r == nil {
+		return nil
+	}
+	list := make([]*LLMTool, 0, len(r.tools))
+	for _, t := range r.tools {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Definitions 返回指定工具的定义，存在未注册的工具时报错
+func (r *LLMToolRegistry) Definitions(names []string) ([]llm.Tool, error) {
+	defs := make([]llm.Tool, 0, len(names))
+	for _, name := range names {
+		var t *LLMTool
+		if r != nil {
+			t = r.tools[name]
+		}
+		if t == nil {
+			return nil, errors.New("未知工具: " + name)
+		}
+		defs = append(defs, llm.NewFunctionTool(t.Name, t.Description, t.Parameters))
+	}
+	return defs, nil
+}
+
+// Call 执行一次工具调用，错误以 {"error": "..."} 返回给模型，由模型决定如何继续
+func (r *LLMToolRegistry) Call(ctx context.Context, userID int64, call llm.ToolCall) (string, error) {
+	t := r.tools[call.Function.Name]
+	if t == nil {
+		return toolError(errors.New("未知工具: " + call.Function.Name))
+	}
+	args := json.RawMessage(call.Function.Arguments)
+	if strings.TrimSpace(call.Function.Arguments) == "" {
+		args = json.RawMessage("{}")
+	}
+	if errs := t.schema.ValidateJSON(args); len(errs) > 0 {
+		return toolError(errors.New("参数错误: " + strings.Join(errs, "; ")))
+	}
+	out, err := t.Handler(ctx, userID, args)
+	if err != nil {
+		return toolError(err)
+	}
+	raw, err := json.Marshal(out)
+	if err != nil {
+		return toolError(err)
+	}
+	return string(raw), nil
+}
+
+func toolError(err error) (string, error) {
+	raw, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(raw), err
+}
+
+// LLMToolCallTrace 一次工具调用的记录，随响应返回
+type LLMToolCallTrace struct {
+	Round     int    `json:"round"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Error     string `json:"error,omitempty"`
+}
+
+// chatWithTools 服务端工具循环：模型发起工具调用时执行并回填结果，直到模型给出最终回复。
+// 达到 tools.max_rounds 后的最后一轮禁止再调用工具，强制模型作答。
+func (s *LLMServiceImpl) chatWithTools(ctx context.Context, userID int64, req LLMChatRequest, targets []*llmTarget) (*LLMChatResponse, error) {
+	var usage LLMUsage
+	var traces []LLMToolCallTrace
+	durationMs := 0
+	for round := 1; ; round++ {
+		if round > s.cfg.Tools.MaxRounds {
+			req.toolChoice = "none"
+		}
+		target, result, err := s.callWithFallback(ctx, userID, req, targets, func(messages []llm.ChatMessage, opts []llm.ChatOption) (*llm.ChatResult, error) {
+			return s.client.ChatCompletion(ctx, messages, opts...)
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+		usage.PromptTokens += result.PromptTokens
+		usage.CompletionTokens += result.CompletionTokens
+		usage.TotalTokens += result.TotalTokens
+		durationMs += result.DurationMs
+
+		if len(result.ToolCalls) == 0 || req.toolChoice == "none" {
+			resp := buildChatResponse(req, target, result)
+			resp.Usage = usage
+			resp.DurationMs = durationMs
+			resp.ToolCalls = traces
+			return resp, nil
+		}
+
+		messages := make([]llm.ChatMessage, 0, len(req.Messages)+1+len(result.ToolCalls))
+		messages = append(messages, req.Messages...)
+		messages = append(messages, llm.ChatMessage{Role: "assistant", Content: result.Content, ToolCalls: result.ToolCalls})
+		for _, call := range result.ToolCalls {
+			out, callErr := s.tools.Call(ctx, userID, call)
+			trace := LLMToolCallTrace{Round: round, Name: call.Function.Name, Arguments: call.Function.Arguments}
+			if callErr != nil {
+				trace.Error = callErr.Error()
+				log.Warnf("LLM工具调用失败 tool=%s user_id=%d: %v", call.Function.Name, userID, callErr)
+			}
+			traces = append(traces, trace)
+			messages = append(messages, llm.ChatMessage{Role: "tool", ToolCallID: call.ID, Content: out})
+		}
+		req.Messages = messages
+	}
+}
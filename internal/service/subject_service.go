@@ -0,0 +1,589 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"manjing-ai-go/config"
+	"manjing-ai-go/internal/model"
+	"manjing-ai-go/internal/repository"
+	"manjing-ai-go/pkg/llm"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// SubjectExtractPurpose 主体提取使用的模型用途
+const SubjectExtractPurpose = "subject_extract"
+
+// SubjectService 主体（角色）库服务
+type SubjectService interface {
+	Create(ctx context.Context, userID, projectID int64, req SubjectCreate) (*model.Subject, error)
+	List(ctx context.Context, userID, projectID int64, query repository.SubjectListQuery) ([]model.Subject, int64, error)
+	Get(ctx context.Context, userID, id int64) (*model.Subject, error)
+	Update(ctx context.Context, userID, id int64, req SubjectUpdate) (*model.Subject, error)
+	Delete(ctx context.Context, userID, id int64) error
+	Merge(ctx context.Context, userID, projectID, targetID int64, sourceIDs []int64) (*model.Subject, error)
+	Extract(ctx context.Context, userID, projectID int64) (*SubjectExtractResult, error)
+}
+
+// SubjectCreate 创建请求
+type SubjectCreate struct {
+	Name             string
+	Aliases          []string
+	Description      string
+	AppearancePrompt string
+	ResourceID       *int64
+	VoiceID          *int64
+}
+
+// SubjectUpdate 更新请求；ResourceID/VoiceID 传 0 表示解绑
+type SubjectUpdate struct {
+	Name             *string
+	Aliases          *[]string
+	Description      *string
+	AppearancePrompt *string
+	ResourceID       *int64
+	VoiceID          *int64
+}
+
+// SubjectExtractResult 提取结果
+type SubjectExtractResult struct {
+	Chapters int             `json:"chapters"` // 处理的章节数
+	Created  int             `json:"created"`  // 新增主体数
+	Updated  int             `json:"updated"`  // 补充信息的已有主体数
+	Subjects []model.Subject `json:"subjects"` // 提取后的完整主体库
+}
+
+// SubjectServiceImpl 实现
+type SubjectServiceImpl struct {
+	repo         repository.SubjectRepository
+	chapterRepo  repository.ChapterRepository
+	resourceRepo repository.ResourceRepository
+	projectSvc   ProjectService
+	voiceSvc     VoiceService
+	llmSvc       LLMService
+	cfg          config.LLMSubjectExtractConfig
+}
+
+// NewSubjectService 创建服务
+func NewSubjectService(repo repository.SubjectRepository, chapterRepo repository.ChapterRepository, resourceRepo repository.ResourceRepository, projectSvc ProjectService, voiceSvc VoiceService, llmSvc LLMService, cfg config.LLMSubjectExtractConfig) *SubjectServiceImpl {
+	if cfg.ChunkChars <= 0 {
+		cfg.ChunkChars = 6000
+	}
+	return &SubjectServiceImpl{
+		repo:         repo,
+		chapterRepo:  chapterRepo,
+		resourceRepo: resourceRepo,
+		projectSvc:   projectSvc,
+		voiceSvc:     voiceSvc,
+		llmSvc:       llmSvc,
+		cfg:          cfg,
+	}
+}
+
+func (s *SubjectServiceImpl) Create(ctx context.Context, userID, projectID int64, req SubjectCreate) (*model.Subject, error) {
+	if _, err := s.projectSvc.Get(ctx, userID, projectID); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if err := validateSubjectName(name); err != nil {
+		return nil, err
+	}
+	aliases := cleanAliases(name, req.Aliases)
+	existing, err := s.repo.ListAllByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if subjectConflict(existing, 0, name, aliases) {
+		return nil, errors.New("主体名称已存在")
+	}
+	if err := s.checkBindings(ctx, userID, req.ResourceID, req.VoiceID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	subject := &model.Subject{
+		ProjectID:        projectID,
+		Name:             name,
+		Aliases:          datatypes.JSONSlice[string](aliases),
+		Description:      req.Description,
+		AppearancePrompt: req.AppearancePrompt,
+		ResourceID:       req.ResourceID,
+		VoiceID:          req.VoiceID,
+		Source:           model.SubjectSourceManual,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	if err := s.repo.Create(ctx, subject); err != nil {
+		return nil, err
+	}
+	return subject, nil
+}
+
+func (s *SubjectServiceImpl) List(ctx context.Context, userID, projectID int64, query repository.SubjectListQuery) ([]model.Subject, int64, error) {
+	if _, err := s.projectSvc.Get(ctx, userID, projectID); err != nil {
+		return nil, 0, err
+	}
+	return s.repo.List(ctx, projectID, query)
+}
+
+func (s *SubjectServiceImpl) Get(ctx context.Context, userID, id int64) (*model.Subject, error) {
+	if userID == 0 {
+		return nil, errors.New("未授权")
+	}
+	subject, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("主体不存在")
+		}
+		return nil, err
+	}
+	if _, err := s.projectSvc.Get(ctx, userID, subject.ProjectID); err != nil {
+		return nil, err
+	}
+	return subject, nil
+}
+
+func (s *SubjectServiceImpl) Update(ctx context.Context, userID, id int64, req SubjectUpdate) (*model.Subject, error) {
+	subject, err := s.Get(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	name := subject.Name
+	aliases := []string(subject.Aliases)
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+		if err := validateSubjectName(name); err != nil {
+			return nil, err
+		}
+		updates["name"] = name
+	}
+	if req.Aliases != nil {
+		aliases = *req.Aliases
+	}
+	if req.Name != nil || req.Aliases != nil {
+		aliases = cleanAliases(name, aliases)
+		existing, err := s.repo.ListAllByProject(ctx, subject.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		if subjectConflict(existing, subject.ID, name, aliases) {
+			return nil, errors.New("主体名称已存在")
+		}
+		updates["aliases"] = datatypes.JSONSlice[string](aliases)
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.AppearancePrompt != nil {
+		updates["appearance_prompt"] = *req.AppearancePrompt
+	}
+	resourceID, voiceID := nonZeroID(req.ResourceID), nonZeroID(req.VoiceID)
+	if err := s.checkBindings(ctx, userID, resourceID, voiceID); err != nil {
+		return nil, err
+	}
+	if req.ResourceID != nil {
+		updates["resource_id"] = resourceID
+	}
+	if req.VoiceID != nil {
+		updates["voice_id"] = voiceID
+	}
+	if len(updates) == 0 {
+		return subject, nil
+	}
+
+	updates["updated_at"] = time.Now()
+	if err := s.repo.Update(ctx, id, updates); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, id)
+}
+
+func (s *SubjectServiceImpl) Delete(ctx context.Context, userID, id int64) error {
+	if _, err := s.Get(ctx, userID, id); err != nil {
+		return err
+	}
+	now := time.Now()
+	return s.repo.Update(ctx, id, map[string]interface{}{
+		"deleted_at": &now,
+		"updated_at": now,
+	})
+}
+
+// Merge 将 sourceIDs 合并到 targetID：来源主体的名称与别名并入目标别名，目标缺失的字段用来源补齐，来源主体删除
+func (s *SubjectServiceImpl) Merge(ctx context.Context, userID, projectID, targetID int64, sourceIDs []int64) (*model.Subject, error) {
+	if _, err := s.projectSvc.Get(ctx, userID, projectID); err != nil {
+		return nil, err
+	}
+	if len(sourceIDs) == 0 {
+		return nil, errors.New("待合并主体不能为空")
+	}
+	target, err := s.repo.FindByID(ctx, targetID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("主体不存在")
+		}
+		return nil, err
+	}
+	if target.ProjectID != projectID {
+		return nil, errors.New("主体不属于该项目")
+	}
+
+	sources := make([]*model.Subject, 0, len(sourceIDs))
+	for _, id := range sourceIDs {
+		if id == targetID {
+			continue
+		}
+		src, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errors.New("主体不存在")
+			}
+			return nil, err
+		}
+		if src.ProjectID != projectID {
+			return nil, errors.New("主体不属于该项目")
+		}
+		sources = append(sources, src)
+	}
+	if len(sources) == 0 {
+		return target, nil
+	}
+
+	for _, src := range sources {
+		mergeSubjectInto(target, src.Name, src.Aliases, src.Description, src.AppearancePrompt)
+		if target.ResourceID == nil {
+			target.ResourceID = src.ResourceID
+		}
+		if target.VoiceID == nil {
+			target.VoiceID = src.VoiceID
+		}
+	}
+
+	now := time.Now()
+	for _, src := range sources {
+		if err := s.repo.Update(ctx, src.ID, map[string]interface{}{
+			"deleted_at": &now,
+			"updated_at": now,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.Update(ctx, target.ID, map[string]interface{}{
+		"aliases":           target.Aliases,
+		"description":       target.Description,
+		"appearance_prompt": target.AppearancePrompt,
+		"resource_id":       target.ResourceID,
+		"voice_id":          target.VoiceID,
+		"updated_at":        now,
+	}); err != nil {
+		return nil, err
+	}
+	return s.repo.FindByID(ctx, target.ID)
+}
+
+// Extract 使用 subject_extract 模型逐章提取主体并合并入主体库；按章节落库，中途失败时已处理章节的结果保留
+func (s *SubjectServiceImpl) Extract(ctx context.Context, userID, projectID int64) (*SubjectExtractResult, error) {
+	project, err := s.projectSvc.Get(ctx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	chapters, err := s.chapterRepo.ListAllByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.repo.ListAllByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	library := make([]*model.Subject, 0, len(existing))
+	for i := range existing {
+		library = append(library, &existing[i])
+	}
+	result := &SubjectExtractResult{}
+	updated := map[int64]bool{}
+
+	for _, chapter := range chapters {
+		if strings.TrimSpace(chapter.Content) == "" {
+			continue
+		}
+		dirty := map[*model.Subject]bool{}
+		chunks := splitChapterContent(chapter.Content, s.cfg.ChunkChars, utf8.RuneCountInString)
+		for i, chunk := range chunks {
+			extracted, err := s.extractChunk(ctx, userID, project.Name, chapter.Name, chunk, i, len(chunks), library)
+			if err != nil {
+				return nil, fmt.Errorf("章节「%s」提取失败: %w", chapter.Name, err)
+			}
+			for _, item := range extracted {
+				subject, changed := mergeExtractedSubject(&library, projectID, item)
+				if changed {
+					dirty[subject] = true
+				}
+			}
+		}
+
+		now := time.Now()
+		for subject := range dirty {
+			subject.UpdatedAt = now
+			if subject.ID == 0 {
+				subject.CreatedAt = now
+				if err := s.repo.Create(ctx, subject); err != nil {
+					return nil, err
+				}
+				result.Created++
+				continue
+			}
+			if err := s.repo.Update(ctx, subject.ID, map[string]interface{}{
+				"aliases":           subject.Aliases,
+				"description":       subject.Description,
+				"appearance_prompt": subject.AppearancePrompt,
+				"updated_at":        now,
+			}); err != nil {
+				return nil, err
+			}
+			updated[subject.ID] = true
+		}
+		result.Chapters++
+	}
+
+	result.Updated = len(updated)
+	result.Subjects, err = s.repo.ListAllByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// extractedSubject 模型输出的主体
+type extractedSubject struct {
+	Name             string   `json:"name"`
+	Aliases          []string `json:"aliases"`
+	Description      string   `json:"description"`
+	AppearancePrompt string   `json:"appearance_prompt"`
+}
+
+// extractChunk 提取单段文本中的主体；未配置 subject_extract 提示词模板时使用内置提示词
+func (s *SubjectServiceImpl) extractChunk(ctx context.Context, userID int64, projectName, chapterName, chunk string, index, total int, library []*model.Subject) ([]extractedSubject, error) {
+	known := make([]string, 0, len(library))
+	for _, subject := range library {
+		known = append(known, subject.Name)
+	}
+	req := LLMChatRequest{
+		Purpose:        SubjectExtractPurpose,
+		ResponseFormat: "json",
+		Variables: map[string]interface{}{
+			"project_name":   projectName,
+			"chapter_name":   chapterName,
+			"content":        chunk,
+			"chunk_index":    index + 1,
+			"chunk_total":    total,
+			"known_subjects": strings.Join(known, "、"),
+		},
+	}
+	resp, err := s.llmSvc.Chat(ctx, userID, req)
+	if errors.Is(err, ErrPromptNotFound) {
+		req.Messages = builtinSubjectExtractMessages(chapterName, chunk, known)
+		resp, err = s.llmSvc.Chat(ctx, userID, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content := strings.TrimSpace(resp.Content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+	var out struct {
+		Subjects []extractedSubject `json:"subjects"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(content)), &out); err != nil {
+		return nil, fmt.Errorf("模型输出不是合法JSON: %v", err)
+	}
+	return out.Subjects, nil
+}
+
+// builtinSubjectExtractMessages 内置主体提取提示词
+func builtinSubjectExtractMessages(chapterName, chunk string, known []string) []llm.ChatMessage {
+	system := `你是小说改编漫剧的角色整理助手。请找出用户给出的小说片段中出现的人物角色。
+只输出 JSON，格式如下：
+{"subjects":[{"name":"角色标准名","aliases":["别名","称呼"],"description":"身份、性格、与他人关系","appearance_prompt":"外貌、服饰、年龄等可用于生成形象的描述"}]}
+要求：
+1. 同一人物只输出一次，name 使用最常用的全名，其他称呼放入 aliases；
+2. 已知角色请沿用已知名称；
+3. 原文未提及的信息留空字符串，不要编造。`
+	user := fmt.Sprintf("章节：%s\n已知角色：%s\n\n%s", chapterName, strings.Join(known, "、"), chunk)
+	return []llm.ChatMessage{
+		{Role: "system", Content: system},
+		{Role: "user", Content: user},
+	}
+}
+
+// mergeExtractedSubject 按名称或别名匹配已有主体并合并，匹配不到时新增；返回受影响的主体及是否有变化
+func mergeExtractedSubject(library *[]*model.Subject, projectID int64, item extractedSubject) (*model.Subject, bool) {
+	name := strings.TrimSpace(item.Name)
+	if name == "" || utf8.RuneCountInString(name) > 64 {
+		return nil, false
+	}
+	var matched *model.Subject
+	for _, subject := range *library {
+		if subjectMatches(subject, []string{name}) {
+			matched = subject
+			break
+		}
+	}
+	if matched == nil {
+		for _, subject := range *library {
+			if subjectMatches(subject, item.Aliases) {
+				matched = subject
+				break
+			}
+		}
+	}
+
+	// 丢弃已属于其他主体的别名，避免一个称呼指向多个主体
+	aliases := make([]string, 0, len(item.Aliases))
+	for _, alias := range item.Aliases {
+		owned := false
+		for _, subject := range *library {
+			if subject != matched && subjectMatches(subject, []string{alias}) {
+				owned = true
+				break
+			}
+		}
+		if !owned {
+			aliases = append(aliases, alias)
+		}
+	}
+
+	if matched != nil {
+		return matched, mergeSubjectInto(matched, name, aliases, item.Description, item.AppearancePrompt)
+	}
+	subject := &model.Subject{
+		ProjectID:        projectID,
+		Name:             name,
+		Aliases:          datatypes.JSONSlice[string](cleanAliases(name, aliases)),
+		Description:      strings.TrimSpace(item.Description),
+		AppearancePrompt: strings.TrimSpace(item.AppearancePrompt),
+		Source:           model.SubjectSourceExtract,
+	}
+	*library = append(*library, subject)
+	return subject, true
+}
+
+// mergeSubjectInto 将名称、别名并入 target 的别名，并补齐 target 为空的描述字段
+func mergeSubjectInto(target *model.Subject, name string, aliases []string, description, appearance string) bool {
+	changed := false
+	merged := cleanAliases(target.Name, append(append([]string(target.Aliases), name), aliases...))
+	if len(merged) != len(target.Aliases) {
+		target.Aliases = datatypes.JSONSlice[string](merged)
+		changed = true
+	}
+	if target.Description == "" && strings.TrimSpace(description) != "" {
+		target.Description = strings.TrimSpace(description)
+		changed = true
+	}
+	if target.AppearancePrompt == "" && strings.TrimSpace(appearance) != "" {
+		target.AppearancePrompt = strings.TrimSpace(appearance)
+		changed = true
+	}
+	return changed
+}
+
+// subjectMatches 任一 key 与主体名称或别名相同（忽略大小写与首尾空白）
+func subjectMatches(subject *model.Subject, keys []string) bool {
+	names := map[string]bool{normalizeSubjectName(subject.Name): true}
+	for _, alias := range subject.Aliases {
+		names[normalizeSubjectName(alias)] = true
+	}
+	for _, key := range keys {
+		if k := normalizeSubjectName(key); k != "" && names[k] {
+			return true
+		}
+	}
+	return false
+}
+
+// subjectConflict 名称或别名与项目内其他主体重复
+func subjectConflict(existing []model.Subject, selfID int64, name string, aliases []string) bool {
+	keys := append([]string{name}, aliases...)
+	for i := range existing {
+		if existing[i].ID == selfID {
+			continue
+		}
+		if subjectMatches(&existing[i], keys) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanAliases 去除空白、重复以及与名称相同的别名
+func cleanAliases(name string, aliases []string) []string {
+	seen := map[string]bool{normalizeSubjectName(name): true}
+	out := make([]string, 0, len(aliases))
+	for _, alias := range aliases {
+		alias = strings.TrimSpace(alias)
+		key := normalizeSubjectName(alias)
+		if key == "" || seen[key] || utf8.RuneCountInString(alias) > 64 {
+			continue
+		}
+		seen[key] = true
+		out = append(out, alias)
+	}
+	return out
+}
+
+func normalizeSubjectName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func validateSubjectName(name string) error {
+	if name == "" {
+		return errors.New("主体名称不能为空")
+	}
+	if utf8.RuneCountInString(name) > 64 {
+		return errors.New("主体名称过长")
+	}
+	return nil
+}
+
+// checkBindings 校验参考图与声音的归属
+func (s *SubjectServiceImpl) checkBindings(ctx context.Context, userID int64, resourceID, voiceID *int64) error {
+	if resourceID != nil {
+		res, err := s.resourceRepo.FindByID(ctx, *resourceID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("参考图资源不存在")
+			}
+			return err
+		}
+		if res.DeletedAt != nil {
+			return errors.New("参考图资源不存在")
+		}
+		if res.UserID != userID {
+			return errors.New("无权访问")
+		}
+	}
+	if voiceID != nil {
+		if _, err := s.voiceSvc.Get(ctx, userID, *voiceID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func nonZeroID(id *int64) *int64 {
+	if id == nil || *id == 0 {
+		return nil
+	}
+	return id
+}
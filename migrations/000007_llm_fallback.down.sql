@@ -0,0 +1,6 @@
+ALTER TABLE llm_call_logs DROP COLUMN IF EXISTS attempt;
+
+DROP INDEX IF EXISTS idx_llm_models_purpose_priority;
+CREATE INDEX idx_llm_models_purpose ON llm_models(purpose) WHERE deleted_at IS NULL;
+
+ALTER TABLE llm_models DROP COLUMN IF EXISTS priority;
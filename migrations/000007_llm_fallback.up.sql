@@ -0,0 +1,8 @@
+ALTER TABLE llm_models ADD COLUMN priority INT NOT NULL DEFAULT 0;
+COMMENT ON COLUMN llm_models.priority IS '同用途下的降级顺序，数值越小越优先';
+
+DROP INDEX IF EXISTS idx_llm_models_purpose;
+CREATE INDEX idx_llm_models_purpose_priority ON llm_models(purpose, priority) WHERE deleted_at IS NULL;
+
+ALTER TABLE llm_call_logs ADD COLUMN attempt SMALLINT NOT NULL DEFAULT 1;
+COMMENT ON COLUMN llm_call_logs.attempt IS '本次请求中的第几次尝试(降级链序号，从1开始)';
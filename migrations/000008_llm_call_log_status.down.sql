@@ -0,0 +1,2 @@
+UPDATE llm_call_logs SET status = 2 WHERE status > 3;
+COMMENT ON COLUMN llm_call_logs.status IS '状态: 1成功/2失败/3超时';
@@ -0,0 +1 @@
+COMMENT ON COLUMN llm_call_logs.status IS '状态: 1成功/2失败/3超时/4限流/5鉴权失败/6请求错误/7上游错误/8响应异常/9上下文超长';
@@ -0,0 +1,4 @@
+ALTER TABLE llm_models DROP COLUMN IF EXISTS api_key_mask;
+-- 回滚前需先用 cmd/llmkey decrypt 还原明文，否则密文超出长度将导致失败
+ALTER TABLE llm_models ALTER COLUMN api_key TYPE VARCHAR(256);
+COMMENT ON COLUMN llm_models.api_key IS 'API密钥(加密存储)';
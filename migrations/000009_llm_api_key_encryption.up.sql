@@ -0,0 +1,11 @@
+ALTER TABLE llm_models ALTER COLUMN api_key TYPE VARCHAR(1024);
+ALTER TABLE llm_models ADD COLUMN api_key_mask VARCHAR(32) NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN llm_models.api_key IS 'API密钥密文(AES-GCM信封加密: enc:v1:<主密钥ID>:<数据密钥密文>:<密钥密文>)';
+COMMENT ON COLUMN llm_models.api_key_mask IS '脱敏后的API密钥，用于展示';
+
+-- 存量明文密钥先生成脱敏展示值，密文由 go run ./cmd/llmkey encrypt 写入
+UPDATE llm_models
+SET api_key_mask = CASE WHEN length(api_key) <= 6 THEN 'sk-***'
+                        ELSE left(api_key, 3) || '***' || right(api_key, 3) END
+WHERE api_key NOT LIKE 'enc:%';
@@ -0,0 +1,3 @@
+ALTER TABLE llm_call_logs DROP COLUMN IF EXISTS prompt_version;
+ALTER TABLE llm_call_logs DROP COLUMN IF EXISTS prompt_id;
+DROP TABLE IF EXISTS llm_prompts;
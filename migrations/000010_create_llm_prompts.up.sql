@@ -0,0 +1,30 @@
+-- 提示词模板表
+CREATE TABLE llm_prompts (
+  id BIGSERIAL PRIMARY KEY,
+  purpose VARCHAR(32) NOT NULL,
+  version INT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  description VARCHAR(256) NOT NULL DEFAULT '',
+  messages JSONB NOT NULL,
+  is_active BOOLEAN NOT NULL DEFAULT true,
+  created_by BIGINT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  deleted_at TIMESTAMPTZ NULL
+);
+
+COMMENT ON TABLE llm_prompts IS 'LLM提示词模板表';
+COMMENT ON COLUMN llm_prompts.purpose IS '用途: default/chapter_parse/subject_extract';
+COMMENT ON COLUMN llm_prompts.version IS '同用途内递增的版本号';
+COMMENT ON COLUMN llm_prompts.name IS '模板名称';
+COMMENT ON COLUMN llm_prompts.description IS '模板说明';
+COMMENT ON COLUMN llm_prompts.messages IS '消息模板(JSONB): [{"role":"system","content":"Go text/template"}]';
+COMMENT ON COLUMN llm_prompts.is_active IS '是否启用';
+COMMENT ON COLUMN llm_prompts.created_by IS '创建人用户ID';
+
+CREATE UNIQUE INDEX idx_llm_prompts_purpose_version ON llm_prompts(purpose, version);
+
+ALTER TABLE llm_call_logs ADD COLUMN prompt_id BIGINT NULL;
+ALTER TABLE llm_call_logs ADD COLUMN prompt_version INT NULL;
+COMMENT ON COLUMN llm_call_logs.prompt_id IS '使用的提示词模板ID';
+COMMENT ON COLUMN llm_call_logs.prompt_version IS '使用的提示词模板版本';
@@ -0,0 +1,30 @@
+-- 章节解析任务与结果表
+CREATE TABLE chapter_parses (
+  id BIGSERIAL PRIMARY KEY,
+  chapter_id BIGINT NOT NULL,
+  project_id BIGINT NOT NULL,
+  user_id BIGINT NOT NULL,
+  status SMALLINT NOT NULL DEFAULT 1,
+  total_chunks INT NOT NULL DEFAULT 0,
+  done_chunks INT NOT NULL DEFAULT 0,
+  content_hash VARCHAR(64) NOT NULL,
+  result JSONB NULL,
+  error_message TEXT NULL,
+  started_at TIMESTAMPTZ NULL,
+  finished_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE chapter_parses IS '章节解析任务表';
+COMMENT ON COLUMN chapter_parses.chapter_id IS '章节ID';
+COMMENT ON COLUMN chapter_parses.project_id IS '项目ID';
+COMMENT ON COLUMN chapter_parses.user_id IS '发起用户ID';
+COMMENT ON COLUMN chapter_parses.status IS '状态: 1排队/2解析中/3成功/4失败';
+COMMENT ON COLUMN chapter_parses.total_chunks IS '分段总数';
+COMMENT ON COLUMN chapter_parses.done_chunks IS '已完成分段数';
+COMMENT ON COLUMN chapter_parses.content_hash IS '解析时章节内容的SHA-256，用于判断结果是否过期';
+COMMENT ON COLUMN chapter_parses.result IS '解析结果(JSONB): 场景、对白、旁白、说话人';
+COMMENT ON COLUMN chapter_parses.error_message IS '错误信息';
+
+CREATE INDEX idx_chapter_parses_chapter_id ON chapter_parses(chapter_id, created_at DESC);
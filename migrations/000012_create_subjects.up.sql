@@ -0,0 +1,28 @@
+-- 项目主体（角色）库
+CREATE TABLE IF NOT EXISTS subjects (
+  id BIGSERIAL PRIMARY KEY,
+  project_id BIGINT NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  aliases JSONB NOT NULL DEFAULT '[]',
+  description TEXT,
+  appearance_prompt TEXT,
+  resource_id BIGINT,
+  voice_id BIGINT,
+  source SMALLINT NOT NULL DEFAULT 1,
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+  deleted_at TIMESTAMPTZ
+);
+
+COMMENT ON TABLE subjects IS '项目主体（角色）库';
+COMMENT ON COLUMN subjects.project_id IS '项目ID';
+COMMENT ON COLUMN subjects.name IS '主体名称，项目内唯一';
+COMMENT ON COLUMN subjects.aliases IS '别名列表(JSON数组)';
+COMMENT ON COLUMN subjects.description IS '人物描述';
+COMMENT ON COLUMN subjects.appearance_prompt IS '外观提示词，用于生图';
+COMMENT ON COLUMN subjects.resource_id IS '参考图资源ID';
+COMMENT ON COLUMN subjects.voice_id IS '绑定的声音ID';
+COMMENT ON COLUMN subjects.source IS '来源: 1手动创建/2模型提取';
+
+CREATE UNIQUE INDEX IF NOT EXISTS uk_subjects_project_name
+  ON subjects (project_id, LOWER(name)) WHERE deleted_at IS NULL;
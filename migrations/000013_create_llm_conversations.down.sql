@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS llm_messages;
+DROP TABLE IF EXISTS llm_conversations;
@@ -0,0 +1,49 @@
+-- 多轮对话会话表
+CREATE TABLE llm_conversations (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  title VARCHAR(128) NOT NULL DEFAULT '',
+  purpose VARCHAR(32) NOT NULL DEFAULT 'default',
+  model_id BIGINT NULL,
+  project_id BIGINT NULL,
+  chapter_id BIGINT NULL,
+  system_prompt TEXT NOT NULL DEFAULT '',
+  message_count INT NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  deleted_at TIMESTAMPTZ NULL
+);
+
+COMMENT ON TABLE llm_conversations IS 'LLM多轮对话会话表';
+COMMENT ON COLUMN llm_conversations.user_id IS '所属用户ID';
+COMMENT ON COLUMN llm_conversations.title IS '会话标题';
+COMMENT ON COLUMN llm_conversations.purpose IS '调用用途，决定使用的模型链';
+COMMENT ON COLUMN llm_conversations.model_id IS '固定使用的模型配置ID，为空时按用途选择';
+COMMENT ON COLUMN llm_conversations.project_id IS '关联项目ID';
+COMMENT ON COLUMN llm_conversations.chapter_id IS '关联章节ID';
+COMMENT ON COLUMN llm_conversations.system_prompt IS '系统提示词，每轮调用时置于历史之前';
+COMMENT ON COLUMN llm_conversations.message_count IS '消息数';
+
+CREATE INDEX idx_llm_conversations_user ON llm_conversations(user_id, updated_at DESC);
+
+-- 会话消息表
+CREATE TABLE llm_messages (
+  id BIGSERIAL PRIMARY KEY,
+  conversation_id BIGINT NOT NULL,
+  role VARCHAR(16) NOT NULL,
+  content TEXT NOT NULL,
+  prompt_tokens INT NOT NULL DEFAULT 0,
+  completion_tokens INT NOT NULL DEFAULT 0,
+  model VARCHAR(64) NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE llm_messages IS 'LLM会话消息表';
+COMMENT ON COLUMN llm_messages.conversation_id IS '会话ID';
+COMMENT ON COLUMN llm_messages.role IS '角色: user/assistant';
+COMMENT ON COLUMN llm_messages.content IS '消息内容';
+COMMENT ON COLUMN llm_messages.prompt_tokens IS '助手回复时本轮的输入Token数';
+COMMENT ON COLUMN llm_messages.completion_tokens IS '助手回复的输出Token数';
+COMMENT ON COLUMN llm_messages.model IS '生成回复的模型';
+
+CREATE INDEX idx_llm_messages_conversation ON llm_messages(conversation_id, id);
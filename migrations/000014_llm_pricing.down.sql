@@ -0,0 +1,8 @@
+ALTER TABLE llm_call_logs DROP COLUMN IF EXISTS currency;
+ALTER TABLE llm_call_logs DROP COLUMN IF EXISTS cost;
+ALTER TABLE llm_call_logs DROP COLUMN IF EXISTS cached_tokens;
+
+ALTER TABLE llm_models DROP COLUMN IF EXISTS currency;
+ALTER TABLE llm_models DROP COLUMN IF EXISTS cached_input_price;
+ALTER TABLE llm_models DROP COLUMN IF EXISTS output_price;
+ALTER TABLE llm_models DROP COLUMN IF EXISTS input_price;
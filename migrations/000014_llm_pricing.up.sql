@@ -0,0 +1,18 @@
+-- 模型单价与调用费用
+ALTER TABLE llm_models ADD COLUMN input_price NUMERIC(12,6) NOT NULL DEFAULT 0;
+ALTER TABLE llm_models ADD COLUMN output_price NUMERIC(12,6) NOT NULL DEFAULT 0;
+ALTER TABLE llm_models ADD COLUMN cached_input_price NUMERIC(12,6) NOT NULL DEFAULT 0;
+ALTER TABLE llm_models ADD COLUMN currency VARCHAR(8) NOT NULL DEFAULT 'CNY';
+
+COMMENT ON COLUMN llm_models.input_price IS '输入单价(每1K Token)';
+COMMENT ON COLUMN llm_models.output_price IS '输出单价(每1K Token)';
+COMMENT ON COLUMN llm_models.cached_input_price IS '缓存命中输入单价(每1K Token)，为0时按输入单价计';
+COMMENT ON COLUMN llm_models.currency IS '币种: CNY/USD';
+
+ALTER TABLE llm_call_logs ADD COLUMN cached_tokens INT NOT NULL DEFAULT 0;
+ALTER TABLE llm_call_logs ADD COLUMN cost NUMERIC(14,6) NOT NULL DEFAULT 0;
+ALTER TABLE llm_call_logs ADD COLUMN currency VARCHAR(8) NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN llm_call_logs.cached_tokens IS '命中缓存的输入Token数';
+COMMENT ON COLUMN llm_call_logs.cost IS '调用费用，按调用时的模型单价计算';
+COMMENT ON COLUMN llm_call_logs.currency IS '费用币种';
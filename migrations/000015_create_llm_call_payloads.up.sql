@@ -0,0 +1,20 @@
+-- LLM 调用请求/响应内容采样表
+CREATE TABLE llm_call_payloads (
+  id BIGSERIAL PRIMARY KEY,
+  call_log_id BIGINT NOT NULL,
+  messages JSONB NOT NULL,
+  response TEXT NOT NULL DEFAULT '',
+  redacted BOOLEAN NOT NULL DEFAULT false,
+  expires_at TIMESTAMPTZ NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE llm_call_payloads IS 'LLM调用内容采样表，按配置抽样记录';
+COMMENT ON COLUMN llm_call_payloads.call_log_id IS '调用日志ID';
+COMMENT ON COLUMN llm_call_payloads.messages IS '发送的消息(JSONB)';
+COMMENT ON COLUMN llm_call_payloads.response IS '模型返回内容（流式中断时为已接收部分）';
+COMMENT ON COLUMN llm_call_payloads.redacted IS '是否已脱敏';
+COMMENT ON COLUMN llm_call_payloads.expires_at IS '过期时间，过期后由清理任务删除';
+
+CREATE UNIQUE INDEX idx_llm_call_payloads_call_log_id ON llm_call_payloads(call_log_id);
+CREATE INDEX idx_llm_call_payloads_expires_at ON llm_call_payloads(expires_at);
@@ -0,0 +1,5 @@
+ALTER TABLE llm_models
+  DROP COLUMN IF EXISTS last_check_error,
+  DROP COLUMN IF EXISTS last_checked_at,
+  DROP COLUMN IF EXISTS consecutive_failures,
+  DROP COLUMN IF EXISTS health_status;
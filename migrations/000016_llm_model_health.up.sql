@@ -0,0 +1,10 @@
+ALTER TABLE llm_models
+  ADD COLUMN health_status SMALLINT NOT NULL DEFAULT 0,
+  ADD COLUMN consecutive_failures INT NOT NULL DEFAULT 0,
+  ADD COLUMN last_checked_at TIMESTAMPTZ,
+  ADD COLUMN last_check_error VARCHAR(512) NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN llm_models.health_status IS '健康状态: 0未检测 1健康 2不健康，不健康的模型不参与按用途选择';
+COMMENT ON COLUMN llm_models.consecutive_failures IS '连续探测失败次数';
+COMMENT ON COLUMN llm_models.last_checked_at IS '最近探测时间';
+COMMENT ON COLUMN llm_models.last_check_error IS '最近一次探测失败原因';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_llm_call_logs_arm_model_id;
+ALTER TABLE llm_call_logs DROP COLUMN IF EXISTS arm_model_id;
+ALTER TABLE llm_models DROP COLUMN IF EXISTS weight;
@@ -0,0 +1,6 @@
+ALTER TABLE llm_models ADD COLUMN weight INT NOT NULL DEFAULT 100;
+COMMENT ON COLUMN llm_models.weight IS '流量权重：同用途同优先级的模型按权重随机分配首选模型，0 表示只作为降级备选';
+
+ALTER TABLE llm_call_logs ADD COLUMN arm_model_id BIGINT;
+COMMENT ON COLUMN llm_call_logs.arm_model_id IS '按权重分配到的模型ID（分组），降级时与实际调用的 model_id 不同；指定 model_id 或使用默认配置时为空';
+CREATE INDEX idx_llm_call_logs_arm_model_id ON llm_call_logs(arm_model_id) WHERE arm_model_id IS NOT NULL;
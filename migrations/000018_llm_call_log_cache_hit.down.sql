@@ -0,0 +1 @@
+ALTER TABLE llm_call_logs DROP COLUMN IF EXISTS cache_hit;
@@ -0,0 +1,2 @@
+ALTER TABLE llm_call_logs ADD COLUMN cache_hit BOOLEAN NOT NULL DEFAULT false;
+COMMENT ON COLUMN llm_call_logs.cache_hit IS '是否命中响应缓存，命中时Token与费用为0';
@@ -0,0 +1 @@
+ALTER TABLE llm_models DROP COLUMN IF EXISTS context_window;
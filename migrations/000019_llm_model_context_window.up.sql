@@ -0,0 +1,2 @@
+ALTER TABLE llm_models ADD COLUMN context_window INT NOT NULL DEFAULT 0;
+COMMENT ON COLUMN llm_models.context_window IS '上下文窗口（输入+输出 Token 上限），发送前据此拒绝或截断超长请求；0 表示不检查';
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS llm_jobs;
+DROP TABLE IF EXISTS llm_batches;
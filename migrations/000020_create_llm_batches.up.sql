@@ -0,0 +1,56 @@
+-- LLM 批量任务：批次与逐条任务
+CREATE TABLE llm_batches (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  name VARCHAR(128) NOT NULL DEFAULT '',
+  total_jobs INT NOT NULL DEFAULT 0,
+  canceled_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE llm_batches IS 'LLM批量任务批次';
+COMMENT ON COLUMN llm_batches.user_id IS '提交用户ID';
+COMMENT ON COLUMN llm_batches.name IS '批次名称';
+COMMENT ON COLUMN llm_batches.total_jobs IS '任务总数';
+COMMENT ON COLUMN llm_batches.canceled_at IS '取消时间，取消后未完成的任务不再执行';
+
+CREATE INDEX idx_llm_batches_user_id ON llm_batches(user_id, created_at DESC);
+
+CREATE TABLE llm_jobs (
+  id BIGSERIAL PRIMARY KEY,
+  batch_id BIGINT NOT NULL,
+  user_id BIGINT NOT NULL,
+  seq INT NOT NULL,
+  status SMALLINT NOT NULL DEFAULT 1,
+  route_key VARCHAR(64) NOT NULL,
+  request JSONB NOT NULL,
+  result JSONB NULL,
+  error_message TEXT NULL,
+  attempts INT NOT NULL DEFAULT 0,
+  next_run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  worker_id VARCHAR(64) NULL,
+  locked_until TIMESTAMPTZ NULL,
+  started_at TIMESTAMPTZ NULL,
+  finished_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE llm_jobs IS 'LLM批量任务明细，由 cmd/worker 执行';
+COMMENT ON COLUMN llm_jobs.batch_id IS '批次ID';
+COMMENT ON COLUMN llm_jobs.user_id IS '提交用户ID';
+COMMENT ON COLUMN llm_jobs.seq IS '批次内序号，从0开始，与提交顺序一致';
+COMMENT ON COLUMN llm_jobs.status IS '状态: 1排队/2执行中/3成功/4失败/5已取消';
+COMMENT ON COLUMN llm_jobs.route_key IS '并发分组：指定模型时为 model:<id>，否则为 purpose:<用途>';
+COMMENT ON COLUMN llm_jobs.request IS '对话请求(JSONB)，与 POST /v1/llm/chat 请求体一致';
+COMMENT ON COLUMN llm_jobs.result IS '对话结果(JSONB)，与 POST /v1/llm/chat 响应数据一致';
+COMMENT ON COLUMN llm_jobs.error_message IS '失败原因';
+COMMENT ON COLUMN llm_jobs.attempts IS '已执行次数';
+COMMENT ON COLUMN llm_jobs.next_run_at IS '最早可执行时间，可重试错误按退避推迟';
+COMMENT ON COLUMN llm_jobs.worker_id IS '执行中的 worker 标识';
+COMMENT ON COLUMN llm_jobs.locked_until IS '执行租约到期时间，worker 定期续租；过期视为 worker 已退出，任务可被重新领取';
+
+CREATE INDEX idx_llm_jobs_batch_id ON llm_jobs(batch_id, seq);
+CREATE INDEX idx_llm_jobs_pending ON llm_jobs(next_run_at, id) WHERE status = 1;
+CREATE INDEX idx_llm_jobs_running ON llm_jobs(locked_until) WHERE status = 2;
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS uniq_chapter_parses_in_progress;
@@ -0,0 +1,9 @@
+-- 同一章节只允许一个进行中的解析任务，避免并发发起重复解析
+UPDATE chapter_parses p SET status = 4, error_message = '任务超时未完成', updated_at = NOW()
+WHERE status IN (1, 2)
+  AND EXISTS (
+    SELECT 1 FROM chapter_parses q
+    WHERE q.chapter_id = p.chapter_id AND q.status IN (1, 2) AND q.id > p.id
+  );
+
+CREATE UNIQUE INDEX uniq_chapter_parses_in_progress ON chapter_parses(chapter_id) WHERE status IN (1, 2);
@@ -0,0 +1,362 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// anthropicVersion Messages API 版本请求头
+const anthropicVersion = "2023-06-01"
+
+// anthropicDefaultMaxTokens Messages API 的 max_tokens 为必填
+const anthropicDefaultMaxTokens = 4096
+
+// anthropicJSONInstruction Messages API 没有 JSON 模式，改为在系统提示词中要求
+const anthropicJSONInstruction = "只输出一个合法的 JSON 对象，不要输出任何其他内容。"
+
+// AnthropicProvider Anthropic Messages API：POST {base_url}/messages，x-api-key 鉴权。
+// base_url 与 OpenAI 兼容服务一致写到版本号，例如 https://api.anthropic.com/v1。
+type AnthropicProvider struct{}
+
+// anthropicRequest Messages API 请求
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	System      string               `json:"system,omitempty"`
+	Messages    []anthropicMessage   `json:"messages"`
+	MaxTokens   int                  `json:"max_tokens"`
+	Temperature *float32             `json:"temperature,omitempty"`
+	Stream      bool                 `json:"stream,omitempty"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+}
+
+// anthropicMessage 消息，角色只有 user / assistant，内容统一使用块数组
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+// anthropicBlock 内容块：text / image / tool_use / tool_result
+type anthropicBlock struct {
+	Type      string                `json:"type"`
+	Text      string                `json:"text,omitempty"`
+	Source    *anthropicImageSource `json:"source,omitempty"`
+	ID        string                `json:"id,omitempty"`
+	Name      string                `json:"name,omitempty"`
+	Input     json.RawMessage       `json:"input,omitempty"`
+	ToolUseID string                `json:"tool_use_id,omitempty"`
+	Content   string                `json:"content,omitempty"` // tool_result 的结果文本
+}
+
+// anthropicImageSource 图片来源：base64 内联或 url
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"` // auto / any / none
+}
+
+// anthropicResponse Messages API 非流式响应，也是流式 message_start 事件中的 message
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      anthropicUsage   `json:"usage"`
+}
+
+// anthropicUsage Token用量，input_tokens 不含缓存读写部分
+type anthropicUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+// anthropicStreamEvent 流式事件
+type anthropicStreamEvent struct {
+	Type         string             `json:"type"`
+	Index        int                `json:"index"`
+	Message      *anthropicResponse `json:"message"`
+	ContentBlock *anthropicBlock    `json:"content_block"`
+	Delta        *struct {
+		Type        string `json:"type"` // text_delta / input_json_delta
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (AnthropicProvider) Name() string { return ProviderAnthropic }
+
+func (AnthropicProvider) BuildRequest(req *ProviderRequest) (string, []byte, error) {
+	system, messages := toAnthropicMessages(req.Messages)
+	if req.JSONMode {
+		system = strings.TrimSpace(system + "\n\n" + anthropicJSONInstruction)
+	}
+	reqBody := anthropicRequest{
+		Model:     req.Model,
+		System:    system,
+		Messages:  messages,
+		MaxTokens: req.MaxTokens,
+		Stream:    req.Stream,
+	}
+	if reqBody.MaxTokens <= 0 {
+		reqBody.MaxTokens = anthropicDefaultMaxTokens
+	}
+	// 温度范围为 0~1
+	temperature := req.Temperature
+	if temperature > 1 {
+		temperature = 1
+	}
+	reqBody.Temperature = &temperature
+	if len(req.Tools) > 0 {
+		for _, t := range req.Tools {
+			schema := t.Function.Parameters
+			if len(schema) == 0 {
+				schema = json.RawMessage(`{"type":"object"}`)
+			}
+			reqBody.Tools = append(reqBody.Tools, anthropicTool{Name: t.Function.Name, Description: t.Function.Description, InputSchema: schema})
+		}
+		switch req.ToolChoice {
+		case "none":
+			reqBody.ToolChoice = &anthropicToolChoice{Type: "none"}
+		case "required":
+			reqBody.ToolChoice = &anthropicToolChoice{Type: "any"}
+		}
+	}
+
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+	return strings.TrimRight(req.BaseURL, "/") + "/messages", bodyBytes, nil
+}
+
+func (AnthropicProvider) SetHeaders(h http.Header, apiKey string) {
+	h.Set("x-api-key", apiKey)
+	h.Set("anthropic-version", anthropicVersion)
+}
+
+func (AnthropicProvider) ParseResponse(body []byte) (*ChatResult, error) {
+	var resp anthropicResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	result := &ChatResult{
+		Model:        resp.Model,
+		FinishReason: anthropicFinishReason(resp.StopReason),
+	}
+	var texts []string
+	for _, b := range resp.Content {
+		switch b.Type {
+		case "text":
+			texts = append(texts, b.Text)
+		case "tool_use":
+			args := string(b.Input)
+			if args == "" {
+				args = "{}"
+			}
+			result.ToolCalls = append(result.ToolCalls, ToolCall{
+				ID:       b.ID,
+				Type:     "function",
+				Function: FunctionCall{Name: b.Name, Arguments: args},
+			})
+		}
+	}
+	result.Content = strings.Join(texts, "")
+	resp.Usage.applyTo(result)
+	return result, nil
+}
+
+func (AnthropicProvider) ReadStream(r io.Reader, result *ChatResult, onDelta StreamHandler) error {
+	toolIndex := make(map[int]int) // 内容块序号 -> ToolCalls 下标
+	err := readSSE(r, func(data string) (bool, error) {
+		var ev anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &ev); err != nil {
+			return false, &Error{Kind: ErrKindMalformedResponse, StatusCode: http.StatusOK, Body: truncateBody([]byte(data)), Err: err}
+		}
+		switch ev.Type {
+		case "message_start":
+			if ev.Message != nil {
+				result.Model = ev.Message.Model
+				ev.Message.Usage.applyTo(result)
+			}
+		case "content_block_start":
+			if ev.ContentBlock != nil && ev.ContentBlock.Type == "tool_use" {
+				toolIndex[ev.Index] = len(result.ToolCalls)
+				result.ToolCalls = append(result.ToolCalls, ToolCall{
+					ID:       ev.ContentBlock.ID,
+					Type:     "function",
+					Function: FunctionCall{Name: ev.ContentBlock.Name},
+				})
+			}
+		case "content_block_delta":
+			if ev.Delta == nil {
+				return false, nil
+			}
+			switch ev.Delta.Type {
+			case "text_delta":
+				if ev.Delta.Text != "" {
+					return false, onDelta(ev.Delta.Text)
+				}
+			case "input_json_delta":
+				if i, ok := toolIndex[ev.Index]; ok {
+					result.ToolCalls[i].Function.Arguments += ev.Delta.PartialJSON
+				}
+			}
+		case "message_delta":
+			if ev.Delta != nil && ev.Delta.StopReason != "" {
+				result.FinishReason = anthropicFinishReason(ev.Delta.StopReason)
+			}
+			if ev.Usage != nil {
+				ev.Usage.applyTo(result)
+			}
+		case "message_stop":
+			return true, nil
+		case "error":
+			return false, anthropicStreamError(ev, data)
+		}
+		return false, nil
+	})
+	// 无参数的工具调用不会收到 input_json_delta
+	for i := range result.ToolCalls {
+		if result.ToolCalls[i].Function.Arguments == "" {
+			result.ToolCalls[i].Function.Arguments = "{}"
+		}
+	}
+	return err
+}
+
+// applyTo 写入调用结果；流式 message_delta 中输入用量可能缺省，此时保留 message_start 的值
+func (u anthropicUsage) applyTo(r *ChatResult) {
+	if input := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens; input > 0 {
+		r.PromptTokens = input
+		r.CachedTokens = u.CacheReadInputTokens
+	}
+	r.CompletionTokens = u.OutputTokens
+	r.TotalTokens = r.PromptTokens + r.CompletionTokens
+}
+
+// anthropicFinishReason 结束原因统一为 OpenAI 取值
+func anthropicFinishReason(stopReason string) string {
+	switch stopReason {
+	case "end_turn", "stop_sequence", "pause_turn":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	}
+	return stopReason
+}
+
+// anthropicStreamError 流式过程中的 error 事件（如服务过载）
+func anthropicStreamError(ev anthropicStreamEvent, data string) *Error {
+	e := &Error{Kind: ErrKindUpstream, StatusCode: http.StatusOK, Body: truncateBody([]byte(data))}
+	if ev.Error != nil {
+		switch ev.Error.Type {
+		case "rate_limit_error":
+			e.Kind = ErrKindRateLimited
+		case "invalid_request_error":
+			e.Kind = ErrKindBadRequest
+		}
+		e.Err = fmt.Errorf("%s: %s", ev.Error.Type, ev.Error.Message)
+	}
+	return e
+}
+
+// toAnthropicMessages 转换消息：system 消息合并为顶层 system，tool 消息转为 user 角色的 tool_result，
+// 相邻同角色消息合并，满足 user / assistant 交替的要求
+func toAnthropicMessages(messages []ChatMessage) (string, []anthropicMessage) {
+	var systems []string
+	var out []anthropicMessage
+	for _, m := range messages {
+		role := m.Role
+		var blocks []anthropicBlock
+		switch m.Role {
+		case "system":
+			if text := m.Text(); text != "" {
+				systems = append(systems, text)
+			}
+			continue
+		case "tool":
+			role = "user"
+			blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: m.ToolCallID, Content: m.Text()})
+		case "assistant":
+			if text := m.Text(); text != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: text})
+			}
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage(`{}`)
+				}
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+		default:
+			role = "user"
+			blocks = anthropicUserBlocks(m)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].Role == role {
+			out[n-1].Content = append(out[n-1].Content, blocks...)
+			continue
+		}
+		out = append(out, anthropicMessage{Role: role, Content: blocks})
+	}
+	return strings.Join(systems, "\n\n"), out
+}
+
+// anthropicUserBlocks user 消息的文本与图片块
+func anthropicUserBlocks(m ChatMessage) []anthropicBlock {
+	if len(m.Parts) == 0 {
+		if m.Content == "" {
+			return nil
+		}
+		return []anthropicBlock{{Type: "text", Text: m.Content}}
+	}
+	blocks := make([]anthropicBlock, 0, len(m.Parts))
+	for _, p := range m.Parts {
+		switch {
+		case p.Type == ContentPartText && p.Text != "":
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: p.Text})
+		case p.Type == ContentPartImageURL && p.ImageURL != nil:
+			blocks = append(blocks, anthropicBlock{Type: "image", Source: anthropicImage(p.ImageURL.URL)})
+		}
+	}
+	return blocks
+}
+
+// anthropicImage data URI 转为 base64 来源，其余按 URL 传递
+func anthropicImage(url string) *anthropicImageSource {
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		meta, data, found := strings.Cut(rest, ",")
+		if mediaType, enc, _ := strings.Cut(meta, ";"); found && enc == "base64" {
+			return &anthropicImageSource{Type: "base64", MediaType: mediaType, Data: data}
+		}
+	}
+	return &anthropicImageSource{Type: "url", URL: url}
+}
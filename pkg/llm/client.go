@@ -1,11 +1,12 @@
 package llm
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -15,61 +16,45 @@ import (
 
 // ChatMessage OpenAI兼容的消息结构
 type ChatMessage struct {
-	Role    string `json:"role"`    // system / user / assistant
-	Content string `json:"content"` // 消息内容
-}
-
-// ChatRequest 对话请求
-type ChatRequest struct {
-	Model          string        `json:"model"`
-	Messages       []ChatMessage `json:"messages"`
-	MaxTokens      int           `json:"max_tokens,omitempty"`
-	Temperature    float32       `json:"temperature,omitempty"`
-	ResponseFormat *struct {
-		Type string `json:"type"`
-	} `json:"response_format,omitempty"`
-}
-
-// ChatResponse 对话响应（OpenAI标准格式）
-type ChatResponse struct {
-	ID      string `json:"id"`
-	Choices []struct {
-		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"message"`
-		FinishReason string `json:"finish_reason"`
-	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
+	Role       string        `json:"role"`                   // system / user / assistant / tool
+	Content    string        `json:"content"`                // 消息内容
+	Parts      []ContentPart `json:"-"`                      // 多模态内容片段，非空时替代 Content 以数组形式发送
+	ToolCalls  []ToolCall    `json:"tool_calls,omitempty"`   // assistant 消息发起的工具调用
+	ToolCallID string        `json:"tool_call_id,omitempty"` // tool 消息对应的调用ID
 }
 
+// StreamHandler 流式增量回调，返回错误时中止读取
+type StreamHandler func(delta string) error
+
 // ChatResult 封装后的调用结果
 type ChatResult struct {
-	Content          string // 模型返回的文本内容
-	PromptTokens     int    // 输入Token数
-	CompletionTokens int    // 输出Token数
-	TotalTokens      int    // 总Token数
-	DurationMs       int    // 调用耗时（毫秒）
+	Content          string     // 模型返回的文本内容
+	Model            string     // 服务商返回的模型标识
+	FinishReason     string     // 结束原因：stop / length / tool_calls / content_filter 等
+	ToolCalls        []ToolCall // 模型发起的工具调用
+	PromptTokens     int        // 输入Token数
+	CompletionTokens int        // 输出Token数
+	TotalTokens      int        // 总Token数
+	CachedTokens     int        // 其中命中缓存的输入Token数
+	DurationMs       int        // 调用耗时（毫秒）
 }
 
 // ClientConfig 客户端配置
 type ClientConfig struct {
-	BaseURL     string  // API端点URL
-	APIKey      string  // API密钥
-	Model       string  // 默认模型
-	MaxTokens   int     // 默认最大Token
-	Temperature float32 // 默认温度
-	Timeout     int     // 超时时间（秒）
+	BaseURL     string      // API端点URL
+	APIKey      string      // API密钥
+	Model       string      // 默认模型
+	MaxTokens   int         // 默认最大Token
+	Temperature float32     // 默认温度
+	Timeout     int         // 默认超时（秒）：非流式调用为整次调用时长，流式调用为等待响应头及相邻分片的最长间隔
+	Retry       RetryPolicy // 默认重试策略
 }
 
-// Client OpenAI兼容的LLM客户端
+// Client LLM客户端，按调用选项中的服务商协议（默认 OpenAI 兼容）发送请求
 type Client struct {
 	config     ClientConfig
 	httpClient *http.Client
+	sleep      func(ctx context.Context, d time.Duration) error
 }
 
 // NewClient 创建LLM客户端
@@ -83,119 +68,224 @@ func NewClient(cfg ClientConfig) *Client {
 	if cfg.Temperature <= 0 {
 		cfg.Temperature = 0.7
 	}
+	if cfg.Retry.MaxAttempts <= 0 {
+		cfg.Retry = DefaultRetryPolicy()
+	}
+	// 不设置客户端级超时：它同样限制读取响应体的时长，会截断长时间的流式输出；超时由每次调用单独控制
 	return &Client{
-		config: cfg,
-		httpClient: &http.Client{
-			Timeout: time.Duration(cfg.Timeout) * time.Second,
-		},
+		config:     cfg,
+		httpClient: &http.Client{},
+		sleep:      sleepContext,
 	}
 }
 
 // ChatCompletion 发送对话请求
 func (c *Client) ChatCompletion(ctx context.Context, messages []ChatMessage, opts ...ChatOption) (*ChatResult, error) {
-	if len(messages) == 0 {
-		return nil, errors.New("messages不能为空")
+	start := time.Now()
+	opt := c.options(opts)
+	if opt.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.Timeout)
+		defer cancel()
 	}
-
-	opt := c.defaultOptions()
-	for _, o := range opts {
-		o(&opt)
+	provider, resp, err := c.send(ctx, messages, false, opt)
+	if err != nil {
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	reqBody := ChatRequest{
-		Model:       opt.Model,
-		Messages:    messages,
-		MaxTokens:   opt.MaxTokens,
-		Temperature: opt.Temperature,
+	respBytes, err := io.ReadAll(resp.Body)
+	durationMs := int(time.Since(start).Milliseconds())
+	if err != nil {
+		return nil, transportError(ctx, err)
 	}
-	if opt.JSONMode {
-		reqBody.ResponseFormat = &struct {
-			Type string `json:"type"`
-		}{Type: "json_object"}
+
+	result, err := provider.ParseResponse(respBytes)
+	if err != nil {
+		return nil, &Error{Kind: ErrKindMalformedResponse, StatusCode: resp.StatusCode, Body: truncateBody(respBytes), Err: err}
 	}
+	result.DurationMs = durationMs
+	return result, nil
+}
 
-	bodyBytes, err := json.Marshal(reqBody)
+// ChatCompletionStream 以流式方式发送对话请求，每收到一段增量内容回调一次 onDelta。
+// 超时不限制整个流的时长，只限制等待响应头和相邻两次读到数据的间隔。
+// 流建立后出错（包括客户端断开导致ctx取消）时，仍返回已累积的部分结果和错误。
+func (c *Client) ChatCompletionStream(ctx context.Context, messages []ChatMessage, onDelta StreamHandler, opts ...ChatOption) (*ChatResult, error) {
+	start := time.Now()
+	provider, resp, err := c.send(ctx, messages, true, c.options(opts))
 	if err != nil {
-		return nil, fmt.Errorf("序列化请求失败: %w", err)
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	// 构建URL
-	baseURL := strings.TrimRight(opt.BaseURL, "/")
-	url := baseURL + "/chat/completions"
+	result := &ChatResult{}
+	var content strings.Builder
+	var handlerErr error
+	err = provider.ReadStream(resp.Body, result, func(delta string) error {
+		content.WriteString(delta)
+		if onDelta != nil {
+			handlerErr = onDelta(delta)
+		}
+		return handlerErr
+	})
+	result.Content = content.String()
+	result.DurationMs = int(time.Since(start).Milliseconds())
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(string(bodyBytes)))
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
+	var llmErr *Error
+	switch {
+	case err == nil || err == handlerErr || errors.As(err, &llmErr):
+		return result, err
+	case errors.Is(ctx.Err(), context.Canceled):
+		return result, ctx.Err()
+	default:
+		return result, transportError(ctx, err)
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+opt.APIKey)
+}
 
-	start := time.Now()
-	resp, err := c.httpClient.Do(req)
-	durationMs := int(time.Since(start).Milliseconds())
+// send 按协议构建并发送请求，仅在HTTP 200时返回响应
+func (c *Client) send(ctx context.Context, messages []ChatMessage, stream bool, opt chatOptions) (Provider, *http.Response, error) {
+	if len(messages) == 0 {
+		return nil, nil, errors.New("messages不能为空")
+	}
 
+	url, bodyBytes, err := opt.Provider.BuildRequest(&ProviderRequest{
+		BaseURL:     opt.BaseURL,
+		Model:       opt.Model,
+		Messages:    messages,
+		MaxTokens:   opt.MaxTokens,
+		Temperature: opt.Temperature,
+		JSONMode:    opt.JSONMode,
+		Tools:       opt.Tools,
+		ToolChoice:  opt.ToolChoice,
+		Stream:      stream,
+	})
 	if err != nil {
-		if ctx.Err() != nil {
-			return nil, fmt.Errorf("调用超时")
+		return nil, nil, err
+	}
+
+	policy := opt.Retry.normalize()
+	for attempt := 1; ; attempt++ {
+		resp, retryAfter, err := c.do(ctx, opt, url, bodyBytes, stream)
+		if err == nil {
+			return opt.Provider, resp, nil
+		}
+		if !shouldRetry(err) || attempt >= policy.MaxAttempts {
+			return nil, nil, err
+		}
+
+		wait := policy.backoff(attempt)
+		if retryAfter > 0 {
+			// 服务端要求的等待超过退避上限时不再重试，交由调用方降级或稍后重试，避免长时间阻塞
+			if retryAfter > policy.MaxBackoff {
+				return nil, nil, err
+			}
+			wait = retryAfter
+		}
+		// 等待时间超过剩余超时时间时不再重试
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, nil, err
+		}
+		log.Warnf("LLM调用失败，%v后重试 attempt=%d/%d: %v", wait, attempt, policy.MaxAttempts, err)
+		if sleepErr := c.sleep(ctx, wait); sleepErr != nil {
+			return nil, nil, &Error{Kind: ErrKindTimeout, Err: sleepErr}
 		}
-		return nil, fmt.Errorf("调用失败: %w", err)
 	}
-	defer resp.Body.Close()
+}
 
-	respBytes, err := io.ReadAll(resp.Body)
+// do 发送单次请求，返回 HTTP 200 的响应；失败时返回 *Error，限流时附带 Retry-After
+func (c *Client) do(ctx context.Context, opt chatOptions, url string, body []byte, stream bool) (*http.Response, time.Duration, *Error) {
+	provider := opt.Provider
+	// 流式请求的超时计时器：等待响应头期间及读取响应体时每两次读到数据之间超过 opt.Timeout 即取消请求
+	var idle *idleTimeout
+	if stream && opt.Timeout > 0 {
+		ctx, idle = newIdleTimeout(ctx, opt.Timeout)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+		idle.stop()
+		return nil, 0, &Error{Kind: ErrKindBadRequest, Err: fmt.Errorf("创建请求失败: %w", err)}
 	}
-
-	if resp.StatusCode == http.StatusTooManyRequests {
-		return nil, fmt.Errorf("模型限流")
+	req.Header.Set("Content-Type", "application/json")
+	provider.SetHeaders(req.Header, opt.APIKey)
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
 	}
-	if resp.StatusCode != http.StatusOK {
-		log.Errorf("LLM调用失败 status=%d body=%s", resp.StatusCode, string(respBytes))
-		return nil, fmt.Errorf("模型调用失败: HTTP %d", resp.StatusCode)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		idle.stop()
+		if idle.fired() {
+			return nil, 0, idle.err()
+		}
+		return nil, 0, transportError(ctx, err)
 	}
 
-	var chatResp ChatResponse
-	if err := json.Unmarshal(respBytes, &chatResp); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %w", err)
+	if resp.StatusCode == http.StatusOK {
+		if idle != nil {
+			resp.Body = &idleTimeoutBody{ReadCloser: resp.Body, idle: idle}
+		}
+		return resp, 0, nil
 	}
+	defer idle.stop()
+	defer resp.Body.Close()
 
-	content := ""
-	if len(chatResp.Choices) > 0 {
-		content = chatResp.Choices[0].Message.Content
+	respBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		log.Errorf("LLM调用失败 provider=%s status=%d body=%s", provider.Name(), resp.StatusCode, string(respBytes))
 	}
+	retryAfter, _ := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	return nil, retryAfter, newStatusError(resp.StatusCode, respBytes)
+}
 
-	return &ChatResult{
-		Content:          content,
-		PromptTokens:     chatResp.Usage.PromptTokens,
-		CompletionTokens: chatResp.Usage.CompletionTokens,
-		TotalTokens:      chatResp.Usage.TotalTokens,
-		DurationMs:       durationMs,
-	}, nil
+// transportError 归类网络层错误：ctx 超时或客户端超时为超时，其余为网络错误
+func transportError(ctx context.Context, err error) *Error {
+	var netErr net.Error
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &Error{Kind: ErrKindTimeout, Err: err}
+	}
+	return &Error{Kind: ErrKindNetwork, Err: err}
 }
 
 // ChatOption 调用选项
 type ChatOption func(*chatOptions)
 
 type chatOptions struct {
+	Provider    Provider
+	Timeout     time.Duration
 	BaseURL     string
 	APIKey      string
 	Model       string
 	MaxTokens   int
 	Temperature float32
 	JSONMode    bool
+	Retry       RetryPolicy
+	Tools       []Tool
+	ToolChoice  string
 }
 
 func (c *Client) defaultOptions() chatOptions {
 	return chatOptions{
+		Provider:    OpenAIProvider{},
+		Timeout:     time.Duration(c.config.Timeout) * time.Second,
 		BaseURL:     c.config.BaseURL,
 		APIKey:      c.config.APIKey,
 		Model:       c.config.Model,
 		MaxTokens:   c.config.MaxTokens,
 		Temperature: c.config.Temperature,
+		Retry:       c.config.Retry,
 	}
 }
 
+// options 合并默认选项与调用选项
+func (c *Client) options(opts []ChatOption) chatOptions {
+	opt := c.defaultOptions()
+	for _, o := range opts {
+		o(&opt)
+	}
+	return opt
+}
+
 // WithModel 指定模型
 func WithModel(model string) ChatOption {
 	return func(o *chatOptions) { o.Model = model }
@@ -216,6 +306,20 @@ func WithJSONMode() ChatOption {
 	return func(o *chatOptions) { o.JSONMode = true }
 }
 
+// WithProvider 按服务商标识选择协议，见 ProviderFor
+func WithProvider(provider string) ChatOption {
+	return func(o *chatOptions) { o.Provider = ProviderFor(provider) }
+}
+
+// WithTimeout 覆盖调用超时：非流式为整次调用时长，流式为等待响应头及相邻分片的最长间隔；<=0 时不覆盖
+func WithTimeout(d time.Duration) ChatOption {
+	return func(o *chatOptions) {
+		if d > 0 {
+			o.Timeout = d
+		}
+	}
+}
+
 // WithEndpoint 覆盖BaseURL和APIKey
 func WithEndpoint(baseURL, apiKey string) ChatOption {
 	return func(o *chatOptions) {
@@ -223,3 +327,16 @@ func WithEndpoint(baseURL, apiKey string) ChatOption {
 		o.APIKey = apiKey
 	}
 }
+
+// WithRetryPolicy 覆盖重试策略
+func WithRetryPolicy(p RetryPolicy) ChatOption {
+	return func(o *chatOptions) { o.Retry = p }
+}
+
+// WithTools 提供可调用的工具；toolChoice 为空时由模型决定（auto）
+func WithTools(toolChoice string, tools ...Tool) ChatOption {
+	return func(o *chatOptions) {
+		o.Tools = tools
+		o.ToolChoice = toolChoice
+	}
+}
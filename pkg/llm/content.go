@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// 内容片段类型
+const (
+	ContentPartText     = "text"
+	ContentPartImageURL = "image_url"
+)
+
+// imagePartTokens 单张图片的估算 Token 数（按 OpenAI 高清模式的常见开销保守估计）
+const imagePartTokens = 765
+
+// ContentPart 多模态消息的内容片段（OpenAI content parts 格式）
+type ContentPart struct {
+	Type     string    `json:"type"` // text / image_url
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+	// ResourceID 业务侧图片资源ID，由服务层解析为 ImageURL 后清空，不发送给服务商
+	ResourceID int64 `json:"resource_id,omitempty"`
+}
+
+// ImageURL 图片地址，可以是 http(s) URL 或 data:<mime>;base64,<数据>
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"` // auto / low / high
+}
+
+// TextPart 文本片段
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: ContentPartText, Text: text}
+}
+
+// ImagePart 图片片段
+func ImagePart(url string) ContentPart {
+	return ContentPart{Type: ContentPartImageURL, ImageURL: &ImageURL{URL: url}}
+}
+
+// chatMessageJSON ChatMessage 的线上格式，content 为字符串或片段数组
+type chatMessageJSON struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content"`
+	ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+// MarshalJSON 有 Parts 时 content 输出为片段数组，否则为字符串
+func (m ChatMessage) MarshalJSON() ([]byte, error) {
+	var (
+		content []byte
+		err     error
+	)
+	if len(m.Parts) > 0 {
+		content, err = json.Marshal(m.Parts)
+	} else {
+		content, err = json.Marshal(m.Content)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(chatMessageJSON{Role: m.Role, Content: content, ToolCalls: m.ToolCalls, ToolCallID: m.ToolCallID})
+}
+
+// UnmarshalJSON content 兼容字符串、片段数组与 null
+func (m *ChatMessage) UnmarshalJSON(b []byte) error {
+	var raw chatMessageJSON
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*m = ChatMessage{Role: raw.Role, ToolCalls: raw.ToolCalls, ToolCallID: raw.ToolCallID}
+	content := strings.TrimSpace(string(raw.Content))
+	switch {
+	case content == "" || content == "null":
+	case content[0] == '[':
+		if err := json.Unmarshal(raw.Content, &m.Parts); err != nil {
+			return err
+		}
+	default:
+		if err := json.Unmarshal(raw.Content, &m.Content); err != nil {
+			return errors.New("content 必须是字符串或内容片段数组")
+		}
+	}
+	return nil
+}
+
+// Text 消息的文本内容，多模态消息拼接全部文本片段
+func (m ChatMessage) Text() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	texts := make([]string, 0, len(m.Parts))
+	for _, p := range m.Parts {
+		if p.Type == ContentPartText {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// HasImages 消息是否包含图片片段
+func (m ChatMessage) HasImages() bool {
+	for _, p := range m.Parts {
+		if p.Type == ContentPartImageURL {
+			return true
+		}
+	}
+	return false
+}
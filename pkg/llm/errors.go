@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrorKind LLM调用错误分类
+type ErrorKind int
+
+const (
+	ErrKindUnknown           ErrorKind = iota
+	ErrKindRateLimited                 // 限流（HTTP 429）
+	ErrKindTimeout                     // 调用超时
+	ErrKindAuth                        // 鉴权失败（HTTP 401/403）
+	ErrKindBadRequest                  // 请求参数错误（其他4xx）
+	ErrKindUpstream                    // 上游服务错误（5xx）
+	ErrKindNetwork                     // 网络连接失败
+	ErrKindMalformedResponse           // 响应无法解析
+	ErrKindContextLength               // 上下文长度超限
+)
+
+// maxErrorBodyLen 错误中保留的响应体最大长度
+const maxErrorBodyLen = 2048
+
+// Error LLM调用错误，携带HTTP状态码和服务商返回的错误内容
+type Error struct {
+	Kind       ErrorKind
+	StatusCode int    // HTTP状态码，未收到响应时为0
+	Body       string // 服务商返回的错误响应体（截断）
+	Err        error  // 底层错误
+}
+
+func (e *Error) Error() string {
+	switch e.Kind {
+	case ErrKindRateLimited:
+		return "模型限流"
+	case ErrKindTimeout:
+		return "调用超时"
+	case ErrKindAuth:
+		return fmt.Sprintf("模型鉴权失败: HTTP %d", e.StatusCode)
+	case ErrKindBadRequest:
+		return fmt.Sprintf("模型请求参数错误: HTTP %d", e.StatusCode)
+	case ErrKindUpstream:
+		return fmt.Sprintf("模型调用失败: HTTP %d", e.StatusCode)
+	case ErrKindNetwork:
+		return fmt.Sprintf("调用失败: %v", e.Err)
+	case ErrKindMalformedResponse:
+		return fmt.Sprintf("解析响应失败: %v", e.Err)
+	case ErrKindContextLength:
+		return "上下文长度超限"
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return "模型调用失败"
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Retryable 限流、超时、网络错误和上游5xx可换模型或稍后重试
+func (e *Error) Retryable() bool {
+	switch e.Kind {
+	case ErrKindRateLimited, ErrKindTimeout, ErrKindUpstream, ErrKindNetwork:
+		return true
+	}
+	return false
+}
+
+// ProviderMessage 从错误响应体中提取服务商给出的错误描述
+func (e *Error) ProviderMessage() string {
+	var body providerErrorBody
+	if err := json.Unmarshal([]byte(e.Body), &body); err == nil && body.Error.Message != "" {
+		return body.Error.Message
+	}
+	return e.Body
+}
+
+// providerErrorBody OpenAI兼容的错误响应
+type providerErrorBody struct {
+	Error struct {
+		Message string      `json:"message"`
+		Type    string      `json:"type"`
+		Code    interface{} `json:"code"`
+	} `json:"error"`
+}
+
+// newStatusError 按HTTP状态码和错误响应体归类
+func newStatusError(status int, body []byte) *Error {
+	e := &Error{StatusCode: status, Body: truncateBody(body)}
+	switch {
+	case status == http.StatusTooManyRequests:
+		e.Kind = ErrKindRateLimited
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		e.Kind = ErrKindAuth
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		e.Kind = ErrKindTimeout
+	case status >= 500:
+		e.Kind = ErrKindUpstream
+	case isContextLengthBody(body):
+		e.Kind = ErrKindContextLength
+	default:
+		e.Kind = ErrKindBadRequest
+	}
+	return e
+}
+
+// isContextLengthBody 识别各服务商的上下文超长错误
+func isContextLengthBody(body []byte) bool {
+	var parsed providerErrorBody
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		if code, ok := parsed.Error.Code.(string); ok && code == "context_length_exceeded" {
+			return true
+		}
+	}
+	lower := strings.ToLower(string(body))
+	for _, kw := range []string{"context_length_exceeded", "maximum context length", "context length", "too many tokens", "prompt is too long"} {
+		if strings.Contains(lower, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateBody(b []byte) string {
+	if len(b) > maxErrorBodyLen {
+		b = b[:maxErrorBodyLen]
+	}
+	return string(b)
+}
@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ChatRequest 对话请求
+type ChatRequest struct {
+	Model          string        `json:"model"`
+	Messages       []ChatMessage `json:"messages"`
+	MaxTokens      int           `json:"max_tokens,omitempty"`
+	Temperature    *float32      `json:"temperature,omitempty"` // 指针：0 也需要显式发送
+	ResponseFormat *struct {
+		Type string `json:"type"`
+	} `json:"response_format,omitempty"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	Tools         []Tool         `json:"tools,omitempty"`
+	ToolChoice    string         `json:"tool_choice,omitempty"` // auto / none / required
+}
+
+// StreamOptions 流式选项
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"` // 最后一个分片携带用量
+}
+
+// Usage Token用量
+type Usage struct {
+	PromptTokens         int                 `json:"prompt_tokens"`
+	CompletionTokens     int                 `json:"completion_tokens"`
+	TotalTokens          int                 `json:"total_tokens"`
+	PromptTokensDetails  *PromptTokensDetail `json:"prompt_tokens_details,omitempty"`   // OpenAI 缓存命中明细
+	PromptCacheHitTokens int                 `json:"prompt_cache_hit_tokens,omitempty"` // DeepSeek 缓存命中Token数
+}
+
+// PromptTokensDetail 输入Token明细
+type PromptTokensDetail struct {
+	CachedTokens int `json:"cached_tokens"`
+}
+
+// CachedTokens 命中服务商缓存的输入Token数，兼容 OpenAI 与 DeepSeek 两种字段
+func (u Usage) CachedTokens() int {
+	if u.PromptTokensDetails != nil && u.PromptTokensDetails.CachedTokens > 0 {
+		return u.PromptTokensDetails.CachedTokens
+	}
+	return u.PromptCacheHitTokens
+}
+
+// ChatResponse 对话响应（OpenAI标准格式）
+type ChatResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Message struct {
+			Role      string     `json:"role"`
+			Content   string     `json:"content"`
+			ToolCalls []ToolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage Usage `json:"usage"`
+}
+
+// ChatStreamChunk 流式响应分片（OpenAI标准格式）
+type ChatStreamChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Role      string          `json:"role"`
+			Content   string          `json:"content"`
+			ToolCalls []toolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+// OpenAIProvider OpenAI 兼容协议：POST {base_url}/chat/completions，Bearer 鉴权
+type OpenAIProvider struct{}
+
+func (OpenAIProvider) Name() string { return ProviderOpenAI }
+
+func (OpenAIProvider) BuildRequest(req *ProviderRequest) (string, []byte, error) {
+	reqBody := ChatRequest{
+		Model:       req.Model,
+		Messages:    req.Messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: &req.Temperature,
+	}
+	if len(req.Tools) > 0 {
+		reqBody.Tools = req.Tools
+		reqBody.ToolChoice = req.ToolChoice
+	}
+	if req.JSONMode {
+		reqBody.ResponseFormat = &struct {
+			Type string `json:"type"`
+		}{Type: "json_object"}
+	}
+	if req.Stream {
+		reqBody.Stream = true
+		reqBody.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+
+	bodyBytes, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+	return strings.TrimRight(req.BaseURL, "/") + "/chat/completions", bodyBytes, nil
+}
+
+func (OpenAIProvider) SetHeaders(h http.Header, apiKey string) {
+	h.Set("Authorization", "Bearer "+apiKey)
+}
+
+func (OpenAIProvider) ParseResponse(body []byte) (*ChatResult, error) {
+	var chatResp ChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return nil, err
+	}
+
+	result := &ChatResult{
+		Model:            chatResp.Model,
+		PromptTokens:     chatResp.Usage.PromptTokens,
+		CompletionTokens: chatResp.Usage.CompletionTokens,
+		TotalTokens:      chatResp.Usage.TotalTokens,
+		CachedTokens:     chatResp.Usage.CachedTokens(),
+	}
+	if len(chatResp.Choices) > 0 {
+		result.Content = chatResp.Choices[0].Message.Content
+		result.FinishReason = chatResp.Choices[0].FinishReason
+		result.ToolCalls = chatResp.Choices[0].Message.ToolCalls
+	}
+	return result, nil
+}
+
+func (OpenAIProvider) ReadStream(r io.Reader, result *ChatResult, onDelta StreamHandler) error {
+	return readSSE(r, func(data string) (bool, error) {
+		if data == "[DONE]" {
+			return true, nil
+		}
+		var chunk ChatStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, &Error{Kind: ErrKindMalformedResponse, StatusCode: http.StatusOK, Body: truncateBody([]byte(data)), Err: err}
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.PromptTokens = chunk.Usage.PromptTokens
+			result.CompletionTokens = chunk.Usage.CompletionTokens
+			result.TotalTokens = chunk.Usage.TotalTokens
+			result.CachedTokens = chunk.Usage.CachedTokens()
+		}
+		if len(chunk.Choices) == 0 {
+			return false, nil
+		}
+		choice := chunk.Choices[0]
+		if choice.FinishReason != nil {
+			result.FinishReason = *choice.FinishReason
+		}
+		if len(choice.Delta.ToolCalls) > 0 {
+			result.ToolCalls = mergeToolCallDeltas(result.ToolCalls, choice.Delta.ToolCalls)
+		}
+		if choice.Delta.Content == "" {
+			return false, nil
+		}
+		return false, onDelta(choice.Delta.Content)
+	})
+}
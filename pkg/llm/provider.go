@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// 服务商协议
+const (
+	ProviderOpenAI    = "openai"    // OpenAI 兼容的 /chat/completions，DeepSeek、通义千问、智谱、Moonshot 等均使用该协议
+	ProviderAnthropic = "anthropic" // Anthropic Messages API
+)
+
+// Provider 服务商协议适配：负责请求地址、鉴权头、请求体与响应解析；重试、超时与错误归类由 Client 统一处理
+type Provider interface {
+	// Name 协议名
+	Name() string
+	// BuildRequest 返回请求地址与请求体
+	BuildRequest(req *ProviderRequest) (url string, body []byte, err error)
+	// SetHeaders 设置鉴权等协议相关的请求头
+	SetHeaders(h http.Header, apiKey string)
+	// ParseResponse 解析非流式响应
+	ParseResponse(body []byte) (*ChatResult, error)
+	// ReadStream 读取流式响应，文本增量回调 onDelta，其余字段累积到 result；正常结束返回 nil
+	ReadStream(r io.Reader, result *ChatResult, onDelta StreamHandler) error
+}
+
+// ProviderRequest 与协议无关的对话请求
+type ProviderRequest struct {
+	BaseURL     string
+	Model       string
+	Messages    []ChatMessage
+	MaxTokens   int
+	Temperature float32
+	JSONMode    bool
+	Tools       []Tool
+	ToolChoice  string // auto / none / required，为空表示 auto
+	Stream      bool
+}
+
+// ProviderFor 按服务商标识选择协议：anthropic（或 claude）使用 Messages API，其余按 OpenAI 兼容协议
+func ProviderFor(provider string) Provider {
+	switch strings.ToLower(strings.TrimSpace(provider)) {
+	case ProviderAnthropic, "claude":
+		return AnthropicProvider{}
+	default:
+		return OpenAIProvider{}
+	}
+}
+
+// readSSE 逐条读取 SSE 事件的 data 字段，fn 返回 true 时停止；读到 EOF 视为正常结束
+func readSSE(r io.Reader, fn func(data string) (bool, error)) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		done, err := fn(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		if err != nil || done {
+			return err
+		}
+	}
+}
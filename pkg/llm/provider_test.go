@@ -0,0 +1,290 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// capturedRequest 替身服务端收到的请求
+type capturedRequest struct {
+	path   string
+	header http.Header
+	body   map[string]any
+}
+
+// providerServer 记录请求并原样返回 status 与 body；流式响应以 SSE 返回
+func providerServer(t *testing.T, status int, body string) (*httptest.Server, *capturedRequest) {
+	t.Helper()
+	captured := &capturedRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		captured.path = r.URL.Path
+		captured.header = r.Header.Clone()
+		raw, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(raw, &captured.body); err != nil {
+			t.Errorf("request body is not JSON: %s", raw)
+		}
+		if stream, _ := captured.body["stream"].(bool); stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, captured
+}
+
+// noRetry 错误归类测试中不重试，避免 5xx 触发多次请求
+var noRetry = RetryPolicy{MaxAttempts: 1}
+
+var providerTestMessages = []ChatMessage{
+	{Role: "system", Content: "你是助手"},
+	{Role: "system", Content: "使用中文回答"},
+	{Role: "user", Content: "你好"},
+}
+
+func TestOpenAIProviderRequestAndUsage(t *testing.T) {
+	srv, req := providerServer(t, http.StatusOK, `{"model":"gpt-x","choices":[{"message":{"content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15,"prompt_tokens_details":{"cached_tokens":4}}}`)
+	c, _ := newTestClient(srv.URL, noRetry)
+
+	res, err := c.ChatCompletion(context.Background(), providerTestMessages, WithEndpoint(srv.URL, "sk-test"), WithJSONMode())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.path != "/chat/completions" {
+		t.Fatalf("path = %q", req.path)
+	}
+	if got := req.header.Get("Authorization"); got != "Bearer sk-test" {
+		t.Fatalf("Authorization = %q", got)
+	}
+	if req.header.Get("x-api-key") != "" {
+		t.Fatalf("unexpected x-api-key header")
+	}
+	// system 消息保留在 messages 中
+	if msgs, _ := req.body["messages"].([]any); len(msgs) != 3 {
+		t.Fatalf("messages = %v", req.body["messages"])
+	}
+	if rf, _ := req.body["response_format"].(map[string]any); rf["type"] != "json_object" {
+		t.Fatalf("response_format = %v", req.body["response_format"])
+	}
+	if res.Content != "hi" || res.Model != "gpt-x" || res.FinishReason != "stop" {
+		t.Fatalf("result = %+v", res)
+	}
+	if res.PromptTokens != 10 || res.CompletionTokens != 5 || res.TotalTokens != 15 || res.CachedTokens != 4 {
+		t.Fatalf("usage = %+v", res)
+	}
+}
+
+func TestOpenAIProviderDeepSeekCachedTokens(t *testing.T) {
+	srv, _ := providerServer(t, http.StatusOK, `{"model":"deepseek-chat","choices":[{"message":{"content":"hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15,"prompt_cache_hit_tokens":6}}`)
+	c, _ := newTestClient(srv.URL, noRetry)
+
+	res, err := c.ChatCompletion(context.Background(), providerTestMessages)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.CachedTokens != 6 {
+		t.Fatalf("cached tokens = %d", res.CachedTokens)
+	}
+}
+
+func TestOpenAIProviderStream(t *testing.T) {
+	sse := strings.Join([]string{
+		`data: {"model":"gpt-x","choices":[{"delta":{"content":"你"}}]}`,
+		`data: {"choices":[{"delta":{"content":"好"}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"q\":"}}]}}]}`,
+		`data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"x\"}"}}]},"finish_reason":"tool_calls"}]}`,
+		`data: {"choices":[],"usage":{"prompt_tokens":8,"completion_tokens":3,"total_tokens":11}}`,
+		`data: [DONE]`,
+		``,
+	}, "\n\n")
+	srv, req := providerServer(t, http.StatusOK, sse)
+	c, _ := newTestClient(srv.URL, noRetry)
+
+	var deltas []string
+	res, err := c.ChatCompletionStream(context.Background(), providerTestMessages, func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts, _ := req.body["stream_options"].(map[string]any); opts["include_usage"] != true {
+		t.Fatalf("stream_options = %v", req.body["stream_options"])
+	}
+	if strings.Join(deltas, "|") != "你|好" || res.Content != "你好" {
+		t.Fatalf("deltas = %v content = %q", deltas, res.Content)
+	}
+	if len(res.ToolCalls) != 1 || res.ToolCalls[0].Function.Name != "lookup" || res.ToolCalls[0].Function.Arguments != `{"q":"x"}` {
+		t.Fatalf("tool calls = %+v", res.ToolCalls)
+	}
+	if res.FinishReason != "tool_calls" || res.PromptTokens != 8 || res.CompletionTokens != 3 || res.TotalTokens != 11 {
+		t.Fatalf("result = %+v", res)
+	}
+}
+
+func TestAnthropicProviderRequestAndUsage(t *testing.T) {
+	srv, req := providerServer(t, http.StatusOK, `{"id":"msg_1","model":"claude-x","content":[{"type":"text","text":"hi"},{"type":"tool_use","id":"tu_1","name":"lookup","input":{"q":"x"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5,"cache_creation_input_tokens":2,"cache_read_input_tokens":3}}`)
+	c, _ := newTestClient(srv.URL, noRetry)
+
+	res, err := c.ChatCompletion(context.Background(), providerTestMessages,
+		WithProvider("anthropic"), WithEndpoint(srv.URL, "sk-ant"), WithTemperature(1.5), WithJSONMode())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.path != "/messages" {
+		t.Fatalf("path = %q", req.path)
+	}
+	if got := req.header.Get("x-api-key"); got != "sk-ant" {
+		t.Fatalf("x-api-key = %q", got)
+	}
+	if got := req.header.Get("anthropic-version"); got != anthropicVersion {
+		t.Fatalf("anthropic-version = %q", got)
+	}
+	if req.header.Get("Authorization") != "" {
+		t.Fatalf("unexpected Authorization header")
+	}
+	// system 消息拆到顶层 system，JSON 模式追加到系统提示词
+	if got := req.body["system"]; got != "你是助手\n\n使用中文回答\n\n"+anthropicJSONInstruction {
+		t.Fatalf("system = %q", got)
+	}
+	msgs, _ := req.body["messages"].([]any)
+	if len(msgs) != 1 || msgs[0].(map[string]any)["role"] != "user" {
+		t.Fatalf("messages = %v", req.body["messages"])
+	}
+	if req.body["temperature"] != 1.0 || req.body["max_tokens"] != 4096.0 {
+		t.Fatalf("temperature = %v max_tokens = %v", req.body["temperature"], req.body["max_tokens"])
+	}
+	if res.Content != "hi" || res.Model != "claude-x" || res.FinishReason != "tool_calls" {
+		t.Fatalf("result = %+v", res)
+	}
+	if len(res.ToolCalls) != 1 || res.ToolCalls[0].ID != "tu_1" || res.ToolCalls[0].Function.Arguments != `{"q":"x"}` {
+		t.Fatalf("tool calls = %+v", res.ToolCalls)
+	}
+	// 输入Token含缓存写入与读取，缓存命中取读取部分
+	if res.PromptTokens != 15 || res.CachedTokens != 3 || res.CompletionTokens != 5 || res.TotalTokens != 20 {
+		t.Fatalf("usage = %+v", res)
+	}
+}
+
+func TestAnthropicProviderToolMessages(t *testing.T) {
+	srv, req := providerServer(t, http.StatusOK, `{"model":"claude-x","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`)
+	c, _ := newTestClient(srv.URL, noRetry)
+
+	messages := []ChatMessage{
+		{Role: "user", Content: "查一下"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "tu_1", Type: "function", Function: FunctionCall{Name: "lookup", Arguments: `{"q":"x"}`}}}},
+		{Role: "tool", ToolCallID: "tu_1", Content: "结果"},
+		{Role: "user", Content: "继续"},
+	}
+	if _, err := c.ChatCompletion(context.Background(), messages, WithProvider("anthropic")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	msgs, _ := req.body["messages"].([]any)
+	if len(msgs) != 3 {
+		t.Fatalf("expected tool result merged with following user message, got %v", msgs)
+	}
+	last := msgs[2].(map[string]any)
+	blocks, _ := last["content"].([]any)
+	if last["role"] != "user" || len(blocks) != 2 || blocks[0].(map[string]any)["type"] != "tool_result" || blocks[0].(map[string]any)["tool_use_id"] != "tu_1" {
+		t.Fatalf("last message = %v", last)
+	}
+}
+
+func TestAnthropicProviderStream(t *testing.T) {
+	sse := strings.Join([]string{
+		"event: message_start\n" + `data: {"type":"message_start","message":{"model":"claude-x","usage":{"input_tokens":10,"output_tokens":1,"cache_read_input_tokens":4}}}`,
+		"event: content_block_start\n" + `data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"你"}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"好"}}`,
+		"event: content_block_start\n" + `data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"tu_1","name":"lookup"}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"q\":"}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"x\"}"}}`,
+		"event: message_delta\n" + `data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}`,
+		"event: message_stop\n" + `data: {"type":"message_stop"}`,
+		``,
+	}, "\n\n")
+	srv, req := providerServer(t, http.StatusOK, sse)
+	c, _ := newTestClient(srv.URL, noRetry)
+
+	var deltas []string
+	res, err := c.ChatCompletionStream(context.Background(), providerTestMessages, func(d string) error {
+		deltas = append(deltas, d)
+		return nil
+	}, WithProvider("anthropic"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if req.body["stream"] != true {
+		t.Fatalf("stream = %v", req.body["stream"])
+	}
+	if strings.Join(deltas, "|") != "你|好" || res.Content != "你好" {
+		t.Fatalf("deltas = %v content = %q", deltas, res.Content)
+	}
+	if len(res.ToolCalls) != 1 || res.ToolCalls[0].ID != "tu_1" || res.ToolCalls[0].Function.Arguments != `{"q":"x"}` {
+		t.Fatalf("tool calls = %+v", res.ToolCalls)
+	}
+	// message_delta 不带输入用量时保留 message_start 的值
+	if res.FinishReason != "tool_calls" || res.PromptTokens != 14 || res.CachedTokens != 4 || res.CompletionTokens != 7 || res.TotalTokens != 21 {
+		t.Fatalf("result = %+v", res)
+	}
+}
+
+func TestAnthropicProviderStreamErrorEvent(t *testing.T) {
+	sse := strings.Join([]string{
+		"event: message_start\n" + `data: {"type":"message_start","message":{"model":"claude-x","usage":{"input_tokens":10}}}`,
+		"event: content_block_delta\n" + `data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"部分"}}`,
+		"event: error\n" + `data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+		``,
+	}, "\n\n")
+	srv, _ := providerServer(t, http.StatusOK, sse)
+	c, _ := newTestClient(srv.URL, noRetry)
+
+	res, err := c.ChatCompletionStream(context.Background(), providerTestMessages, nil, WithProvider("anthropic"))
+	var llmErr *Error
+	if !errors.As(err, &llmErr) || llmErr.Kind != ErrKindUpstream {
+		t.Fatalf("expected upstream error, got %v", err)
+	}
+	if res == nil || res.Content != "部分" {
+		t.Fatalf("expected partial result, got %+v", res)
+	}
+}
+
+func TestProviderErrorStatusMapping(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   ErrorKind
+	}{
+		{http.StatusTooManyRequests, `{"error":{"message":"slow down"}}`, ErrKindRateLimited},
+		{http.StatusUnauthorized, `{"error":{"message":"bad key"}}`, ErrKindAuth},
+		{http.StatusForbidden, `{"error":{"message":"forbidden"}}`, ErrKindAuth},
+		{http.StatusBadRequest, `{"error":{"message":"bad"}}`, ErrKindBadRequest},
+		{http.StatusBadRequest, `{"error":{"code":"context_length_exceeded","message":"too long"}}`, ErrKindContextLength},
+		{http.StatusBadRequest, `{"type":"error","error":{"type":"invalid_request_error","message":"prompt is too long: 210000 tokens > 200000 maximum"}}`, ErrKindContextLength},
+		{http.StatusGatewayTimeout, `timeout`, ErrKindTimeout},
+		{http.StatusInternalServerError, `{"error":{"message":"boom"}}`, ErrKindUpstream},
+		{529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`, ErrKindUpstream},
+	}
+	for _, provider := range []string{ProviderOpenAI, ProviderAnthropic} {
+		for _, tc := range cases {
+			srv, _ := providerServer(t, tc.status, tc.body)
+			c, _ := newTestClient(srv.URL, noRetry)
+			_, err := c.ChatCompletion(context.Background(), providerTestMessages, WithProvider(provider))
+			var llmErr *Error
+			if !errors.As(err, &llmErr) {
+				t.Fatalf("%s %d: expected *Error, got %v", provider, tc.status, err)
+			}
+			if llmErr.Kind != tc.want || llmErr.StatusCode != tc.status {
+				t.Fatalf("%s %d %s: kind = %d status = %d, want %d", provider, tc.status, tc.body, llmErr.Kind, llmErr.StatusCode, tc.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// retryServer 前 failures 次返回 status，之后返回成功响应
+func retryServer(t *testing.T, status, failures int, retryAfter string) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if int(n) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			io.WriteString(w, `{"error":{"message":"fail"}}`)
+			return
+		}
+		io.WriteString(w, `{"model":"m","choices":[{"message":{"content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+// newTestClient 创建不真正等待的客户端，记录每次退避时长
+func newTestClient(baseURL string, policy RetryPolicy) (*Client, *[]time.Duration) {
+	c := NewClient(ClientConfig{BaseURL: baseURL, APIKey: "k", Model: "m", Retry: policy})
+	var waits []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	return c, &waits
+}
+
+var testPolicy = RetryPolicy{MaxAttempts: 3, BaseBackoff: 100 * time.Millisecond, MaxBackoff: 10 * time.Second}
+
+func TestRetryOnRetryableStatus(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		srv, calls := retryServer(t, status, 1, "")
+		c, waits := newTestClient(srv.URL, testPolicy)
+		res, err := c.ChatCompletion(context.Background(), []ChatMessage{{Role: "user", Content: "hi"}})
+		if err != nil {
+			t.Fatalf("status %d: unexpected error %v", status, err)
+		}
+		if res.Content != "ok" || *calls != 2 || len(*waits) != 1 {
+			t.Fatalf("status %d: content=%q calls=%d waits=%v", status, res.Content, *calls, *waits)
+		}
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	cases := map[int]ErrorKind{
+		http.StatusBadRequest:   ErrKindBadRequest,
+		http.StatusUnauthorized: ErrKindAuth,
+	}
+	for status, kind := range cases {
+		srv, calls := retryServer(t, status, 10, "")
+		c, waits := newTestClient(srv.URL, testPolicy)
+		_, err := c.ChatCompletion(context.Background(), []ChatMessage{{Role: "user", Content: "hi"}})
+		var llmErr *Error
+		if !errors.As(err, &llmErr) || llmErr.Kind != kind {
+			t.Fatalf("status %d: got %v, want kind %v", status, err, kind)
+		}
+		if *calls != 1 || len(*waits) != 0 {
+			t.Fatalf("status %d: calls=%d waits=%v, want no retry", status, *calls, *waits)
+		}
+	}
+}
+
+func TestRetryAfterHonoured(t *testing.T) {
+	srv, calls := retryServer(t, http.StatusTooManyRequests, 1, "2")
+	c, waits := newTestClient(srv.URL, testPolicy)
+	if _, err := c.ChatCompletion(context.Background(), []ChatMessage{{Role: "user", Content: "hi"}}); err != nil {
+		t.Fatal(err)
+	}
+	if *calls != 2 || len(*waits) != 1 || (*waits)[0] != 2*time.Second {
+		t.Fatalf("calls=%d waits=%v, want one 2s wait", *calls, *waits)
+	}
+}
+
+func TestRetryAfterBeyondMaxBackoffFailsFast(t *testing.T) {
+	srv, calls := retryServer(t, http.StatusTooManyRequests, 1, "3600")
+	c, waits := newTestClient(srv.URL, testPolicy)
+	_, err := c.ChatCompletion(context.Background(), []ChatMessage{{Role: "user", Content: "hi"}})
+	var llmErr *Error
+	if !errors.As(err, &llmErr) || llmErr.Kind != ErrKindRateLimited {
+		t.Fatalf("got %v, want rate limited", err)
+	}
+	if *calls != 1 || len(*waits) != 0 {
+		t.Fatalf("calls=%d waits=%v, want fail fast", *calls, *waits)
+	}
+}
+
+func TestRetryStopsAtMaxAttempts(t *testing.T) {
+	srv, calls := retryServer(t, http.StatusServiceUnavailable, 10, "")
+	c, waits := newTestClient(srv.URL, testPolicy)
+	_, err := c.ChatCompletion(context.Background(), []ChatMessage{{Role: "user", Content: "hi"}})
+	var llmErr *Error
+	if !errors.As(err, &llmErr) || llmErr.Kind != ErrKindUpstream || llmErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got %v, want upstream 503", err)
+	}
+	if *calls != 3 || len(*waits) != 2 {
+		t.Fatalf("calls=%d waits=%v, want 3 attempts", *calls, *waits)
+	}
+	if (*waits)[0] != 100*time.Millisecond || (*waits)[1] != 200*time.Millisecond {
+		t.Fatalf("waits=%v, want exponential backoff 100ms, 200ms", *waits)
+	}
+}
@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// errStreamIdle 流式请求在超时时间内没有收到任何数据
+var errStreamIdle = errors.New("流式响应空闲超时")
+
+// idleTimeout 流式请求的空闲计时器：每次读到数据重新计时，超时后取消请求
+type idleTimeout struct {
+	d      time.Duration
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	timer  *time.Timer
+}
+
+// newIdleTimeout 返回超时后会被取消的子 ctx 及其计时器
+func newIdleTimeout(ctx context.Context, d time.Duration) (context.Context, *idleTimeout) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	t := &idleTimeout{d: d, ctx: ctx, cancel: cancel}
+	t.timer = time.AfterFunc(d, func() { cancel(errStreamIdle) })
+	return ctx, t
+}
+
+// reset 收到数据后重新计时
+func (t *idleTimeout) reset() {
+	t.timer.Reset(t.d)
+}
+
+// stop 停止计时并释放子 ctx；nil 时为空操作
+func (t *idleTimeout) stop() {
+	if t == nil {
+		return
+	}
+	t.timer.Stop()
+	t.cancel(nil)
+}
+
+// fired 请求是否因空闲超时被取消
+func (t *idleTimeout) fired() bool {
+	return t != nil && errors.Is(context.Cause(t.ctx), errStreamIdle)
+}
+
+func (t *idleTimeout) err() *Error {
+	return &Error{Kind: ErrKindTimeout, Err: errStreamIdle}
+}
+
+// idleTimeoutBody 读到数据时刷新空闲计时器，因空闲超时中断时返回超时错误
+type idleTimeoutBody struct {
+	io.ReadCloser
+	idle *idleTimeout
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.idle.reset()
+	}
+	if err != nil && !errors.Is(err, io.EOF) && b.idle.fired() {
+		return n, b.idle.err()
+	}
+	return n, err
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.idle.stop()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,99 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowStreamServer 每隔 gap 发送一个分片，共 chunks 个
+func slowStreamServer(t *testing.T, chunks int, gap time.Duration) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for i := 0; i < chunks; i++ {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":\"%d\"}}]}\n\n", i)
+			flusher.Flush()
+			select {
+			case <-time.After(gap):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// hangingServer 直到测试结束都不返回响应头
+func hangingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { close(release) })
+	return srv
+}
+
+func TestStreamOutlivesTimeoutWhileChunksArrive(t *testing.T) {
+	// 整个流约 300ms，超过超时时间，但分片间隔始终小于超时
+	srv := slowStreamServer(t, 6, 50*time.Millisecond)
+	c, _ := newTestClient(srv.URL, noRetry)
+
+	res, err := c.ChatCompletionStream(context.Background(), providerTestMessages, nil, WithTimeout(150*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Content != "012345" {
+		t.Fatalf("content = %q", res.Content)
+	}
+}
+
+func TestStreamIdleTimeout(t *testing.T) {
+	srv := slowStreamServer(t, 3, time.Second)
+	c, _ := newTestClient(srv.URL, noRetry)
+
+	res, err := c.ChatCompletionStream(context.Background(), providerTestMessages, nil, WithTimeout(100*time.Millisecond))
+	var llmErr *Error
+	if !errors.As(err, &llmErr) || llmErr.Kind != ErrKindTimeout {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if res == nil || res.Content != "0" {
+		t.Fatalf("expected partial result, got %+v", res)
+	}
+}
+
+func TestStreamResponseHeaderTimeout(t *testing.T) {
+	srv := hangingServer(t)
+	c, _ := newTestClient(srv.URL, noRetry)
+
+	_, err := c.ChatCompletionStream(context.Background(), providerTestMessages, nil, WithTimeout(100*time.Millisecond))
+	var llmErr *Error
+	if !errors.As(err, &llmErr) || llmErr.Kind != ErrKindTimeout {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+}
+
+func TestNonStreamTimeout(t *testing.T) {
+	srv := hangingServer(t)
+	c, _ := newTestClient(srv.URL, noRetry)
+
+	start := time.Now()
+	_, err := c.ChatCompletion(context.Background(), providerTestMessages, WithTimeout(100*time.Millisecond))
+	var llmErr *Error
+	if !errors.As(err, &llmErr) || llmErr.Kind != ErrKindTimeout {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("timeout not applied, took %v", elapsed)
+	}
+}
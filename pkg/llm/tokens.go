@@ -0,0 +1,34 @@
+package llm
+
+import "manjing-ai-go/pkg/tokenizer"
+
+// messageOverheadTokens 每条消息在 role、分隔符上的额外开销
+const messageOverheadTokens = 4
+
+// EstimateTokens 粗略估算文本 Token 数，见 tokenizer.Default。
+// 用于截断历史等预算控制，结果偏保守，不能替代模型返回的实际用量。
+func EstimateTokens(text string) int {
+	return tokenizer.Default.Count(text)
+}
+
+// EstimateMessagesTokens 使用默认分词器估算一组消息的 Token 数
+func EstimateMessagesTokens(messages []ChatMessage) int {
+	return CountMessagesTokens(tokenizer.Default, messages)
+}
+
+// CountMessagesTokens 使用指定分词器估算一组消息作为请求输入的 Token 数
+func CountMessagesTokens(enc *tokenizer.Encoding, messages []ChatMessage) int {
+	total := 0
+	for _, m := range messages {
+		total += enc.Count(m.Text()) + messageOverheadTokens
+		for _, p := range m.Parts {
+			if p.Type == ContentPartImageURL {
+				total += imagePartTokens
+			}
+		}
+		for _, c := range m.ToolCalls {
+			total += enc.Count(c.Function.Name) + enc.Count(c.Function.Arguments)
+		}
+	}
+	return total
+}
@@ -0,0 +1,64 @@
+package llm
+
+import "encoding/json"
+
+// Tool 可供模型调用的工具定义（OpenAI tools 格式）
+type Tool struct {
+	Type     string       `json:"type"` // 目前仅支持 function
+	Function FunctionSpec `json:"function"`
+}
+
+// FunctionSpec 函数定义
+type FunctionSpec struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"` // 参数的 JSON Schema
+}
+
+// ToolCall 模型发起的工具调用
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall 函数调用的名称与参数
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON 字符串，可能不合法，需调用方校验
+}
+
+// NewFunctionTool 创建函数工具定义
+func NewFunctionTool(name, description string, parameters json.RawMessage) Tool {
+	return Tool{Type: "function", Function: FunctionSpec{Name: name, Description: description, Parameters: parameters}}
+}
+
+// toolCallDelta 流式分片中的工具调用增量，同一调用按 index 拼接
+type toolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// mergeToolCallDeltas 把流式增量合并到已累积的工具调用中
+func mergeToolCallDeltas(calls []ToolCall, deltas []toolCallDelta) []ToolCall {
+	for _, d := range deltas {
+		for len(calls) <= d.Index {
+			calls = append(calls, ToolCall{Type: "function"})
+		}
+		c := &calls[d.Index]
+		if d.ID != "" {
+			c.ID = d.ID
+		}
+		if d.Type != "" {
+			c.Type = d.Type
+		}
+		c.Function.Name += d.Function.Name
+		c.Function.Arguments += d.Function.Arguments
+	}
+	return calls
+}
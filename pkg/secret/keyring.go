@@ -0,0 +1,212 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 密文格式：enc:v1:<主密钥ID>:<base64(被主密钥加密的数据密钥)>:<base64(被数据密钥加密的明文)>
+const (
+	prefix  = "enc:"
+	version = "v1"
+)
+
+// IsEncrypted 判断值是否为本包生成的密文
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// Keyring 主密钥环：当前主密钥用于加密，历史主密钥仅在轮换期间用于解密
+type Keyring struct {
+	current *masterKey
+	keys    map[string]*masterKey
+}
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewKeyring 创建密钥环，主密钥为 base64 编码的32字节随机值（如 openssl rand -base64 32）
+func NewKeyring(current string, previous []string) (*Keyring, error) {
+	if current == "" {
+		return nil, errors.New("主密钥未配置")
+	}
+	cur, err := newMasterKey(current)
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{current: cur, keys: map[string]*masterKey{cur.id: cur}}
+	for _, p := range previous {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		mk, err := newMasterKey(p)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[mk.id] = mk
+	}
+	return k, nil
+}
+
+func newMasterKey(encoded string) (*masterKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(raw) != 32 {
+		return nil, errors.New("主密钥必须是base64编码的32字节")
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(raw)
+	return &masterKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+// CurrentKeyID 当前主密钥ID
+func (k *Keyring) CurrentKeyID() string {
+	return k.current.id
+}
+
+// Encrypt 使用随机数据密钥加密明文，再用当前主密钥加密数据密钥
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	dekAEAD, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dekAEAD, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.current.aead, dek)
+	if err != nil {
+		return "", err
+	}
+	return format(k.current.id, wrapped, ciphertext), nil
+}
+
+// Decrypt 解密密文；非密文（历史明文）原样返回
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	keyID, wrapped, ciphertext, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	dek, err := k.unwrap(keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+	dekAEAD, err := newAEAD(dek)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dekAEAD, ciphertext)
+	if err != nil {
+		return "", errors.New("密文解密失败")
+	}
+	return string(plaintext), nil
+}
+
+// KeyID 返回密文使用的主密钥ID，非密文返回空
+func (k *Keyring) KeyID(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	keyID, _, _, err := parse(value)
+	if err != nil {
+		return ""
+	}
+	return keyID
+}
+
+// Rewrap 用当前主密钥重新加密数据密钥，明文密文本身不变
+func (k *Keyring) Rewrap(value string) (string, error) {
+	keyID, wrapped, ciphertext, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	if keyID == k.current.id {
+		return value, nil
+	}
+	dek, err := k.unwrap(keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+	rewrapped, err := seal(k.current.aead, dek)
+	if err != nil {
+		return "", err
+	}
+	return format(k.current.id, rewrapped, ciphertext), nil
+}
+
+func (k *Keyring) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	mk, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("未找到主密钥 %s", keyID)
+	}
+	dek, err := open(mk.aead, wrapped)
+	if err != nil {
+		return nil, errors.New("数据密钥解密失败")
+	}
+	return dek, nil
+}
+
+func format(keyID string, wrapped, ciphertext []byte) string {
+	return strings.Join([]string{
+		"enc", version, keyID,
+		base64.StdEncoding.EncodeToString(wrapped),
+		base64.StdEncoding.EncodeToString(ciphertext),
+	}, ":")
+}
+
+func parse(value string) (keyID string, wrapped, ciphertext []byte, err error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 5 || parts[0]+":" != prefix || parts[1] != version {
+		return "", nil, nil, errors.New("密文格式错误")
+	}
+	if wrapped, err = base64.StdEncoding.DecodeString(parts[3]); err != nil {
+		return "", nil, nil, errors.New("密文格式错误")
+	}
+	if ciphertext, err = base64.StdEncoding.DecodeString(parts[4]); err != nil {
+		return "", nil, nil, errors.New("密文格式错误")
+	}
+	return parts[2], wrapped, ciphertext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal 加密并把随机 nonce 前置到密文
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, data []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("密文过短")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
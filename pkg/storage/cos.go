@@ -29,6 +29,10 @@ func (s *COSStorage) Delete(ctx context.Context, objectKey string) error {
 	return errors.New("cos storage not implemented")
 }
 
+func (s *COSStorage) Read(ctx context.Context, objectKey string) ([]byte, error) {
+	return nil, errors.New("cos storage not implemented")
+}
+
 func (s *COSStorage) URL(ctx context.Context, objectKey string) (string, error) {
 	return "", errors.New("cos storage not implemented")
 }
@@ -42,6 +42,11 @@ func (s *LocalStorage) Delete(ctx context.Context, objectKey string) error {
 	return nil
 }
 
+func (s *LocalStorage) Read(ctx context.Context, objectKey string) ([]byte, error) {
+	path := filepath.Join(s.baseDir, filepath.FromSlash(objectKey))
+	return os.ReadFile(path)
+}
+
 func (s *LocalStorage) URL(ctx context.Context, objectKey string) (string, error) {
 	return s.buildURL(objectKey), nil
 }
@@ -12,5 +12,6 @@ type ObjectInfo struct {
 type Service interface {
 	Save(ctx context.Context, objectKey string, data []byte) (*ObjectInfo, error)
 	Delete(ctx context.Context, objectKey string) error
+	Read(ctx context.Context, objectKey string) ([]byte, error)
 	URL(ctx context.Context, objectKey string) (string, error)
 }
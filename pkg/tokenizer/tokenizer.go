@@ -0,0 +1,163 @@
+// Package tokenizer 离线估算文本的 Token 数，用于发送前的上下文窗口检查与编辑器实时计数。
+// 不加载词表，按 BPE 分词器的预切分规则（字母串、数字串、标点、空白、汉字）分段后按经验系数折算，
+// 中文误差通常在 ±15% 以内；结果只作预算参考，计费以模型返回的用量为准。
+package tokenizer
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Encoding 一类分词器的估算参数
+type Encoding struct {
+	Name         string
+	CJKPerChar   float64 // 每个中日韩字符折算的 Token 数；针对中文优化的词表常把双字词合并为 1 个 Token
+	LetterPerTok float64 // 超过 6 个字母的长单词平均多少个字符合成 1 个 Token
+}
+
+// 常见分词器的估算参数
+var (
+	// CL100K gpt-3.5 / gpt-4 使用的 cl100k_base，大部分汉字占 1~2 个 Token
+	CL100K = &Encoding{Name: "cl100k", CJKPerChar: 1.3, LetterPerTok: 4.5}
+	// O200K gpt-4o 及之后模型使用的 o200k_base
+	O200K = &Encoding{Name: "o200k", CJKPerChar: 0.85, LetterPerTok: 4.8}
+	// CJKOptimized DeepSeek、Qwen、GLM 等针对中文扩充的词表，约 1.5 个汉字 1 个 Token
+	CJKOptimized = &Encoding{Name: "cjk", CJKPerChar: 0.65, LetterPerTok: 4.5}
+	// Default 无法识别模型时使用，偏保守
+	Default = &Encoding{Name: "default", CJKPerChar: 1.0, LetterPerTok: 4}
+)
+
+// modelEncodings 按模型名前缀匹配分词器，先匹配的优先
+var modelEncodings = []struct {
+	prefix string
+	enc    *Encoding
+}{
+	{"gpt-4o", O200K},
+	{"gpt-4.1", O200K},
+	{"gpt-4.5", O200K},
+	{"gpt-5", O200K},
+	{"o1", O200K},
+	{"o3", O200K},
+	{"o4", O200K},
+	{"gpt-4", CL100K},
+	{"gpt-3.5", CL100K},
+	{"deepseek", CJKOptimized},
+	{"qwen", CJKOptimized},
+	{"glm", CJKOptimized},
+	{"moonshot", CJKOptimized},
+	{"kimi", CJKOptimized},
+	{"doubao", CJKOptimized},
+	{"yi-", CJKOptimized},
+	{"baichuan", CJKOptimized},
+	{"ernie", CJKOptimized},
+	{"hunyuan", CJKOptimized},
+}
+
+// ForModel 按模型名选择分词器，未识别时返回 Default
+func ForModel(modelName string) *Encoding {
+	name := strings.ToLower(modelName)
+	// 兼容 "provider/model" 形式的模型名
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	for _, m := range modelEncodings {
+		if strings.HasPrefix(name, m.prefix) {
+			return m.enc
+		}
+	}
+	return Default
+}
+
+// 字符类别，对应 BPE 预切分的分段
+const (
+	classNone = iota
+	classLetter
+	classDigit
+	classCJK
+	classSpace
+	classOther
+)
+
+func classify(r rune) int {
+	switch {
+	case isCJK(r):
+		return classCJK
+	case r < unicode.MaxASCII && unicode.IsLetter(r):
+		return classLetter
+	case unicode.IsDigit(r):
+		return classDigit
+	case unicode.IsSpace(r):
+		return classSpace
+	case unicode.IsLetter(r):
+		// 西里尔、希腊等其他字母按字母串处理
+		return classLetter
+	default:
+		return classOther
+	}
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// Count 估算文本的 Token 数
+func (e *Encoding) Count(text string) int {
+	if e == nil {
+		e = Default
+	}
+	total := 0.0
+	class, runLen, nonASCII, plainSpace := classNone, 0, false, true
+	flush := func() {
+		switch class {
+		case classLetter:
+			per := e.LetterPerTok
+			if nonASCII {
+				// 非拉丁字母在英文词表中覆盖较差
+				per = 2
+			} else if runLen <= 6 {
+				// 常见短词在词表中为单个 Token
+				total++
+				break
+			}
+			total += math.Max(1, math.Ceil(float64(runLen)/per))
+		case classDigit:
+			// 数字按最多 3 位一组切分
+			total += math.Ceil(float64(runLen) / 3)
+		case classCJK:
+			total += math.Ceil(float64(runLen) * e.CJKPerChar)
+		case classSpace:
+			// 单个空格并入后面的词，其余空白段（换行、缩进）计 1 个 Token
+			if runLen > 1 || !plainSpace {
+				total++
+			}
+		case classOther:
+			// 标点符号常见组合（如 "..."、"——"）会合并，按 2 个字符 1 个 Token 且至少 1 个
+			total += math.Max(1, math.Ceil(float64(runLen)/2))
+		}
+		runLen, nonASCII, plainSpace = 0, false, true
+	}
+
+	for _, r := range text {
+		c := classify(r)
+		if c != class {
+			flush()
+			class = c
+		}
+		if r >= unicode.MaxASCII {
+			nonASCII = true
+		}
+		if r != ' ' {
+			plainSpace = false
+		}
+		runLen++
+	}
+	flush()
+	return int(total)
+}
+
+// Count 使用 Default 估算文本的 Token 数
+func Count(text string) int {
+	return Default.Count(text)
+}
@@ -497,6 +497,71 @@ const docTemplate = `{
                 }
             }
         },
+        "/v1/chapters/{id}/parse": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Chapter"
+                ],
+                "summary": "查询章节最近一次解析的状态与结果",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "章节ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "使用 chapter_parse 用途的模型将章节拆分为场景、对白与旁白；同一章节已有进行中的任务时返回该任务；运行中的解析任务数达到 llm.chapter_parse.concurrency 时返回 42903",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Chapter"
+                ],
+                "summary": "发起章节解析（后台任务）",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "章节ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
         "/v1/chapters/{id}/restore": {
             "post": {
                 "security": [
@@ -563,13 +628,50 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/llm/chat": {
+        "/v1/llm/batches": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "LLM批量任务列表",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "页码",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量",
+                        "name": "page_size",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "description": "任务由 worker 进程异步执行，通过批次详情轮询进度，通过任务明细获取结果",
                 "consumes": [
                     "application/json"
                 ],
@@ -579,21 +681,21 @@ const docTemplate = `{
                 "tags": [
                     "LLM"
                 ],
-                "summary": "发送对话请求",
+                "summary": "提交LLM批量任务",
                 "parameters": [
                     {
-                        "description": "对话请求",
+                        "description": "批量任务",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handler.ChatReq"
+                            "$ref": "#/definitions/handler.SubmitBatchReq"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
                             "$ref": "#/definitions/handler.Resp"
                         }
@@ -601,56 +703,28 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/llm/logs": {
+        "/v1/llm/batches/{id}": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "description": "status: pending 排队 / running 执行中 / completed 全部结束 / canceled 已取消",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "LLM"
                 ],
-                "summary": "调用日志列表",
+                "summary": "LLM批量任务详情",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "页码",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "每页数量",
-                        "name": "page_size",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "用途",
-                        "name": "purpose",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "服务商",
-                        "name": "provider",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "状态",
-                        "name": "status",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "排序",
-                        "name": "sort",
-                        "in": "query"
+                        "description": "批次ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -663,38 +737,28 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/llm/logs/stats": {
-            "get": {
+        "/v1/llm/batches/{id}/cancel": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "description": "排队与执行中的任务标记为已取消，已完成的结果保留",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "LLM"
                 ],
-                "summary": "用量统计",
+                "summary": "取消LLM批量任务",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "起始时间",
-                        "name": "start_time",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "结束时间",
-                        "name": "end_time",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "分组维度",
-                        "name": "group_by",
-                        "in": "query"
+                        "type": "integer",
+                        "description": "批次ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
@@ -707,43 +771,45 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/llm/models": {
+        "/v1/llm/batches/{id}/jobs": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "description": "按提交顺序返回，成功任务的 result 与 POST /v1/llm/chat 响应数据一致",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "LLM"
                 ],
-                "summary": "模型配置列表",
+                "summary": "LLM批量任务明细",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "页码",
-                        "name": "page",
-                        "in": "query"
+                        "description": "批次ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     },
                     {
                         "type": "integer",
-                        "description": "每页数量",
-                        "name": "page_size",
+                        "description": "状态：1排队/2执行中/3成功/4失败/5已取消",
+                        "name": "status",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "服务商",
-                        "name": "provider",
+                        "type": "integer",
+                        "description": "页码",
+                        "name": "page",
                         "in": "query"
                     },
                     {
-                        "type": "string",
-                        "description": "用途",
-                        "name": "purpose",
+                        "type": "integer",
+                        "description": "每页数量",
+                        "name": "page_size",
                         "in": "query"
                     }
                 ],
@@ -755,37 +821,41 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/v1/llm/batches/{id}/jobs/{job_id}/retry": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "consumes": [
-                    "application/json"
-                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "LLM"
                 ],
-                "summary": "创建模型配置",
+                "summary": "重试LLM批量任务中的单个失败任务",
                 "parameters": [
                     {
-                        "description": "创建模型配置",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handler.CreateLLMModelReq"
-                        }
+                        "type": "integer",
+                        "description": "批次ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "任务ID",
+                        "name": "job_id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/handler.Resp"
                         }
@@ -793,8 +863,8 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/llm/models/{id}": {
-            "get": {
+        "/v1/llm/batches/{id}/retry": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
@@ -806,11 +876,11 @@ const docTemplate = `{
                 "tags": [
                     "LLM"
                 ],
-                "summary": "模型配置详情",
+                "summary": "重试LLM批量任务中失败的任务",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "模型配置ID",
+                        "description": "批次ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -824,41 +894,22 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
+            }
+        },
+        "/v1/llm/cache": {
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "consumes": [
-                    "application/json"
-                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "LLM"
                 ],
-                "summary": "更新模型配置",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "模型配置ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "description": "更新模型配置",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handler.UpdateLLMModelReq"
-                        }
-                    }
-                ],
+                "summary": "清空LLM响应缓存（仅管理员）",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -867,25 +918,819 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/v1/llm/chat": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "produces": [
+                "description": "stream=true 或 Accept: text/event-stream 时以SSE返回：delta 事件携带增量内容，done 事件携带完整结果，error 事件携带错误\n多模态消息：content 传数组，如 [{\"type\":\"text\",\"text\":\"描述这张图\"},{\"type\":\"image_url\",\"resource_id\":12}]，resource_id 须为本人上传的图片资源",
+                "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "application/json",
+                    "text/event-stream"
+                ],
                 "tags": [
                     "LLM"
                 ],
-                "summary": "删除模型配置",
+                "summary": "发送对话请求",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "模型配置ID",
-                        "name": "id",
+                        "type": "boolean",
+                        "description": "是否流式返回",
+                        "name": "stream",
+                        "in": "query"
+                    },
+                    {
+                        "description": "对话请求",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.ChatReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/conversations": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "会话列表",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "页码",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "项目ID",
+                        "name": "project_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "章节ID",
+                        "name": "chapter_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "创建多轮对话会话",
+                "parameters": [
+                    {
+                        "description": "创建会话",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateConversationReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/conversations/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "会话详情",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "会话ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "删除会话",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "会话ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/conversations/{id}/messages": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "历史消息超出上下文预算时从最早的消息开始截断，truncated 为本轮未发送的历史条数",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "追加用户消息并获取回复",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "会话ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "消息",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.SendMessageReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/logs": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "非管理员只返回自己的调用记录，user_id 参数仅管理员可用",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "调用日志列表",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "页码",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "用途",
+                        "name": "purpose",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "服务商",
+                        "name": "provider",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "状态",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "用户ID",
+                        "name": "user_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "排序",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/logs/stats": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "费用按币种返回（costs），groups、series 与 top_users 中不同币种的费用不相加；顶层 total_cost 为不区分币种的合计",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "用量统计（仅管理员）",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "起始时间",
+                        "name": "start_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "结束时间",
+                        "name": "end_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "分组维度: provider/purpose/model/model_id/user_id/status/day/arm，默认 provider",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "时间序列粒度: hour/day/week，指定后返回 series 与 top_users",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "时间序列第二维度: provider/purpose/model/arm",
+                        "name": "split_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "用量前N的用户，默认10，最大100",
+                        "name": "top_n",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/logs/{id}/payload": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "仅在开启 llm.capture 且命中采样时记录，超过保留期后不可查",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "查询调用日志的请求与返回内容（仅管理员）",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "调用日志ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/models": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "模型配置列表（仅管理员）",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "页码",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "服务商",
+                        "name": "provider",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "用途",
+                        "name": "purpose",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "创建模型配置（仅管理员）",
+                "parameters": [
+                    {
+                        "description": "创建模型配置",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateLLMModelReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/models/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "模型配置详情（仅管理员）",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "模型配置ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "更新模型配置（仅管理员）",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "模型配置ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "更新模型配置",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.UpdateLLMModelReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "删除模型配置（仅管理员）",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "模型配置ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    }
+                }
+            }
+        },
+        "/v1/llm/models/{id}/test": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "发送最小探测请求，返回耗时、HTTP状态类别与服务商返回的模型标识，并更新模型健康状态",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "模型连通性测试（仅管理员）",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "模型配置ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/prompts": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "提示词模板列表",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "页码",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "用途",
+                        "name": "purpose",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "是否启用",
+                        "name": "is_active",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "创建提示词模板版本（仅管理员）",
+                "parameters": [
+                    {
+                        "description": "创建提示词模板",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateLLMPromptReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/prompts/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "提示词模板详情",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "模板ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "更新提示词模板（名称、说明、启用状态）（仅管理员）",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "模板ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "更新提示词模板",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.UpdateLLMPromptReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "删除提示词模板（仅管理员）",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "模板ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     }
@@ -895,10 +1740,265 @@ const docTemplate = `{
                         "description": "No Content"
                     }
                 }
-            }
-        },
-        "/v1/projects": {
-            "get": {
+            }
+        },
+        "/v1/llm/tokenize": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "离线估算，不调用模型，用于编辑器实时显示章节 Token 数及是否超出模型上下文窗口",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "估算文本或消息的 Token 数",
+                "parameters": [
+                    {
+                        "description": "估算请求",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.TokenizeReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/tools": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "对话请求的 tools 字段填写工具名称后，模型可在回答前调用这些工具读取项目数据",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "可用的服务端工具",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/llm/usage/me": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "limit 为 0 表示不限，此时 remaining 为 -1",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "LLM"
+                ],
+                "summary": "查询当前用户的Token用量与剩余额度",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/projects": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Project"
+                ],
+                "summary": "获取项目列表",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "页码",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "状态",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "关键词",
+                        "name": "keyword",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "排序",
+                        "name": "sort",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Project"
+                ],
+                "summary": "创建项目",
+                "parameters": [
+                    {
+                        "description": "创建项目",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateProjectReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/projects/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Project"
+                ],
+                "summary": "获取项目详情",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "项目ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Project"
+                ],
+                "summary": "更新项目",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "项目ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "更新项目",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.UpdateProjectReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
@@ -910,78 +2010,52 @@ const docTemplate = `{
                 "tags": [
                     "Project"
                 ],
-                "summary": "获取项目列表",
+                "summary": "删除项目",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "页码",
-                        "name": "page",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "每页数量",
-                        "name": "page_size",
-                        "in": "query"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "状态",
-                        "name": "status",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "关键词",
-                        "name": "keyword",
-                        "in": "query"
-                    },
-                    {
-                        "type": "string",
-                        "description": "排序",
-                        "name": "sort",
-                        "in": "query"
+                        "description": "项目ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "204": {
+                        "description": "No Content",
                         "schema": {
                             "$ref": "#/definitions/handler.Resp"
                         }
                     }
                 }
-            },
+            }
+        },
+        "/v1/projects/{id}/archive": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "consumes": [
-                    "application/json"
-                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
                     "Project"
                 ],
-                "summary": "创建项目",
+                "summary": "归档项目",
                 "parameters": [
                     {
-                        "description": "创建项目",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handler.CreateProjectReq"
-                        }
+                        "type": "integer",
+                        "description": "项目ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "$ref": "#/definitions/handler.Resp"
                         }
@@ -989,8 +2063,8 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/projects/{id}": {
-            "get": {
+        "/v1/projects/{id}/restore": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
@@ -1002,7 +2076,7 @@ const docTemplate = `{
                 "tags": [
                     "Project"
                 ],
-                "summary": "获取项目详情",
+                "summary": "恢复项目",
                 "parameters": [
                     {
                         "type": "integer",
@@ -1020,23 +2094,22 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
+            }
+        },
+        "/v1/projects/{id}/subjects": {
+            "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "consumes": [
-                    "application/json"
-                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Project"
+                    "Subject"
                 ],
-                "summary": "更新项目",
+                "summary": "项目主体列表",
                 "parameters": [
                     {
                         "type": "integer",
@@ -1046,13 +2119,22 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "description": "更新项目",
-                        "name": "body",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handler.UpdateProjectReq"
-                        }
+                        "type": "integer",
+                        "description": "页码",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "每页数量",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "名称或别名关键字",
+                        "name": "keyword",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -1064,19 +2146,22 @@ const docTemplate = `{
                     }
                 }
             },
-            "delete": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Project"
+                    "Subject"
                 ],
-                "summary": "删除项目",
+                "summary": "创建主体",
                 "parameters": [
                     {
                         "type": "integer",
@@ -1084,11 +2169,20 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "创建主体",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateSubjectReq"
+                        }
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content",
+                    "201": {
+                        "description": "Created",
                         "schema": {
                             "$ref": "#/definitions/handler.Resp"
                         }
@@ -1096,20 +2190,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/projects/{id}/archive": {
+        "/v1/projects/{id}/subjects/extract": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "description": "使用 subject_extract 用途的模型逐章提取人物，按名称与别名去重合并入项目主体库",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Project"
+                    "Subject"
                 ],
-                "summary": "归档项目",
+                "summary": "从项目章节提取主体",
                 "parameters": [
                     {
                         "type": "integer",
@@ -1129,20 +2224,24 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/projects/{id}/restore": {
+        "/v1/projects/{id}/subjects/merge": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
+                "description": "来源主体的名称与别名并入目标主体别名，目标缺失的描述、参考图、声音用来源补齐，来源主体被删除",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Project"
+                    "Subject"
                 ],
-                "summary": "恢复项目",
+                "summary": "合并主体",
                 "parameters": [
                     {
                         "type": "integer",
@@ -1150,6 +2249,15 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "description": "合并主体",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.MergeSubjectsReq"
+                        }
                     }
                 ],
                 "responses": {
@@ -1266,21 +2374,134 @@ const docTemplate = `{
                         "in": "formData"
                     },
                     {
-                        "type": "string",
-                        "description": "分类",
-                        "name": "category",
-                        "in": "formData"
+                        "type": "string",
+                        "description": "分类",
+                        "name": "category",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "扩展数据(JSON)",
+                        "name": "extra_data",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/resources/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Resource"
+                ],
+                "summary": "获取资源详情",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "资源ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Resource"
+                ],
+                "summary": "更新资源信息",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "资源ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "更新信息",
+                        "name": "body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.UpdateReq"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.Resp"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Resource"
+                ],
+                "summary": "删除资源",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "资源ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "扩展数据(JSON)",
-                        "name": "extra_data",
-                        "in": "formData"
+                        "type": "boolean",
+                        "description": "是否硬删除",
+                        "name": "hard",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "204": {
+                        "description": "No Content",
                         "schema": {
                             "$ref": "#/definitions/handler.Resp"
                         }
@@ -1288,7 +2509,7 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/resources/{id}": {
+        "/v1/subjects/{id}": {
             "get": {
                 "security": [
                     {
@@ -1299,13 +2520,13 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Resource"
+                    "Subject"
                 ],
-                "summary": "获取资源详情",
+                "summary": "主体详情",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "资源ID",
+                        "description": "主体ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -1333,24 +2554,24 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Resource"
+                    "Subject"
                 ],
-                "summary": "更新资源信息",
+                "summary": "更新主体",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "资源ID",
+                        "description": "主体ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
-                        "description": "更新信息",
+                        "description": "更新主体",
                         "name": "body",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/handler.UpdateReq"
+                            "$ref": "#/definitions/handler.UpdateSubjectReq"
                         }
                     }
                 ],
@@ -1373,22 +2594,16 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Resource"
+                    "Subject"
                 ],
-                "summary": "删除资源",
+                "summary": "删除主体",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "资源ID",
+                        "description": "主体ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "type": "boolean",
-                        "description": "是否硬删除",
-                        "name": "hard",
-                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -1638,11 +2853,19 @@ const docTemplate = `{
         "handler.ChatReq": {
             "type": "object",
             "properties": {
+                "cache": {
+                    "description": "温度非0时也使用响应缓存（仅非流式）",
+                    "type": "boolean"
+                },
+                "json_schema": {
+                    "description": "输出需符合的 JSON Schema（仅非流式），不符合时自动要求模型修正",
+                    "type": "object"
+                },
                 "max_tokens": {
                     "type": "integer"
                 },
                 "messages": {
-                    "description": "消息列表",
+                    "description": "消息列表；content 可为字符串或内容片段数组，图片片段用 image_url.url 或本人图片资源的 resource_id",
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/llm.ChatMessage"
@@ -1652,6 +2875,14 @@ const docTemplate = `{
                     "description": "指定模型配置ID",
                     "type": "integer"
                 },
+                "overflow": {
+                    "description": "输入超出模型上下文窗口时：reject 返回 41301 / trim 丢弃最早的历史消息，为空按服务端配置",
+                    "type": "string"
+                },
+                "prompt_id": {
+                    "description": "提示词模板ID，已停用的版本不可使用；未提供 messages 时按 purpose 使用最新启用模板",
+                    "type": "integer"
+                },
                 "purpose": {
                     "description": "用途",
                     "type": "string"
@@ -1660,8 +2891,24 @@ const docTemplate = `{
                     "description": "text / json",
                     "type": "string"
                 },
+                "stream": {
+                    "description": "是否以SSE流式返回",
+                    "type": "boolean"
+                },
                 "temperature": {
                     "type": "number"
+                },
+                "tools": {
+                    "description": "允许模型调用的服务端工具（仅非流式），见 GET /v1/llm/tools",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "variables": {
+                    "description": "模板变量",
+                    "type": "object",
+                    "additionalProperties": true
                 }
             }
         },
@@ -1690,6 +2937,35 @@ const docTemplate = `{
                 }
             }
         },
+        "handler.CreateConversationReq": {
+            "type": "object",
+            "properties": {
+                "chapter_id": {
+                    "description": "关联章节ID（可选）",
+                    "type": "integer"
+                },
+                "model_id": {
+                    "description": "固定模型配置ID（可选）",
+                    "type": "integer"
+                },
+                "project_id": {
+                    "description": "关联项目ID（可选）",
+                    "type": "integer"
+                },
+                "purpose": {
+                    "description": "用途（可选，默认 default）",
+                    "type": "string"
+                },
+                "system_prompt": {
+                    "description": "系统提示词（可选）",
+                    "type": "string"
+                },
+                "title": {
+                    "description": "标题（可选，默认取首条消息）",
+                    "type": "string"
+                }
+            }
+        },
         "handler.CreateLLMModelReq": {
             "type": "object",
             "properties": {
@@ -1701,6 +2977,26 @@ const docTemplate = `{
                     "description": "API端点（必填）",
                     "type": "string"
                 },
+                "cached_input_price": {
+                    "description": "缓存命中输入单价（每1K Token），0 表示按输入单价计",
+                    "type": "number"
+                },
+                "context_window": {
+                    "description": "上下文窗口（输入+输出Token上限），0 表示不检查",
+                    "type": "integer"
+                },
+                "currency": {
+                    "description": "币种（默认 CNY）",
+                    "type": "string"
+                },
+                "extra_config": {
+                    "description": "扩展配置（retry: max_attempts/base_backoff_ms/max_backoff_ms/jitter；limits: max_concurrency/rpm/tpm）",
+                    "type": "object"
+                },
+                "input_price": {
+                    "description": "输入单价（每1K Token）",
+                    "type": "number"
+                },
                 "max_tokens": {
                     "description": "最大输出Token",
                     "type": "integer"
@@ -1713,8 +3009,16 @@ const docTemplate = `{
                     "description": "显示名称（必填）",
                     "type": "string"
                 },
+                "output_price": {
+                    "description": "输出单价（每1K Token）",
+                    "type": "number"
+                },
+                "priority": {
+                    "description": "同用途降级顺序，越小越优先",
+                    "type": "integer"
+                },
                 "provider": {
-                    "description": "服务商标识（必填）",
+                    "description": "服务商标识（必填），anthropic 使用 Messages API，其余按 OpenAI 兼容协议",
                     "type": "string"
                 },
                 "purpose": {
@@ -1728,6 +3032,34 @@ const docTemplate = `{
                 "timeout": {
                     "description": "超时时间",
                     "type": "integer"
+                },
+                "weight": {
+                    "description": "同优先级内的流量权重（默认100，0 表示只作为降级备选）",
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.CreateLLMPromptReq": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "description": "模板说明",
+                    "type": "string"
+                },
+                "messages": {
+                    "description": "消息模板（必填），content 支持 Go text/template 变量，如 {{.content}}",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/llm.ChatMessage"
+                    }
+                },
+                "name": {
+                    "description": "模板名称（必填）",
+                    "type": "string"
+                },
+                "purpose": {
+                    "description": "用途（必填），同用途下自动生成新版本",
+                    "type": "string"
                 }
             }
         },
@@ -1756,6 +3088,38 @@ const docTemplate = `{
                 }
             }
         },
+        "handler.CreateSubjectReq": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "description": "别名（可选）",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "appearance_prompt": {
+                    "description": "外观提示词（可选）",
+                    "type": "string"
+                },
+                "description": {
+                    "description": "人物描述（可选）",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "主体名称（必填，项目内唯一）",
+                    "type": "string"
+                },
+                "resource_id": {
+                    "description": "参考图资源ID（可选）",
+                    "type": "integer"
+                },
+                "voice_id": {
+                    "description": "绑定声音ID（可选）",
+                    "type": "integer"
+                }
+            }
+        },
         "handler.CreateVoiceReq": {
             "type": "object",
             "properties": {
@@ -1802,6 +3166,22 @@ const docTemplate = `{
                 }
             }
         },
+        "handler.MergeSubjectsReq": {
+            "type": "object",
+            "properties": {
+                "source_ids": {
+                    "description": "并入目标后删除的主体ID（必填）",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "target_id": {
+                    "description": "保留的主体ID（必填）",
+                    "type": "integer"
+                }
+            }
+        },
         "handler.PasswordReq": {
             "type": "object",
             "properties": {
@@ -1856,6 +3236,27 @@ const docTemplate = `{
                 }
             }
         },
+        "handler.SendMessageReq": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "description": "用户消息（必填）",
+                    "type": "string"
+                },
+                "max_tokens": {
+                    "description": "最大输出Token（可选）",
+                    "type": "integer"
+                },
+                "response_format": {
+                    "description": "返回格式: text/json（可选）",
+                    "type": "string"
+                },
+                "temperature": {
+                    "description": "温度（可选）",
+                    "type": "number"
+                }
+            }
+        },
         "handler.SendVerifyCodeReq": {
             "type": "object",
             "properties": {
@@ -1878,6 +3279,50 @@ const docTemplate = `{
                 }
             }
         },
+        "handler.SubmitBatchReq": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "description": "任务列表，每项与 POST /v1/llm/chat 请求体一致（stream 无效）",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/handler.ChatReq"
+                    }
+                },
+                "name": {
+                    "description": "批次名称（可选）",
+                    "type": "string"
+                }
+            }
+        },
+        "handler.TokenizeReq": {
+            "type": "object",
+            "properties": {
+                "max_tokens": {
+                    "description": "回复预留，默认取模型配置",
+                    "type": "integer"
+                },
+                "messages": {
+                    "description": "待估算消息列表",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/llm.ChatMessage"
+                    }
+                },
+                "model_id": {
+                    "description": "按指定模型估算",
+                    "type": "integer"
+                },
+                "purpose": {
+                    "description": "未指定 model_id 时按该用途的首选模型估算",
+                    "type": "string"
+                },
+                "text": {
+                    "description": "待估算文本，与 messages 二选一",
+                    "type": "string"
+                }
+            }
+        },
         "handler.UpdateChapterReq": {
             "type": "object",
             "properties": {
@@ -1912,6 +3357,21 @@ const docTemplate = `{
                 "base_url": {
                     "type": "string"
                 },
+                "cached_input_price": {
+                    "type": "number"
+                },
+                "context_window": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "extra_config": {
+                    "type": "object"
+                },
+                "input_price": {
+                    "type": "number"
+                },
                 "is_active": {
                     "type": "boolean"
                 },
@@ -1924,6 +3384,12 @@ const docTemplate = `{
                 "name": {
                     "type": "string"
                 },
+                "output_price": {
+                    "type": "number"
+                },
+                "priority": {
+                    "type": "integer"
+                },
                 "provider": {
                     "type": "string"
                 },
@@ -1935,6 +3401,23 @@ const docTemplate = `{
                 },
                 "timeout": {
                     "type": "integer"
+                },
+                "weight": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.UpdateLLMPromptReq": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "is_active": {
+                    "type": "boolean"
+                },
+                "name": {
+                    "type": "string"
                 }
             }
         },
@@ -1985,6 +3468,38 @@ const docTemplate = `{
                 }
             }
         },
+        "handler.UpdateSubjectReq": {
+            "type": "object",
+            "properties": {
+                "aliases": {
+                    "description": "别名，整体替换（可选）",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "appearance_prompt": {
+                    "description": "外观提示词（可选）",
+                    "type": "string"
+                },
+                "description": {
+                    "description": "人物描述（可选）",
+                    "type": "string"
+                },
+                "name": {
+                    "description": "主体名称（可选）",
+                    "type": "string"
+                },
+                "resource_id": {
+                    "description": "参考图资源ID（可选，传0解绑）",
+                    "type": "integer"
+                },
+                "voice_id": {
+                    "description": "绑定声音ID（可选，传0解绑）",
+                    "type": "integer"
+                }
+            }
+        },
         "handler.UpdateVoiceReq": {
             "type": "object",
             "properties": {
@@ -2022,7 +3537,44 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "role": {
-                    "description": "system / user / assistant",
+                    "description": "system / user / assistant / tool",
+                    "type": "string"
+                },
+                "tool_call_id": {
+                    "description": "tool 消息对应的调用ID",
+                    "type": "string"
+                },
+                "tool_calls": {
+                    "description": "assistant 消息发起的工具调用",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/llm.ToolCall"
+                    }
+                }
+            }
+        },
+        "llm.FunctionCall": {
+            "type": "object",
+            "properties": {
+                "arguments": {
+                    "description": "JSON 字符串，可能不合法，需调用方校验",
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "llm.ToolCall": {
+            "type": "object",
+            "properties": {
+                "function": {
+                    "$ref": "#/definitions/llm.FunctionCall"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "type": {
                     "type": "string"
                 }
             }